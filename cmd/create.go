@@ -7,10 +7,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	core "github.com/yum45f/did-dnssec/pkg"
-	"golang.org/x/net/idna"
 )
 
 // createCmd represents the create command
@@ -25,10 +25,23 @@ func init() {
 	rootCmd.AddCommand(createCmd)
 
 	createCmd.Flags().StringP("basefqdn", "b", "", "Base FQDN (e.g. example.com.)")
+	createCmd.Flags().String("id", "", "DID of the document, which may have sub-identifiers (e.g. did:dnssec:example.com:alice)")
 	createCmd.Flags().StringP("didjson", "d", "", "DID document file path")
 	createCmd.Flags().StringP("out", "o", "", "Output file path")
-
-	createCmd.MarkFlagRequired("basefqdn")
+	createCmd.Flags().StringP("encoding", "e", "tree", "Record encoding (tree|compact)")
+	createCmd.Flags().Bool("compress", false, "Compress the document (compact encoding only)")
+	createCmd.Flags().String("payload", "json", "Document payload format (json|cbor, compact encoding only)")
+	createCmd.Flags().String("labels", "base64", "Label encoding of the map keys (base64|base32, tree encoding only)")
+	createCmd.Flags().Int("version-id", 0, "Version number of the document; no version record is published if 0")
+	createCmd.Flags().String("version-time", "", "Publication time of the version in RFC 3339 (default: now)")
+	createCmd.Flags().Bool("archive", false, "Publish the document as the previous version under v<version-id>._did")
+	createCmd.Flags().Bool("no-validate", false, "Skip the DID Core conformance validation of the document")
+	createCmd.Flags().String("host", "", "FQDN of the hosting provider's zone to publish the records under (e.g. example-com.hoster.net.)")
+	createCmd.Flags().String("delegation-out", "", "Output file path of the CNAME record delegating the DID to the host")
+
+	createCmd.MarkFlagsOneRequired("basefqdn", "id")
+	createCmd.MarkFlagsMutuallyExclusive("basefqdn", "id")
+	createCmd.MarkFlagsRequiredTogether("host", "delegation-out")
 	createCmd.MarkFlagRequired("didjson")
 	createCmd.MarkFlagRequired("out")
 }
@@ -63,9 +76,82 @@ func handleCreate(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	// check if base is a valid FQDN
-	if _, err := idna.ToASCII(base); err != nil {
-		return fmt.Errorf("basefqdn is not a valid FQDN")
+	// the records are published under the A-labels of the base
+	if base != "" {
+		if base, err = core.NormalizeDomain(base); err != nil {
+			return fmt.Errorf("basefqdn is not a valid FQDN: %w", err)
+		}
+	}
+
+	// the records of the DID with the sub-identifiers are published under the name derived from them
+	if id, err := cmd.Flags().GetString("id"); err != nil {
+		return err
+	} else if id != "" {
+		if base, err = core.DIDBase(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Publishing under _did.%s\n", base)
+	}
+
+	encoding, err := cmd.Flags().GetString("encoding")
+	if err != nil {
+		return err
+	}
+	if encoding != "tree" && encoding != "compact" {
+		return fmt.Errorf("encoding must be tree or compact")
+	}
+
+	compress, err := cmd.Flags().GetBool("compress")
+	if err != nil {
+		return err
+	}
+	if compress && encoding != "compact" {
+		return fmt.Errorf("compress is only available with the compact encoding")
+	}
+
+	payloadStr, err := cmd.Flags().GetString("payload")
+	if err != nil {
+		return err
+	}
+	payload, err := core.ParsePayloadFormat(payloadStr)
+	if err != nil {
+		return err
+	}
+	if payload != core.PayloadJSON && encoding != "compact" {
+		return fmt.Errorf("payload is only available with the compact encoding")
+	}
+
+	labels, err := getLabelEncoding(cmd)
+	if err != nil {
+		return err
+	}
+	if labels != core.LabelBase64 && encoding != "tree" {
+		return fmt.Errorf("labels is only available with the tree encoding")
+	}
+
+	versionID, err := cmd.Flags().GetInt("version-id")
+	if err != nil {
+		return err
+	}
+	if versionID < 0 {
+		return fmt.Errorf("version-id must be positive")
+	}
+
+	versionTime := time.Now()
+	if vt, err := cmd.Flags().GetString("version-time"); err != nil {
+		return err
+	} else if vt != "" {
+		if versionTime, err = time.Parse(time.RFC3339, vt); err != nil {
+			return fmt.Errorf("version-time must be in RFC 3339")
+		}
+	}
+
+	archive, err := cmd.Flags().GetBool("archive")
+	if err != nil {
+		return err
+	}
+	if archive && versionID == 0 {
+		return fmt.Errorf("archive requires version-id")
 	}
 
 	f, err := os.Open(json)
@@ -84,16 +170,83 @@ func handleCreate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if noValidate, err := cmd.Flags().GetBool("no-validate"); err != nil {
+		return err
+	} else if !noValidate {
+		if err := core.Validate(doc, base); err != nil {
+			return err
+		}
+	}
+
+	// the document is validated against the DID, and published under the host
+	if host, err := cmd.Flags().GetString("host"); err != nil {
+		return err
+	} else if host != "" {
+		if host, err = core.NormalizeDomain(host); err != nil {
+			return fmt.Errorf("host is not a valid FQDN: %w", err)
+		}
+
+		delegationOut, err := cmd.Flags().GetString("delegation-out")
+		if err != nil {
+			return err
+		}
+		if err := writeRRsFile(cmd, delegationOut, []*core.ResorceRecord{core.DelegationRR(base, host)}); err != nil {
+			return err
+		}
+
+		base = host
+	}
+
 	f, err = os.Create(out)
 	if err != nil {
 		return err
 	}
 
-	if err := doc.DumpRRs(f, base); err != nil {
+	var rrs []*core.ResorceRecord
+	if encoding == "compact" {
+		rrs, err = doc.CompactRRs(base, core.CompactOptions{
+			Compress: compress,
+			Payload:  payload,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		rrs, err = doc.TreeRRs(base, labels)
+		if err != nil {
+			return err
+		}
+	}
+
+	if versionID > 0 {
+		version := core.Version{ID: versionID, Time: versionTime}
+		rrs = append(rrs, version.RR(fmt.Sprintf("_did.%s", base)))
+		if archive {
+			rrs = core.ArchiveRRs(rrs, base, versionID)
+		}
+	}
+
+	if err := core.WriteRRs(f, rrs); err != nil {
 		return err
 	} else {
-		fmt.Printf("Dumped to %s\n", out)
+		fmt.Fprintf(cmd.ErrOrStderr(), "Dumped to %s\n", out)
+	}
+
+	return nil
+}
+
+// writeRRsFile writes the resource records to the file in the zone file format.
+func writeRRsFile(cmd *cobra.Command, path string, rrs []*core.ResorceRecord) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := core.WriteRRs(f, rrs); err != nil {
+		return err
 	}
 
+	fmt.Fprintf(cmd.ErrOrStderr(), "Dumped to %s\n", path)
 	return nil
 }
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// deactivateCmd represents the deactivate command
+var deactivateCmd = &cobra.Command{
+	Use:   "deactivate",
+	Short: "Deactivate a DID by publishing the tombstone record",
+	Long: `Deactivate a DID by publishing the tombstone record.
+
+The records of the document as published are looked up, and replaced with the
+tombstone published as the next version. The current version is archived under
+v<id>._did.<base>, and the previous versions are kept, so that they are still
+resolved with ?versionId= and ?versionTime= after the deactivation. Records
+delegated to another zone with CNAME or DNAME must be deactivated at the host.
+
+The update is sent to the primary server with the dynamic update (RFC 2136)
+with --server. With --out, the new records are written to the zone file instead;
+the records of the current version under _did.<base> must be removed from the
+zone by hand.
+
+The DID with the sub-identifiers is given with --id.`,
+	RunE: handleDeactivate,
+}
+
+func init() {
+	rootCmd.AddCommand(deactivateCmd)
+
+	deactivateCmd.Flags().StringP("basefqdn", "b", "", "Base FQDN (e.g. example.com.)")
+	deactivateCmd.Flags().String("id", "", "DID to deactivate, which may have the sub-identifiers")
+	deactivateCmd.Flags().StringP("out", "o", "", "Output file path")
+	addUpdateFlags(deactivateCmd)
+
+	deactivateCmd.MarkFlagsOneRequired("basefqdn", "id")
+	deactivateCmd.MarkFlagsMutuallyExclusive("basefqdn", "id")
+	deactivateCmd.MarkFlagsMutuallyExclusive("out", "server")
+	deactivateCmd.MarkFlagsOneRequired("out", "server")
+}
+
+func handleDeactivate(cmd *cobra.Command, args []string) error {
+	base, err := cmd.Flags().GetString("basefqdn")
+	if err != nil {
+		return err
+	}
+	// the records are published under the A-labels of the base
+	if base != "" {
+		if base, err = core.NormalizeDomain(base); err != nil {
+			return fmt.Errorf("basefqdn is not a valid FQDN: %w", err)
+		}
+	}
+
+	did, err := cmd.Flags().GetString("id")
+	if err != nil {
+		return err
+	}
+	if did != "" {
+		if base, err = core.DIDBase(did); err != nil {
+			return err
+		}
+	} else if did, err = core.DIDFromDomain(base); err != nil {
+		return err
+	}
+
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	// the records are replaced as they are published, whichever encoding they use
+	published, err := core.LookupPublished(cmd.Context(), base)
+	if errors.Is(err, core.ErrNotFound) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "No document is published under _did.%s\n", base)
+		published = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to look up the published records of %s: %w", did, err)
+	}
+
+	rrs, err := core.DeactivateRRs(published, base, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := core.WriteRRs(f, rrs); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.ErrOrStderr(), "Dumped to %s\n", out)
+		return nil
+	}
+
+	cfg, err := getUpdateConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	old := []*core.ResorceRecord{}
+	if published != nil {
+		old = published.RRs
+	}
+	if err := core.DiffRRs(old, rrs).Send(cfg); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Deactivated %s\n", did)
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+var createDedupeCmd = &cobra.Command{
+	Use:   "dedupe <didjson>...",
+	Short: "Create a zone file from DID documents sharing their identical subtrees",
+	Long: `Create a zone file from DID documents in the tree encoding, publishing the subtrees
+which appear more than once among them only once under the shared name, and the reference
+records to them where they appear. Each document is published under the base of its id.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: handleCreateDedupe,
+}
+
+func init() {
+	createCmd.AddCommand(createDedupeCmd)
+
+	createDedupeCmd.Flags().String("shared", "", "FQDN to publish the shared subtrees under (e.g. shared.example.com.)")
+	createDedupeCmd.Flags().StringP("out", "o", "", "Output file path")
+	createDedupeCmd.Flags().String("labels", "base64", "Label encoding of the map keys (base64|base32)")
+	createDedupeCmd.Flags().Bool("no-validate", false, "Skip the DID Core conformance validation of the documents")
+
+	createDedupeCmd.MarkFlagRequired("shared")
+	createDedupeCmd.MarkFlagRequired("out")
+}
+
+func handleCreateDedupe(cmd *cobra.Command, args []string) error {
+	shared, err := cmd.Flags().GetString("shared")
+	if err != nil {
+		return err
+	}
+	if shared, err = core.NormalizeDomain(shared); err != nil {
+		return fmt.Errorf("shared is not a valid FQDN: %w", err)
+	}
+
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	labels, err := getLabelEncoding(cmd)
+	if err != nil {
+		return err
+	}
+
+	noValidate, err := cmd.Flags().GetBool("no-validate")
+	if err != nil {
+		return err
+	}
+
+	docs := map[string]*core.Node{}
+	for _, path := range args {
+		base, doc, err := readDocumentNode(path)
+		if err != nil {
+			return err
+		}
+		if _, ok := docs[base]; ok {
+			return fmt.Errorf("%s: duplicated document; base = %s", path, base)
+		}
+
+		if !noValidate {
+			if err := core.Validate(doc, base); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		docs[base] = doc
+	}
+
+	rrs, err := core.DedupeRRs(docs, shared, labels)
+	if err != nil {
+		return err
+	}
+
+	return writeRRsFile(cmd, out, rrs)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// readDocument reads the DID document from the JSON file.
+func readDocument(path string) (*core.DIDDocument, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bytes, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := core.CreateFromJSON(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewDIDDocument(node)
+}
+
+// writeDocument validates the DID document and writes it to the JSON file.
+func writeDocument(cmd *cobra.Command, path string, doc *core.DIDDocument) error {
+	node, err := doc.Node()
+	if err != nil {
+		return err
+	}
+	if err := core.Validate(node, ""); err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, append(bytes, '\n'), 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Written to %s\n", path)
+	return nil
+}
@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// keyCmd represents the key command
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the keys and verification methods of a DID document",
+	Long:  `Manage the keys and verification methods of a DID document.`,
+}
+
+// keyGenerateCmd represents the key generate command
+var keyGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a key pair and its verification method",
+	Long: `Generate a key pair and its verification method.
+
+The verification method is written as JSON to --out (or stdout), and the
+private key is written as JWK to --private-out, which must not exist.`,
+	RunE: handleKeyGenerate,
+}
+
+// keyAddCmd represents the key add command
+var keyAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a verification method to a DID document",
+	Long: `Add a verification method to a DID document.
+
+The verification method is read from --vm, or generated with --type. It is
+referred from the relationships given with --relationship.
+
+The private key of the generated method is written as JWK to --private-out,
+which must not exist, only after the document is validated and written.`,
+	RunE: handleKeyAdd,
+}
+
+// keyRemoveCmd represents the key remove command
+var keyRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove a verification method from a DID document",
+	Long: `Remove a verification method from a DID document.
+
+The references to the verification method are removed from the relationships,
+or replaced with --replace-with to rotate the key.`,
+	RunE: handleKeyRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(keyCmd)
+	keyCmd.AddCommand(keyGenerateCmd, keyAddCmd, keyRemoveCmd)
+
+	keyGenerateCmd.Flags().StringP("type", "t", "ed25519", "Key type (ed25519|secp256k1|p256)")
+	keyGenerateCmd.Flags().StringP("format", "f", "multikey", "Verification method format (multikey|jwk)")
+	keyGenerateCmd.Flags().String("id", "", "Verification method id (e.g. did:dnssec:example.com#key-1)")
+	keyGenerateCmd.Flags().String("controller", "", "Controller DID of the verification method")
+	keyGenerateCmd.Flags().StringP("out", "o", "", "Output file path of the verification method (default: stdout)")
+	keyGenerateCmd.Flags().String("private-out", "", "Output file path of the private key")
+	keyGenerateCmd.MarkFlagRequired("id")
+	keyGenerateCmd.MarkFlagRequired("controller")
+	keyGenerateCmd.MarkFlagRequired("private-out")
+
+	keyAddCmd.Flags().StringP("didjson", "d", "", "DID document file path")
+	keyAddCmd.Flags().StringP("out", "o", "", "Output file path (default: overwrite didjson)")
+	keyAddCmd.Flags().String("vm", "", "Verification method file path")
+	keyAddCmd.Flags().StringP("type", "t", "", "Key type to generate (ed25519|secp256k1|p256)")
+	keyAddCmd.Flags().StringP("format", "f", "multikey", "Verification method format to generate (multikey|jwk)")
+	keyAddCmd.Flags().String("id", "", "Verification method id to generate (default: #key-<n>)")
+	keyAddCmd.Flags().String("private-out", "", "Output file path of the generated private key")
+	keyAddCmd.Flags().StringSliceP("relationship", "r", []string{"authentication"},
+		"Verification relationships referring to the key (e.g. authentication,assertionMethod)")
+	keyAddCmd.MarkFlagRequired("didjson")
+	keyAddCmd.MarkFlagsMutuallyExclusive("vm", "type")
+	keyAddCmd.MarkFlagsOneRequired("vm", "type")
+	keyAddCmd.MarkFlagsRequiredTogether("type", "private-out")
+
+	keyRemoveCmd.Flags().StringP("didjson", "d", "", "DID document file path")
+	keyRemoveCmd.Flags().StringP("out", "o", "", "Output file path (default: overwrite didjson)")
+	keyRemoveCmd.Flags().String("id", "", "Verification method id to remove")
+	keyRemoveCmd.Flags().String("replace-with", "", "Verification method id replacing the references")
+	keyRemoveCmd.MarkFlagRequired("didjson")
+	keyRemoveCmd.MarkFlagRequired("id")
+}
+
+func handleKeyGenerate(cmd *cobra.Command, args []string) error {
+	id, err := cmd.Flags().GetString("id")
+	if err != nil {
+		return err
+	}
+
+	controller, err := cmd.Flags().GetString("controller")
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	vm, key, err := generateVerificationMethod(cmd, id, controller)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(vm, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := createPrivateKeyFile(cmd)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+	} else if err := os.WriteFile(out, append(bytes, '\n'), 0644); err != nil {
+		discardPrivateKeyFile(f)
+		return err
+	}
+
+	return writePrivateKey(f, key)
+}
+
+func handleKeyAdd(cmd *cobra.Command, args []string) error {
+	path, out, err := getDocumentPaths(cmd)
+	if err != nil {
+		return err
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+
+	relationships, err := cmd.Flags().GetStringSlice("relationship")
+	if err != nil {
+		return err
+	}
+
+	var vm *core.VerificationMethod
+	var key *core.KeyPair
+	if vmPath, err := cmd.Flags().GetString("vm"); err != nil {
+		return err
+	} else if vmPath != "" {
+		bytes, err := os.ReadFile(vmPath)
+		if err != nil {
+			return err
+		}
+
+		vm = &core.VerificationMethod{}
+		if err := json.Unmarshal(bytes, vm); err != nil {
+			return err
+		}
+	} else {
+		id, err := cmd.Flags().GetString("id")
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			id = nextKeyID(doc)
+		}
+
+		if vm, key, err = generateVerificationMethod(cmd, id, doc.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := doc.AddVerificationMethod(*vm, relationships...); err != nil {
+		return err
+	}
+
+	if key == nil {
+		return writeDocument(cmd, out, doc)
+	}
+
+	// the private key is written only once the document referring to it is validated and written
+	f, err := createPrivateKeyFile(cmd)
+	if err != nil {
+		return err
+	}
+	if err := writeDocument(cmd, out, doc); err != nil {
+		discardPrivateKeyFile(f)
+		return err
+	}
+
+	return writePrivateKey(f, key)
+}
+
+func handleKeyRemove(cmd *cobra.Command, args []string) error {
+	path, out, err := getDocumentPaths(cmd)
+	if err != nil {
+		return err
+	}
+
+	id, err := cmd.Flags().GetString("id")
+	if err != nil {
+		return err
+	}
+
+	replaceWith, err := cmd.Flags().GetString("replace-with")
+	if err != nil {
+		return err
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+
+	if err := doc.RemoveVerificationMethod(id, replaceWith); err != nil {
+		return err
+	}
+
+	return writeDocument(cmd, out, doc)
+}
+
+// generateVerificationMethod generates the key pair with the type and format flags,
+// and returns its verification method.
+func generateVerificationMethod(
+	cmd *cobra.Command, id string, controller string,
+) (*core.VerificationMethod, *core.KeyPair, error) {
+	typStr, err := cmd.Flags().GetString("type")
+	if err != nil {
+		return nil, nil, err
+	}
+	typ, err := core.ParseKeyType(strings.ToLower(typStr))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	formatStr, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := core.ParseKeyFormat(strings.ToLower(formatStr))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := core.GenerateKey(typ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vm := key.VerificationMethod(id, controller, format)
+	return &vm, key, nil
+}
+
+// createPrivateKeyFile creates the file of the private-out flag. It fails if the file exists,
+// so that an existing private key is never overwritten.
+func createPrivateKeyFile(cmd *cobra.Command) (*os.File, error) {
+	path, err := cmd.Flags().GetString("private-out")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("private key file already exists; path = %s", path)
+	}
+	return f, err
+}
+
+// writePrivateKey writes the private key as JWK to the file created with createPrivateKeyFile,
+// and closes it. The file is removed if the key cannot be written.
+func writePrivateKey(f *os.File, key *core.KeyPair) error {
+	bytes, err := json.MarshalIndent(key.PrivateJWK(), "", "  ")
+	if err != nil {
+		discardPrivateKeyFile(f)
+		return err
+	}
+
+	if _, err := f.Write(append(bytes, '\n')); err != nil {
+		discardPrivateKeyFile(f)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// discardPrivateKeyFile closes and removes the file created with createPrivateKeyFile.
+func discardPrivateKeyFile(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// getDocumentPaths returns the didjson flag and the out flag, which defaults to didjson.
+func getDocumentPaths(cmd *cobra.Command) (string, string, error) {
+	path, err := cmd.Flags().GetString("didjson")
+	if err != nil {
+		return "", "", err
+	}
+
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return "", "", err
+	}
+	if out == "" {
+		out = path
+	}
+
+	return path, out, nil
+}
+
+// nextKeyID returns the first unused id in the form of #key-<n>.
+func nextKeyID(doc *core.DIDDocument) string {
+	used := map[string]bool{}
+	for _, vm := range doc.VerificationMethod {
+		used[doc.AbsoluteID(vm.ID)] = true
+	}
+
+	for i := 1; ; i++ {
+		id := fmt.Sprintf("#key-%d", i)
+		if !used[doc.AbsoluteID(id)] {
+			return id
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// addMetricsFlags adds the flag to expose the Prometheus metrics to the server commands.
+func addMetricsFlags(cmd *cobra.Command) {
+	cmd.Flags().String("metrics-addr", "", "Address to serve the Prometheus metrics at /metrics (e.g. :9100)")
+}
+
+// metricsHandler returns the handler of /metrics with the collectors of the package and the process.
+func metricsHandler() (http.Handler, error) {
+	reg := prometheus.NewRegistry()
+	if err := core.RegisterMetrics(reg); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(collectors.NewGoCollector()); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return nil, err
+	}
+
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), nil
+}
+
+// startMetricsServer serves /metrics in the background if --metrics-addr is given.
+// The returned function shuts the server down.
+func startMetricsServer(cmd *cobra.Command) (func() error, error) {
+	addr, err := cmd.Flags().GetString("metrics-addr")
+	if err != nil {
+		return nil, err
+	}
+	if addr == "" {
+		return func() error { return nil }, nil
+	}
+
+	handler, err := metricsHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			core.Logger().Error("metrics server failed", "addr", addr, "error", err)
+		}
+	}()
+
+	return srv.Close, nil
+}
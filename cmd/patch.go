@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// patchCmd represents the patch command
+var patchCmd = &cobra.Command{
+	Use:   "patch",
+	Short: "Apply a JSON Patch or JSON Merge Patch to a DID document",
+	Long: `Apply a JSON Patch (RFC 6902) or JSON Merge Patch (RFC 7396) to a DID document.
+
+The document is read from --didjson, or resolved from --did. The patched
+document is written to --out, and its zone file to --zone-out. The records
+changed from the ones published under the base are written as an nsupdate
+script to --update-out, or sent with the dynamic update with --server. The
+patched document is encoded the way the published one is: compact, with the
+same label encoding, or as the next version.
+
+Nothing is written unless the patched document is valid.`,
+	RunE: handlePatch,
+}
+
+func init() {
+	rootCmd.AddCommand(patchCmd)
+
+	patchCmd.Flags().StringP("patch", "p", "", "Patch file path")
+	patchCmd.Flags().Bool("merge", false, "Treat the patch as JSON Merge Patch instead of JSON Patch")
+	patchCmd.Flags().StringP("didjson", "d", "", "DID document file path")
+	patchCmd.Flags().String("did", "", "DID to resolve the document from")
+	patchCmd.Flags().StringP("out", "o", "", "Output file path of the patched document")
+	addPublishFlags(patchCmd)
+
+	patchCmd.MarkFlagRequired("patch")
+	patchCmd.MarkFlagsMutuallyExclusive("didjson", "did")
+	patchCmd.MarkFlagsOneRequired("didjson", "did")
+}
+
+func handlePatch(cmd *cobra.Command, args []string) error {
+	patchPath, err := cmd.Flags().GetString("patch")
+	if err != nil {
+		return err
+	}
+
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return err
+	}
+
+	merge, err := cmd.Flags().GetBool("merge")
+	if err != nil {
+		return err
+	}
+
+	path, err := cmd.Flags().GetString("didjson")
+	if err != nil {
+		return err
+	}
+
+	did, err := cmd.Flags().GetString("did")
+	if err != nil {
+		return err
+	}
+
+	var old *core.Node
+	if did != "" {
+		if old, _, err = core.ResolveContext(cmd.Context(), did); err != nil {
+			return err
+		}
+
+		// publish to the resolved domain unless the base is given
+		if !cmd.Flags().Changed("basefqdn") {
+			base, err := core.DIDBase(did)
+			if err != nil {
+				return err
+			}
+			cmd.Flags().Set("basefqdn", base)
+		}
+	} else {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if old, err = core.CreateFromJSON(bytes); err != nil {
+			return err
+		}
+	}
+
+	new := old.Clone()
+	if merge {
+		err = new.ApplyMergePatch(patch)
+	} else {
+		err = new.ApplyPatch(patch)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := core.Validate(new, ""); err != nil {
+		return err
+	}
+
+	// the published records are looked up before the patched document is written
+	plan, err := planPublish(cmd, new)
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	if out != "" {
+		bytes, err := new.JSON()
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(out, append(bytes, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Written to %s\n", out)
+	}
+
+	return plan.publish(cmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <did> <expression>",
+	Short: "Evaluate a JSONPath expression against a DID document",
+	Long: `Evaluate a JSONPath expression against the document of the DID, and print
+the matched values as a JSON array.
+
+With the tree encoding, only the records the expression needs are looked up.
+For example, the following looks up the ids of the services without
+resolving the verification methods:
+
+  did-dnssec query did:dnssec:example.com '$.service[*].id'
+
+Filters compare a relative path with a literal:
+
+  did-dnssec query did:dnssec:example.com '$.verificationMethod[?@.type == "Multikey"]'`,
+	RunE: handleQuery,
+	Args: cobra.ExactArgs(2),
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().Bool("paths", false, "Print the paths of the matched values along with the values")
+}
+
+func handleQuery(cmd *cobra.Command, args []string) error {
+	withPaths, err := cmd.Flags().GetBool("paths")
+	if err != nil {
+		return err
+	}
+
+	results, err := core.QueryDIDContext(cmd.Context(), args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	out := []interface{}{}
+	for _, r := range results {
+		v, err := r.Node.Interface()
+		if err != nil {
+			return err
+		}
+
+		if withPaths {
+			out = append(out, map[string]interface{}{"path": r.Path, "value": v})
+		} else {
+			out = append(out, v)
+		}
+	}
+
+	bytes, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+	return nil
+}
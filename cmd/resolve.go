@@ -4,6 +4,9 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -36,7 +39,41 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// resolveCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	resolveCmd.Flags().StringP("out", "o", "", "Output json file path")
+	resolveCmd.Flags().StringP("out", "o", "", "Output file path")
+	resolveCmd.Flags().String("output", "json", "Output format (json|cbor)")
+	resolveCmd.Flags().Bool("metadata", false, "Output the document metadata along with the document (json only)")
+	resolveCmd.Flags().String("trace", "", "Write the trace of the queries to stderr (tree|json)")
+	resolveCmd.Flags().Lookup("trace").NoOptDefVal = "tree"
+}
+
+// withTrace runs the function with the trace collector, and writes the trace to stderr
+// in the format given by the --trace flag, even if the function fails.
+func withTrace(cmd *cobra.Command, f func(ctx context.Context) error) error {
+	format, err := cmd.Flags().GetString("trace")
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		return f(cmd.Context())
+	}
+	if format != "tree" && format != "json" {
+		return fmt.Errorf("invalid trace format; got = %s, expected = tree || json", format)
+	}
+
+	collector := &core.TraceCollector{}
+	fErr := f(core.WithTraceHook(cmd.Context(), collector))
+
+	if format == "json" {
+		bytes, err := collector.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.ErrOrStderr(), string(bytes))
+	} else if err := collector.WriteTree(cmd.ErrOrStderr()); err != nil {
+		return err
+	}
+
+	return fErr
 }
 
 func handleResolve(cmd *cobra.Command, args []string) error {
@@ -45,18 +82,68 @@ func handleResolve(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	node, err := core.Resolve(args[0])
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	format, err := core.ParsePayloadFormat(output)
+	if err != nil {
+		return err
+	}
+
+	withMeta, err := cmd.Flags().GetBool("metadata")
 	if err != nil {
 		return err
 	}
+	if withMeta && format != core.PayloadJSON {
+		return fmt.Errorf("metadata is only available with the json output")
+	}
 
-	node.Print()
+	var node *core.Node
+	var meta *core.DocumentMetadata
+	err = withTrace(cmd, func(ctx context.Context) (err error) {
+		node, meta, err = core.ResolveContext(ctx, args[0])
+		return err
+	})
+	if err != nil && withMeta {
+		// report the error as the DID resolution metadata as well as the exit status
+		bytes, mErr := json.MarshalIndent(map[string]interface{}{
+			"didDocument": nil,
+			"didResolutionMetadata": map[string]string{
+				"error":        core.ErrorCode(err),
+				"errorMessage": err.Error(),
+			},
+		}, "", "  ")
+		if mErr != nil {
+			return mErr
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
+	}
+	if err != nil {
+		return err
+	}
 
-	bytes, err := node.JSON()
+	var bytes []byte
+	if format == core.PayloadCBOR {
+		bytes, err = node.CBOR()
+	} else {
+		bytes, err = node.JSON()
+	}
 	if err != nil {
 		return err
 	}
 
+	if withMeta {
+		bytes, err = json.MarshalIndent(map[string]interface{}{
+			"didDocument":           json.RawMessage(bytes),
+			"didDocumentMetadata":   meta,
+			"didResolutionMetadata": map[string]string{"contentType": "application/did+json"},
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+	}
+
 	if out != "" {
 		f, err := os.Create(out)
 		if err != nil {
@@ -67,8 +154,12 @@ func handleResolve(cmd *cobra.Command, args []string) error {
 		if _, err = f.Write(bytes); err != nil {
 			return err
 		}
+	} else if format == core.PayloadCBOR {
+		if _, err := cmd.OutOrStdout().Write(bytes); err != nil {
+			return err
+		}
 	} else {
-		cmd.Println(string(bytes))
+		fmt.Fprintln(cmd.OutOrStdout(), string(bytes))
 	}
 
 	return nil
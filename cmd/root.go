@@ -1,16 +1,39 @@
 package cmd
 
 import (
+	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "did-dnssec",
 	Short: "A cli-based client for did:dnssec",
-	Long:  `did-dnssec is a cli-based client for did:dnssec.`,
+	Long: `did-dnssec is a cli-based client for did:dnssec.
+
+The results are written to stdout, and the logs to stderr. Only the warnings
+and errors are logged unless --verbose is given.
+
+With --strict, the resolution fails if an owner name has conflicting records,
+such as several valid records left by a half-finished update. Otherwise they
+are logged as warnings.
+
+With --require-dnssec, the resolution fails unless every answer, including the
+ones along the chain of the CNAME and DNAME aliases, is validated as secure by
+the resolver.`,
+	PersistentPreRunE: setup,
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Log the lookups and updates at the debug level")
+	rootCmd.PersistentFlags().String("log-format", "text", "Log format (text|json)")
+	rootCmd.PersistentFlags().Bool("strict", false, "Fail on the conflicting records at an owner name")
+	rootCmd.PersistentFlags().Bool("require-dnssec", false, "Fail unless every answer is validated as secure with DNSSEC")
+	rootCmd.PersistentFlags().StringSlice("nameserver", nil, "Resolvers (host:port) to query instead of the ones in resolv.conf")
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -21,3 +44,59 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func setup(cmd *cobra.Command, args []string) error {
+	if err := setupLogger(cmd); err != nil {
+		return err
+	}
+
+	strict, err := cmd.Flags().GetBool("strict")
+	if err != nil {
+		return err
+	}
+	core.SetStrict(strict)
+
+	requireDNSSEC, err := cmd.Flags().GetBool("require-dnssec")
+	if err != nil {
+		return err
+	}
+	core.SetRequireDNSSEC(requireDNSSEC)
+
+	nameservers, err := cmd.Flags().GetStringSlice("nameserver")
+	if err != nil {
+		return err
+	}
+	core.SetNameservers(nameservers)
+
+	return nil
+}
+
+func setupLogger(cmd *cobra.Command) error {
+	verbose, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		return err
+	}
+
+	format, err := cmd.Flags().GetString("log-format")
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: slog.LevelWarn}
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(cmd.ErrOrStderr(), opts)
+	case "json":
+		handler = slog.NewJSONHandler(cmd.ErrOrStderr(), opts)
+	default:
+		return fmt.Errorf("invalid log format; got = %s, expected = text || json", format)
+	}
+
+	core.SetLogger(slog.New(handler))
+	return nil
+}
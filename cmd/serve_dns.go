@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// signatureValidity is the validity period of the signatures of the served zone.
+// The zone is re-signed when half of it has passed.
+const signatureValidity = 7 * 24 * time.Hour
+
+var serveDNSCmd = &cobra.Command{
+	Use:   "serve-dns",
+	Short: "Serve the records of DID documents as an authoritative nameserver",
+	Long: `Serve the records of DID documents in the tree encoding as the authoritative
+nameserver of the zone, over both UDP and TCP. Each document is published under
+the base of its id, which must be in the zone. The SOA and NS records of the zone
+are synthesized.
+
+With --dnssec or --dnssec-key, the zone is signed online, and the nonexistence of
+the names is proven with NSEC3. With --dnssec, an ephemeral key is generated, and
+its DS record is written to stderr.
+
+The documents are reloaded when the files change, and the zone is swapped atomically.
+If a changed document is invalid, the previous zone is kept being served, and
+the documents are reloaded again until they are fixed.
+
+With --store, the documents are instead managed by the tenants with the hosting
+API served at --api-addr, which also serves /metrics:
+
+  GET    /dids/<did>  returns the document.
+  PUT    /dids/<did>  creates or replaces the document.
+  PATCH  /dids/<did>  applies a JSON Patch (application/json-patch+json)
+                      or JSON Merge Patch (application/merge-patch+json).
+  DELETE /dids/<did>  deletes the document.
+
+Every written document is validated, stored in the directory, and served at once.
+The requests are authenticated with the bearer token of the tenant, or signed
+with its key; each signed request is accepted only once. The API is served over
+TLS with --api-tls-cert and --api-tls-key, which should be used unless a proxy
+terminates TLS in front of it, since the bearer tokens are sent in the clear
+otherwise. The tenants file is a JSON array of the tenants:
+
+  [{"name": "alice", "tokenSha256": "<hex>", "domains": ["example.com"]},
+   {"name": "bob", "publicKeyJwk": {"kty": "OKP", ...}, "domains": ["example.org"]}]
+
+The served records can be resolved with the --nameserver flag, for example:
+
+  did-dnssec serve-dns --zone example.com. --doc did.json --addr 127.0.0.1:5353
+  did-dnssec resolve --nameserver 127.0.0.1:5353 did:dnssec:example.com`,
+	RunE: handleServeDNS,
+}
+
+func init() {
+	rootCmd.AddCommand(serveDNSCmd)
+
+	serveDNSCmd.Flags().String("zone", "", "Origin of the zone (e.g. example.com.)")
+	serveDNSCmd.Flags().StringSlice("doc", nil, "DID document file paths")
+	serveDNSCmd.Flags().String("addr", ":53", "Address to serve the zone")
+	serveDNSCmd.Flags().String("labels", "base64", "Label encoding of the map keys (base64|base32)")
+	serveDNSCmd.Flags().StringSlice("ns", nil, "Nameservers of the zone (default: ns.<zone>)")
+	serveDNSCmd.Flags().Bool("no-validate", false, "Skip the DID Core conformance validation of the documents")
+	serveDNSCmd.Flags().Bool("dnssec", false, "Sign the zone with an ephemeral key")
+	serveDNSCmd.Flags().String("dnssec-key", "", "Path of the BIND key files to sign the zone without the extension (e.g. Kexample.com.+013+12345)")
+	serveDNSCmd.Flags().Uint16("nsec3-iterations", 0, "Additional iterations of the NSEC3 hash")
+	serveDNSCmd.Flags().String("nsec3-salt", "", "Salt of the NSEC3 hash in hex")
+	serveDNSCmd.Flags().Duration("reload-interval", 2*time.Second, "Interval to check the documents for changes")
+	serveDNSCmd.Flags().String("store", "", "Directory of the documents managed with the hosting API")
+	serveDNSCmd.Flags().String("tenants", "", "Tenants file of the hosting API")
+	serveDNSCmd.Flags().String("api-addr", "", "Address to serve the hosting API and /metrics (e.g. :8080)")
+	serveDNSCmd.Flags().String("api-tls-cert", "", "Certificate file to serve the hosting API over TLS")
+	serveDNSCmd.Flags().String("api-tls-key", "", "Private key file of the certificate of the hosting API")
+	addMetricsFlags(serveDNSCmd)
+
+	serveDNSCmd.MarkFlagRequired("zone")
+	serveDNSCmd.MarkFlagsOneRequired("doc", "store")
+	serveDNSCmd.MarkFlagsMutuallyExclusive("doc", "store")
+	serveDNSCmd.MarkFlagsRequiredTogether("store", "tenants", "api-addr")
+	serveDNSCmd.MarkFlagsRequiredTogether("api-tls-cert", "api-tls-key")
+	serveDNSCmd.MarkFlagsMutuallyExclusive("dnssec", "dnssec-key")
+}
+
+// zoneBuilder builds the zone from the documents with the flags of the server commands.
+type zoneBuilder struct {
+	origin     string
+	labels     core.LabelEncoding
+	noValidate bool
+	opts       core.ZoneOptions
+}
+
+// newZoneBuilder reads the flags of the zone.
+func newZoneBuilder(cmd *cobra.Command) (*zoneBuilder, error) {
+	b := &zoneBuilder{}
+
+	origin, err := cmd.Flags().GetString("zone")
+	if err != nil {
+		return nil, err
+	}
+	if b.origin, err = core.NormalizeDomain(origin); err != nil {
+		return nil, fmt.Errorf("zone is not a valid FQDN: %w", err)
+	}
+
+	if b.labels, err = getLabelEncoding(cmd); err != nil {
+		return nil, err
+	}
+
+	if b.noValidate, err = cmd.Flags().GetBool("no-validate"); err != nil {
+		return nil, err
+	}
+
+	if b.opts.NS, err = cmd.Flags().GetStringSlice("ns"); err != nil {
+		return nil, err
+	}
+
+	ephemeral, err := cmd.Flags().GetBool("dnssec")
+	if err != nil {
+		return nil, err
+	}
+	keyPath, err := cmd.Flags().GetString("dnssec-key")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ephemeral:
+		if b.opts.Key, b.opts.Signer, err = core.GenerateZoneKey(b.origin); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Signing with the ephemeral key; DS record:\n%s\n", b.opts.Key.ToDS(dns.SHA256))
+	case keyPath != "":
+		if b.opts.Key, b.opts.Signer, err = core.ReadZoneKey(keyPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.opts.NSEC3Iterations, err = cmd.Flags().GetUint16("nsec3-iterations"); err != nil {
+		return nil, err
+	}
+	if b.opts.NSEC3Salt, err = cmd.Flags().GetString("nsec3-salt"); err != nil {
+		return nil, err
+	}
+	if _, err := hex.DecodeString(b.opts.NSEC3Salt); err != nil || len(b.opts.NSEC3Salt) > 510 {
+		return nil, fmt.Errorf("nsec3-salt must be in hex up to 255 bytes; got = %s", b.opts.NSEC3Salt)
+	}
+	b.opts.Validity = signatureValidity
+
+	return b, nil
+}
+
+// build validates the documents, and builds the zone of their records.
+// The docs argument maps the base names of the documents to them.
+func (b *zoneBuilder) build(docs map[string]*core.Node) (*core.Zone, error) {
+	rrs := []*core.ResorceRecord{}
+	for base, doc := range docs {
+		if !b.noValidate {
+			if err := core.Validate(doc, base); err != nil {
+				return nil, err
+			}
+		}
+
+		r, err := doc.TreeRRs(base, b.labels)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, r...)
+	}
+
+	return core.NewZone(b.origin, rrs, b.opts)
+}
+
+// readDocumentNode reads the DID document, and returns it with the base of its id.
+func readDocumentNode(path string) (string, *core.Node, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	doc, err := core.CreateFromJSON(bytes)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	id := doc.GetChildValue("id")
+	if id == nil || id.Type != core.ValTypeString {
+		return "", nil, fmt.Errorf("%s: id is required", path)
+	}
+	base, err := core.DIDBase(id.String())
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return base, doc, nil
+}
+
+// load reads the documents, and builds the zone of their records.
+func (b *zoneBuilder) load(paths []string) (*core.Zone, error) {
+	docs := map[string]*core.Node{}
+	for _, path := range paths {
+		base, doc, err := readDocumentNode(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := docs[base]; ok {
+			return nil, fmt.Errorf("%s: duplicated document; base = %s", path, base)
+		}
+		docs[base] = doc
+	}
+
+	return b.build(docs)
+}
+
+func handleServeDNS(cmd *cobra.Command, args []string) error {
+	paths, err := cmd.Flags().GetStringSlice("doc")
+	if err != nil {
+		return err
+	}
+
+	storeDir, err := cmd.Flags().GetString("store")
+	if err != nil {
+		return err
+	}
+
+	addr, err := cmd.Flags().GetString("addr")
+	if err != nil {
+		return err
+	}
+
+	interval, err := cmd.Flags().GetDuration("reload-interval")
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("reload-interval must be positive")
+	}
+
+	b, err := newZoneBuilder(cmd)
+	if err != nil {
+		return err
+	}
+
+	stopMetrics, err := startMetricsServer(cmd)
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the documents are either read from the files, or managed with the hosting API
+	var srv *core.Server
+	var changed func() bool
+	var reload func() error
+	if storeDir != "" {
+		h, err := startHostingAPI(ctx, cmd, storeDir, b)
+		if err != nil {
+			return err
+		}
+		srv = h.Server()
+		changed = func() bool { return false }
+		reload = h.Rebuild
+	} else {
+		mtimes := modTimes(paths)
+		zone, err := b.load(paths)
+		if err != nil {
+			return err
+		}
+		srv = core.NewServer(zone)
+
+		// the modification times are recorded only when the zone is reloaded, so that
+		// a failed reload is retried until the documents are fixed
+		changed = func() bool {
+			return !equalModTimes(modTimes(paths), mtimes)
+		}
+		reload = func() error {
+			current := modTimes(paths)
+			zone, err := b.load(paths)
+			if err != nil {
+				return err
+			}
+			srv.SetZone(zone)
+			mtimes = current
+			return nil
+		}
+	}
+
+	go func() {
+		signed := time.Now()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// the zone is rebuilt when the documents change, or the signatures are going to expire
+			resign := b.opts.Key != nil && time.Since(signed) > signatureValidity/2
+			if !changed() && !resign {
+				continue
+			}
+
+			if err := reload(); err != nil {
+				core.Logger().Error("failed to reload the documents; serving the previous zone", "error", err)
+				continue
+			}
+			signed = time.Now()
+			core.Logger().Info("reloaded the zone", "zone", b.origin)
+		}
+	}()
+
+	return srv.ListenAndServe(ctx, addr)
+}
+
+// startHostingAPI serves the hosting API of the documents in the store at --api-addr until the context is done,
+// along with /metrics.
+func startHostingAPI(ctx context.Context, cmd *cobra.Command, storeDir string, b *zoneBuilder) (*core.Hosting, error) {
+	apiAddr, err := cmd.Flags().GetString("api-addr")
+	if err != nil {
+		return nil, err
+	}
+
+	tenantsPath, err := cmd.Flags().GetString("tenants")
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := core.LoadTenants(tenantsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := core.OpenStore(storeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := core.NewHosting(store, tenants, b.build)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := metricsHandler()
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/dids/", h)
+	mux.Handle("/metrics", metrics)
+	srv := &http.Server{Addr: apiAddr, Handler: mux}
+
+	certPath, err := cmd.Flags().GetString("api-tls-cert")
+	if err != nil {
+		return nil, err
+	}
+	keyPath, err := cmd.Flags().GetString("api-tls-key")
+	if err != nil {
+		return nil, err
+	}
+
+	// the listener is bound here, so that the errors are returned rather than logged in the background
+	l, err := net.Listen("tcp", apiAddr)
+	if err != nil {
+		return nil, err
+	}
+	if certPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		l = tls.NewListener(l, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	} else {
+		core.Logger().Warn("serving hosting api without tls", "addr", apiAddr)
+	}
+
+	go func() {
+		core.Logger().Info("serving hosting api", "addr", l.Addr().String(), "tls", certPath != "")
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			core.Logger().Error("hosting api failed", "addr", apiAddr, "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	return h, nil
+}
+
+// modTimes returns the modification times of the files, which are zero for the files not available.
+func modTimes(paths []string) []time.Time {
+	times := make([]time.Time, len(paths))
+	for i, path := range paths {
+		if fi, err := os.Stat(path); err == nil {
+			times[i] = fi.ModTime()
+		}
+	}
+
+	return times
+}
+
+func equalModTimes(a, b []time.Time) bool {
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// serviceCmd represents the service command
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage the services of a DID document",
+	Long: `Manage the services of a DID document.
+
+The commands editing the document can also regenerate the zone file with
+--zone-out, or publish the changed records directly with --server.`,
+}
+
+// serviceAddCmd represents the service add command
+var serviceAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a service to a DID document",
+	Long: `Add a service to a DID document.
+
+The endpoint is a URI, or a JSON map or set (e.g. '["https://a.example", {"uri": "..."}]').`,
+	RunE: handleServiceAdd,
+}
+
+// serviceUpdateCmd represents the service update command
+var serviceUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update a service of a DID document",
+	Long:  `Update the type or endpoint of a service of a DID document.`,
+	RunE:  handleServiceUpdate,
+}
+
+// serviceRemoveCmd represents the service remove command
+var serviceRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove a service from a DID document",
+	Long:  `Remove a service from a DID document.`,
+	RunE:  handleServiceRemove,
+}
+
+// serviceListCmd represents the service list command
+var serviceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the services of a DID document",
+	Long:  `List the services of a DID document.`,
+	RunE:  handleServiceList,
+}
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.AddCommand(serviceAddCmd, serviceUpdateCmd, serviceRemoveCmd, serviceListCmd)
+
+	for _, c := range []*cobra.Command{serviceAddCmd, serviceUpdateCmd, serviceRemoveCmd} {
+		c.Flags().StringP("didjson", "d", "", "DID document file path")
+		c.Flags().StringP("out", "o", "", "Output file path (default: overwrite didjson)")
+		c.Flags().String("id", "", "Service id (e.g. #atproto_pds)")
+		addPublishFlags(c)
+
+		c.MarkFlagRequired("didjson")
+		c.MarkFlagRequired("id")
+	}
+
+	for _, c := range []*cobra.Command{serviceAddCmd, serviceUpdateCmd} {
+		c.Flags().StringSliceP("type", "t", nil, "Service types")
+		c.Flags().StringP("endpoint", "e", "", "Service endpoint (URI, JSON map or JSON set)")
+	}
+	serviceAddCmd.MarkFlagRequired("type")
+	serviceAddCmd.MarkFlagRequired("endpoint")
+
+	serviceListCmd.Flags().StringP("didjson", "d", "", "DID document file path")
+	serviceListCmd.MarkFlagRequired("didjson")
+}
+
+func handleServiceAdd(cmd *cobra.Command, args []string) error {
+	return editServices(cmd, func(doc *core.DIDDocument, id string) error {
+		s := core.Service{ID: id}
+		if err := applyServiceFlags(cmd, &s); err != nil {
+			return err
+		}
+
+		return doc.AddService(s)
+	})
+}
+
+func handleServiceUpdate(cmd *cobra.Command, args []string) error {
+	return editServices(cmd, func(doc *core.DIDDocument, id string) error {
+		i := doc.FindService(id)
+		if i == -1 {
+			return fmt.Errorf("service not found; id = %s", id)
+		}
+
+		return applyServiceFlags(cmd, &doc.Service[i])
+	})
+}
+
+func handleServiceRemove(cmd *cobra.Command, args []string) error {
+	return editServices(cmd, func(doc *core.DIDDocument, id string) error {
+		return doc.RemoveService(id)
+	})
+}
+
+func handleServiceList(cmd *cobra.Command, args []string) error {
+	path, err := cmd.Flags().GetString("didjson")
+	if err != nil {
+		return err
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range doc.Service {
+		endpoint, err := json.Marshal(s.ServiceEndpoint)
+		if err != nil {
+			return err
+		}
+
+		typ, err := json.Marshal(s.Type)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", s.ID, typ, endpoint)
+	}
+
+	return nil
+}
+
+// editServices reads the document, edits it with the function, and writes and publishes the result.
+// Nothing is written unless the result is valid.
+func editServices(cmd *cobra.Command, edit func(doc *core.DIDDocument, id string) error) error {
+	path, out, err := getDocumentPaths(cmd)
+	if err != nil {
+		return err
+	}
+
+	id, err := cmd.Flags().GetString("id")
+	if err != nil {
+		return err
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+
+	if err := edit(doc, id); err != nil {
+		return err
+	}
+
+	new, err := doc.Node()
+	if err != nil {
+		return err
+	}
+
+	// the document is validated and the published records are looked up before the file is written
+	plan, err := planPublish(cmd, new)
+	if err != nil {
+		return err
+	}
+
+	if err := writeDocument(cmd, out, doc); err != nil {
+		return err
+	}
+
+	return plan.publish(cmd)
+}
+
+// applyServiceFlags sets the type and endpoint flags to the service if they are given.
+func applyServiceFlags(cmd *cobra.Command, s *core.Service) error {
+	if cmd.Flags().Changed("type") {
+		types, err := cmd.Flags().GetStringSlice("type")
+		if err != nil {
+			return err
+		}
+		s.Type = core.StringOrSetOf(types...)
+	}
+
+	if cmd.Flags().Changed("endpoint") {
+		endpointStr, err := cmd.Flags().GetString("endpoint")
+		if err != nil {
+			return err
+		}
+
+		endpoint, err := core.ParseServiceEndpoint(endpointStr)
+		if err != nil {
+			return err
+		}
+		s.ServiceEndpoint = endpoint
+	}
+
+	return nil
+}
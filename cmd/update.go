@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	core "github.com/yum45f/did-dnssec/pkg"
+)
+
+// addUpdateFlags adds the flags to send the dynamic update to the command.
+func addUpdateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("server", "", "Primary server to send the dynamic update (e.g. ns1.example.com:53)")
+	cmd.Flags().String("zone", "", "Zone to be updated (e.g. example.com.)")
+	cmd.Flags().String("tsig-name", "", "TSIG key name")
+	cmd.Flags().String("tsig-secret", "", "TSIG secret (base64)")
+	cmd.Flags().String("tsig-algorithm", "hmac-sha256.", "TSIG algorithm")
+}
+
+func getUpdateConfig(cmd *cobra.Command) (core.UpdateConfig, error) {
+	cfg := core.UpdateConfig{}
+
+	for flag, dst := range map[string]*string{
+		"server":         &cfg.Server,
+		"zone":           &cfg.Zone,
+		"tsig-name":      &cfg.TSIGName,
+		"tsig-secret":    &cfg.TSIGSecret,
+		"tsig-algorithm": &cfg.TSIGAlgorithm,
+	} {
+		v, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return cfg, err
+		}
+		*dst = v
+	}
+
+	if cfg.Server == "" {
+		return cfg, fmt.Errorf("server is required")
+	}
+	if cfg.Zone == "" {
+		return cfg, fmt.Errorf("zone is required")
+	}
+	if cfg.TSIGName != "" && cfg.TSIGSecret == "" {
+		return cfg, fmt.Errorf("tsig-secret is required with tsig-name")
+	}
+
+	return cfg, nil
+}
+
+// addPublishFlags adds the flags to regenerate the zone file or publish the change of the document.
+func addPublishFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("basefqdn", "b", "", "Base FQDN to publish the document (e.g. example.com.)")
+	cmd.Flags().String("zone-out", "", "Output zone file path of the updated document")
+	cmd.Flags().String("update-out", "", "Output nsupdate script path of the changed records")
+	cmd.Flags().String("labels", "base64", "Label encoding of the map keys (base64|base32)")
+	addUpdateFlags(cmd)
+}
+
+func getLabelEncoding(cmd *cobra.Command) (core.LabelEncoding, error) {
+	labels, err := cmd.Flags().GetString("labels")
+	if err != nil {
+		return core.LabelBase64, err
+	}
+
+	return core.ParseLabelEncoding(labels)
+}
+
+// publishPlan is the records of the new document to publish, and the update from the records
+// published in the DNS. It is made before anything is written, so that a document which fails
+// the validation changes no file.
+type publishPlan struct {
+	zoneOut   string
+	updateOut string
+	server    string
+	cfg       core.UpdateConfig
+	rrs       []*core.ResorceRecord
+	update    *core.Update
+}
+
+// planPublish validates the new document under the base, and makes the plan to publish it as
+// requested by the publish flags. It returns nil if none of them is given.
+// The flags to send the update are checked here too, so that they fail before anything is written.
+//
+// To write or send the update, the records published under the base are looked up, and the update
+// is made against them rather than the old document. The new document is encoded in the way they
+// are: in the compact encoding with the same options, in the same label encoding unless --labels is
+// given, and as the next version archiving the current one if they are versioned.
+// The zone file alone is written in the tree encoding without the lookup.
+func planPublish(cmd *cobra.Command, new *core.Node) (*publishPlan, error) {
+	p := &publishPlan{}
+	for flag, dst := range map[string]*string{
+		"zone-out":   &p.zoneOut,
+		"update-out": &p.updateOut,
+		"server":     &p.server,
+	} {
+		v, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return nil, err
+		}
+		*dst = v
+	}
+
+	if p.zoneOut == "" && p.updateOut == "" && p.server == "" {
+		return nil, nil
+	}
+
+	if p.server != "" {
+		cfg, err := getUpdateConfig(cmd)
+		if err != nil {
+			return nil, err
+		}
+		p.cfg = cfg
+	}
+
+	base, err := cmd.Flags().GetString("basefqdn")
+	if err != nil {
+		return nil, err
+	}
+	if base == "" {
+		return nil, fmt.Errorf("basefqdn is required to publish the document")
+	}
+	if base, err = core.NormalizeDomain(base); err != nil {
+		return nil, fmt.Errorf("basefqdn is not a valid FQDN: %w", err)
+	}
+
+	if err := core.Validate(new, base); err != nil {
+		return nil, err
+	}
+
+	labels, err := getLabelEncoding(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.updateOut == "" && p.server == "" {
+		p.rrs, err = new.TreeRRs(base, labels)
+		return p, err
+	}
+
+	published, err := core.LookupPublished(cmd.Context(), base)
+	if errors.Is(err, core.ErrNotFound) {
+		published = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to look up the published records of %s: %w", base, err)
+	} else if published.Location != "" {
+		return nil, fmt.Errorf("records are delegated to %s; publish them to the host as the basefqdn", published.Location)
+	}
+
+	if p.rrs, err = encodeAsPublished(new, base, labels, cmd.Flags().Changed("labels"), published); err != nil {
+		return nil, err
+	}
+
+	old := []*core.ResorceRecord{}
+	if published != nil {
+		old = append(append(old, published.RRs...), published.Archived...)
+	}
+	p.update = core.DiffRRs(old, p.rrs)
+
+	return p, nil
+}
+
+// encodeAsPublished returns the records of the document encoded in the way the published one is.
+// The labels argument is used if nothing is published, or if forced.
+func encodeAsPublished(
+	doc *core.Node, base string, labels core.LabelEncoding, forceLabels bool, published *core.Published,
+) ([]*core.ResorceRecord, error) {
+	if published == nil {
+		return doc.TreeRRs(base, labels)
+	}
+
+	var rrs []*core.ResorceRecord
+	var err error
+	switch {
+	case published.Compact:
+		rrs, err = doc.CompactRRs(base, published.CompactOptions)
+	case forceLabels:
+		rrs, err = doc.TreeRRs(base, labels)
+	default:
+		rrs, err = doc.TreeRRs(base, published.Labels)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if v := published.Version; v != nil {
+		rrs = append(rrs, core.Version{ID: v.ID + 1, Time: time.Now()}.RR(fmt.Sprintf("_did.%s", base)))
+		rrs = append(rrs, core.ArchiveRRs(published.RRs, base, v.ID)...)
+		rrs = append(rrs, published.Archived...)
+	}
+
+	return rrs, nil
+}
+
+// publish writes the zone file and the update, and sends the update, as planned.
+func (p *publishPlan) publish(cmd *cobra.Command) error {
+	if p == nil {
+		return nil
+	}
+
+	if p.zoneOut != "" {
+		f, err := os.Create(p.zoneOut)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := core.WriteRRs(f, p.rrs); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Dumped to %s\n", p.zoneOut)
+	}
+
+	if p.updateOut != "" {
+		cfg := core.UpdateConfig{}
+		cfg.Server, _ = cmd.Flags().GetString("server")
+		cfg.Zone, _ = cmd.Flags().GetString("zone")
+
+		if err := os.WriteFile(p.updateOut, []byte(p.update.Script(cfg)), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Dumped the update to %s\n", p.updateOut)
+	}
+
+	if p.server != "" {
+		if p.update.Empty() {
+			fmt.Fprintln(cmd.ErrOrStderr(), "No change to publish")
+			return nil
+		}
+
+		if err := p.update.Send(p.cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Published %d deletions and %d additions to %s\n", len(p.update.Delete), len(p.update.Add), p.cfg.Server)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// memoryBackend answers the queries from the zones in memory in place of the nameservers,
+// with the zone of the longest origin the name is under.
+type memoryBackend struct {
+	zones []*Zone
+	// secure is the origins of the zones whose answers are validated as secure (the AD bit).
+	secure  map[string]bool
+	queries atomic.Int64
+}
+
+func (b *memoryBackend) exchange(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	b.queries.Add(1)
+
+	var zone *Zone
+	for _, z := range b.zones {
+		if dns.IsSubDomain(z.origin, strings.ToLower(msg.Question[0].Name)) && (zone == nil || len(z.origin) > len(zone.origin)) {
+			zone = z
+		}
+	}
+	if zone == nil {
+		m := &dns.Msg{}
+		return m.SetRcode(msg, dns.RcodeRefused), nil
+	}
+
+	m := zone.answer(msg)
+	m.AuthenticatedData = b.secure[zone.origin]
+	return m, nil
+}
+
+// useBackend makes the lookups answered from the zones until the test ends.
+func useBackend(t testing.TB, zones ...*Zone) *memoryBackend {
+	t.Helper()
+
+	b := &memoryBackend{zones: zones, secure: map[string]bool{}}
+	exchange = b.exchange
+	SetNameservers([]string{"memory:53"})
+	t.Cleanup(func() {
+		exchange = exchangeNet
+		SetNameservers(nil)
+	})
+
+	return b
+}
+
+// useLimits sets the limits until the test ends.
+func useLimits(t testing.TB, l Limits) {
+	t.Helper()
+
+	SetLimits(l)
+	t.Cleanup(func() { SetLimits(DefaultLimits) })
+}
+
+// testZone builds the unsigned zone of the origin from the records.
+func testZone(t testing.TB, origin string, rrs ...*ResorceRecord) *Zone {
+	t.Helper()
+
+	z, err := NewZone(origin, rrs, ZoneOptions{})
+	if err != nil {
+		t.Fatalf("NewZone() error = %v", err)
+	}
+	return z
+}
+
+// testDocument parses the JSON document.
+func testDocument(t testing.TB, doc string) *Node {
+	t.Helper()
+
+	n, err := CreateFromJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("CreateFromJSON() error = %v", err)
+	}
+	return n
+}
+
+// txtRR returns the TXT record with the content.
+func txtRR(name string, content string) *ResorceRecord {
+	return &ResorceRecord{Name: dns.Fqdn(name), Class: "IN", Type: "TXT", TTL: 3600, Data: zoneData(content)}
+}
+
+// aliasRR returns the CNAME or DNAME record to the target.
+func aliasRR(typ string, name string, target string) *ResorceRecord {
+	return &ResorceRecord{Name: dns.Fqdn(name), Class: "IN", Type: typ, TTL: 3600, Data: dns.Fqdn(target)}
+}
+
+func TestResolveMemoryBackend(t *testing.T) {
+	doc := testDocument(t, `{"id": "did:dnssec:example.com", "service": [{"id": "#a", "type": "A"}], "n": 1.5}`)
+	useBackend(t, testZone(t, "example.com.", doc.RRs("example.com.")...))
+
+	got, err := Resolve("did:dnssec:example.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	want, _ := doc.JSON()
+	if b, _ := got.JSON(); string(b) != string(want) {
+		t.Errorf("Resolve() = %s, want %s", b, want)
+	}
+}
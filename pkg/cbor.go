@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// PayloadFormat is the serialization format of the document in the compact encoding.
+type PayloadFormat int
+
+const (
+	PayloadJSON PayloadFormat = iota
+	PayloadCBOR
+)
+
+func (p PayloadFormat) String() string {
+	return [...]string{"json", "cbor"}[p]
+}
+
+// ParsePayloadFormat parses the name of the payload format, "json" or "cbor".
+func ParsePayloadFormat(s string) (PayloadFormat, error) {
+	switch s {
+	case "json":
+		return PayloadJSON, nil
+	case "cbor":
+		return PayloadCBOR, nil
+	default:
+		return PayloadJSON, fmt.Errorf("invalid payload format; got = %s, expected = json || cbor", s)
+	}
+}
+
+// cborEncMode encodes in the deterministic DAG-CBOR flavour:
+// map keys are sorted length-first and floats are always encoded in 64 bits.
+var cborEncMode = func() cbor.EncMode {
+	em, err := cbor.EncOptions{
+		Sort:          cbor.SortCanonical,
+		ShortestFloat: cbor.ShortestFloatNone,
+		NaNConvert:    cbor.NaNConvertReject,
+		InfConvert:    cbor.InfConvertReject,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+var cborDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+		DupMapKey:      cbor.DupMapKeyEnforcedAPF,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+// CBOR returns the CBOR (RFC 8949) representation of the node.
+// The primitives are packed natively: the integral numbers are encoded as the integers,
+// and the others keep their own major types instead of the `type=value` strings.
+func (n *Node) CBOR() ([]byte, error) {
+	v, err := nodeToCBORValue(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return cborEncMode.Marshal(v)
+}
+
+// CreateFromCBOR creates the document tree from the CBOR-encoded map.
+func CreateFromCBOR(bytes []byte) (*Node, error) {
+	var mapData map[string]interface{}
+	if err := cborDecMode.Unmarshal(bytes, &mapData); err != nil {
+		return nil, err
+	}
+
+	v, err := normalizeCBORValue(mapData)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := mapToNode("root", nil, v.(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	tree.Key = ""
+	return tree, nil
+}
+
+func nodeToCBORValue(n *Node) (interface{}, error) {
+	switch n.Value.Type {
+	case ValTypeMap:
+		m := map[string]interface{}{}
+		for _, child := range *n.Children {
+			v, err := nodeToCBORValue(child)
+			if err != nil {
+				return nil, err
+			}
+			m[child.Key] = v
+		}
+		return m, nil
+
+	case ValTypeArray:
+		s := []interface{}{}
+		for _, child := range *n.Children {
+			v, err := nodeToCBORValue(child)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+
+	case ValTypeFloat:
+		f := n.Value.Float()
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return f, nil
+
+	default:
+		return n.Value.value, nil
+	}
+}
+
+// normalizeCBORValue converts the decoded integers into the types accepted by mapToNode.
+func normalizeCBORValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			ne, err := normalizeCBORValue(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = ne
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, e := range v {
+			ne, err := normalizeCBORValue(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = ne
+		}
+		return v, nil
+
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("integer overflows; got = %d", v)
+		}
+		return int(v), nil
+
+	case int64:
+		return int(v), nil
+
+	case float32:
+		return float64(v), nil
+
+	case float64, string, bool:
+		return v, nil
+
+	default:
+		return nil, fmt.Errorf("invalid primitive type; value = %v, type = %v", v, reflect.TypeOf(v))
+	}
+}
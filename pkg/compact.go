@@ -0,0 +1,246 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// compactVersion is the record version of the compact encoding.
+// The compact encoding stores the whole document in the TXT records of `_did.<base>`,
+// so the document can be resolved with a single lookup.
+const compactVersion = "did:dnssec2"
+
+// compactChunkSize is the length of the data in a chunk record.
+// It keeps each record within a single character-string (255 octets).
+const compactChunkSize = 200
+
+// CompactOptions is the options for the compact encoding.
+type CompactOptions struct {
+	// Compress compresses the payload with DEFLATE (RFC 1951) before splitting it into chunks.
+	Compress bool
+	// Payload is the serialization format of the document.
+	Payload PayloadFormat
+}
+
+// CompactRRs returns the resource records of the node in the compact encoding.
+// The base argument is the base domain name of the node, and must be ended with a dot(root).
+//
+// The document is serialized with JCS (RFC 8785) or CBOR, optionally compressed, base64url encoded and
+// split into the numbered chunk records. All records are published under `_did.<base>`:
+//
+//	`v=did:dnssec2; t=h; n=<count>; z=<compression>; e=<payload>`
+//	`v=did:dnssec2; t=c; i=<index>; d=<data>`
+//	- count: the number of the chunk records.
+//	- compression: "none" or "deflate".
+//	- payload: "json" or "cbor". The records without this tag are treated as "json".
+//	- index: the zero-based index of the chunk.
+//	- data: the base64url encoded part of the payload.
+func (n *Node) CompactRRs(base string, opts CompactOptions) ([]*ResorceRecord, error) {
+	if n.Value.Type != ValTypeMap {
+		return nil, fmt.Errorf("invalid node type for document; typ = %s", n.Value.Type.String())
+	}
+
+	var payload []byte
+	var err error
+	if opts.Payload == PayloadCBOR {
+		payload, err = n.CBOR()
+	} else {
+		payload, err = n.CanonicalJSON()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	compression := "none"
+	if opts.Compress {
+		compression = "deflate"
+		buf := &bytes.Buffer{}
+		w, err := flate.NewWriter(buf, flate.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+	}
+
+	data := base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(payload)
+	chunks := []string{}
+	for len(data) > compactChunkSize {
+		chunks = append(chunks, data[:compactChunkSize])
+		data = data[compactChunkSize:]
+	}
+	chunks = append(chunks, data)
+
+	recName := fmt.Sprintf("_did.%s", base)
+	rrs := []*ResorceRecord{{
+		Name:  recName,
+		Class: "IN",
+		Type:  "TXT",
+		TTL:   3600,
+		Data: zoneData(formatRecord(
+			compactVersion, "t", "h", "n", strconv.Itoa(len(chunks)), "z", compression, "e", opts.Payload.String(),
+		)),
+	}}
+
+	for i, chunk := range chunks {
+		rrs = append(rrs, &ResorceRecord{
+			Name:  recName,
+			Class: "IN",
+			Type:  "TXT",
+			TTL:   3600,
+			Data:  zoneData(formatRecord(compactVersion, "t", "c", "i", strconv.Itoa(i), "d", chunk)),
+		})
+	}
+
+	return rrs, nil
+}
+
+// isCompact reports whether the TXT records are published in the compact encoding.
+func isCompact(txt []string) bool {
+	for _, v := range txt {
+		if mapping, err := parseTagList(v); err == nil && mapping["v"] == compactVersion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compactOptions returns the options which the TXT records of the compact encoding are encoded with.
+func compactOptions(txt []string) (CompactOptions, error) {
+	for _, v := range txt {
+		mapping, err := parseTagList(v)
+		if err != nil || mapping["v"] != compactVersion || mapping["t"] != "h" {
+			continue
+		}
+
+		opts := CompactOptions{Compress: mapping["z"] == "deflate"}
+		if e := mapping["e"]; e != "" {
+			if opts.Payload, err = ParsePayloadFormat(e); err != nil {
+				return opts, err
+			}
+		}
+		return opts, nil
+	}
+
+	return CompactOptions{}, fmt.Errorf("no header record found")
+}
+
+// decodeCompact reassembles the document from the TXT records of the name in the compact encoding.
+// The decompressed payload and the document are counted against the limits of the resolution.
+func decodeCompact(ctx context.Context, name string, txt []string) (*Node, error) {
+	b := budgetFrom(ctx)
+
+	count := -1
+	compression := ""
+	format := ""
+	chunks := map[int]string{}
+
+	for _, v := range txt {
+		mapping, err := parseTagList(v)
+		if err != nil || mapping["v"] != compactVersion {
+			continue
+		}
+
+		switch mapping["t"] {
+		case "h":
+			if count != -1 {
+				return nil, fmt.Errorf("got multiple header records")
+			}
+
+			c, err := strconv.Atoi(mapping["n"])
+			if err != nil || c < 1 {
+				return nil, fmt.Errorf("invalid chunk count; got = %s", mapping["n"])
+			}
+			count = c
+			compression = mapping["z"]
+			format = mapping["e"]
+
+		case "c":
+			i, err := strconv.Atoi(mapping["i"])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid chunk index; got = %s", mapping["i"])
+			}
+			if _, ok := chunks[i]; ok {
+				return nil, fmt.Errorf("got duplicated chunk; index = %d", i)
+			}
+			chunks[i] = mapping["d"]
+
+		default:
+			return nil, fmt.Errorf("invalid value type; got = %s, expected = h || c", mapping["t"])
+		}
+	}
+
+	if count == -1 {
+		return nil, fmt.Errorf("no header record found")
+	}
+
+	if len(chunks) != count {
+		return nil, fmt.Errorf("chunk count mismatch; expected = %d, actual = %d", count, len(chunks))
+	}
+
+	sb := strings.Builder{}
+	for i := 0; i < count; i++ {
+		chunk, ok := chunks[i]
+		if !ok {
+			return nil, fmt.Errorf("missing chunk; index = %d", i)
+		}
+		sb.WriteString(chunk)
+	}
+
+	payload, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(sb.String())
+	if err != nil {
+		return nil, err
+	}
+
+	switch compression {
+	case "", "none":
+	case "deflate":
+		r := flate.NewReader(bytes.NewReader(payload))
+		defer r.Close()
+
+		// stop decompressing as soon as the payload exceeds the limit
+		var src io.Reader = r
+		if b != nil && b.limits.MaxDataSize > 0 {
+			src = io.LimitReader(r, int64(b.limits.MaxDataSize-b.size)+1)
+		}
+		if payload, err = io.ReadAll(src); err != nil {
+			return nil, err
+		}
+		if err := b.data(name, len(payload)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid compression; got = %s, expected = none || deflate", compression)
+	}
+
+	var node *Node
+	switch format {
+	case "", "json":
+		node, err = CreateFromJSON(payload)
+	case "cbor":
+		node, err = CreateFromCBOR(payload)
+	default:
+		return nil, fmt.Errorf("invalid payload format; got = %s, expected = json || cbor", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.tree(name, node, 0); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeDocument returns the document with the n services.
+func largeDocument(t testing.TB, n int) *Node {
+	t.Helper()
+
+	services := []string{}
+	for i := 0; i < n; i++ {
+		services = append(services, fmt.Sprintf(`{"id": "#s%d", "type": "LinkedDomains", "serviceEndpoint": "https://s%d.example.com/"}`, i, i))
+	}
+	return testDocument(t, `{"id": "did:dnssec:example.com", "service": [`+strings.Join(services, ",")+`]}`)
+}
+
+// compactTXT returns the contents of the records, as the lookups return them.
+func compactTXT(t testing.TB, rrs []*ResorceRecord) []string {
+	t.Helper()
+
+	txt := []string{}
+	for _, rr := range rrs {
+		txt = append(txt, strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(strings.Trim(rr.Data, `"`)))
+	}
+	return txt
+}
+
+func TestCompactRRs(t *testing.T) {
+	doc := largeDocument(t, 20)
+	want, _ := doc.CanonicalJSON()
+
+	sizes := map[CompactOptions]int{}
+	for _, opts := range []CompactOptions{
+		{},
+		{Compress: true},
+		{Payload: PayloadCBOR},
+		{Compress: true, Payload: PayloadCBOR},
+	} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			rrs, err := doc.CompactRRs("example.com.", opts)
+			if err != nil {
+				t.Fatalf("CompactRRs() error = %v", err)
+			}
+
+			data := ""
+			txt := compactTXT(t, rrs)
+			for i, v := range txt[1:] {
+				mapping, _ := parseTagList(v)
+				if mapping["i"] != fmt.Sprint(i) {
+					t.Errorf("chunk %d has index %s", i, mapping["i"])
+				}
+				if len(mapping["d"]) > compactChunkSize {
+					t.Errorf("chunk %d has %d bytes, want <= %d", i, len(mapping["d"]), compactChunkSize)
+				}
+				if len(v) > 255 {
+					t.Errorf("chunk %d record has %d bytes, want <= 255", i, len(v))
+				}
+				data += mapping["d"]
+			}
+			header, _ := parseTagList(txt[0])
+			if header["n"] != fmt.Sprint(len(txt)-1) || (len(data)+compactChunkSize-1)/compactChunkSize != len(txt)-1 {
+				t.Errorf("header = %s, chunks = %d, data = %d bytes", txt[0], len(txt)-1, len(data))
+			}
+			sizes[opts] = len(data)
+
+			node, err := decodeCompact(context.Background(), "_did.example.com.", txt)
+			if err != nil {
+				t.Fatalf("decodeCompact() error = %v", err)
+			}
+			if got, _ := node.CanonicalJSON(); string(got) != string(want) {
+				t.Errorf("decodeCompact() = %s, want %s", got, want)
+			}
+		})
+	}
+
+	if sizes[CompactOptions{Compress: true}] >= sizes[CompactOptions{}] {
+		t.Errorf("compressed = %d bytes, uncompressed = %d bytes", sizes[CompactOptions{Compress: true}], sizes[CompactOptions{}])
+	}
+}
+
+func TestDecodeCompactErrors(t *testing.T) {
+	record := func(tags ...string) string {
+		return formatRecord(compactVersion, tags...)
+	}
+	b64 := func(s string) string {
+		return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString([]byte(s))
+	}
+	doc := b64(`{"id": "did:dnssec:example.com"}`)
+
+	tests := []struct {
+		name string
+		txt  []string
+		want string
+	}{
+		{name: "no header", txt: []string{record("t", "c", "i", "0", "d", doc)}, want: "no header record found"},
+		{name: "multiple headers", txt: []string{record("t", "h", "n", "1"), record("t", "h", "n", "1")}, want: "multiple header records"},
+		{name: "invalid count", txt: []string{record("t", "h", "n", "0")}, want: "invalid chunk count"},
+		{name: "invalid index", txt: []string{record("t", "h", "n", "1"), record("t", "c", "i", "-1", "d", doc)}, want: "invalid chunk index"},
+		{name: "duplicated chunk", txt: []string{record("t", "h", "n", "2"), record("t", "c", "i", "0", "d", doc), record("t", "c", "i", "0", "d", doc)}, want: "duplicated chunk"},
+		{name: "count mismatch", txt: []string{record("t", "h", "n", "2"), record("t", "c", "i", "0", "d", doc)}, want: "chunk count mismatch"},
+		{name: "missing chunk", txt: []string{record("t", "h", "n", "2"), record("t", "c", "i", "0", "d", doc), record("t", "c", "i", "2", "d", doc)}, want: "missing chunk"},
+		{name: "invalid type", txt: []string{record("t", "h", "n", "1"), record("t", "q")}, want: "invalid value type"},
+		{name: "invalid base64", txt: []string{record("t", "h", "n", "1"), record("t", "c", "i", "0", "d", "!!")}, want: "illegal base64"},
+		{name: "invalid compression", txt: []string{record("t", "h", "n", "1", "z", "gzip"), record("t", "c", "i", "0", "d", doc)}, want: "invalid compression"},
+		{name: "invalid deflate", txt: []string{record("t", "h", "n", "1", "z", "deflate"), record("t", "c", "i", "0", "d", doc)}, want: "flate"},
+		{name: "invalid payload format", txt: []string{record("t", "h", "n", "1", "e", "xml"), record("t", "c", "i", "0", "d", doc)}, want: "invalid payload format"},
+		{name: "invalid json", txt: []string{record("t", "h", "n", "1"), record("t", "c", "i", "0", "d", b64("{"))}, want: "unexpected end of JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCompact(context.Background(), "_did.example.com.", tt.txt)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeCompact() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCompactBomb(t *testing.T) {
+	// the payload of zeros is compressed into a tiny fraction of its size
+	doc := testDocument(t, `{"id": "did:dnssec:example.com", "pad": "`+strings.Repeat("0", 1<<20)+`"}`)
+	rrs, err := doc.CompactRRs("example.com.", CompactOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useLimits(t, Limits{MaxDataSize: 64 << 10})
+	_, err = decodeCompact(withBudget(context.Background()), "_did.example.com.", compactTXT(t, rrs))
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxDataSize" {
+		t.Errorf("decodeCompact() error = %v, want MaxDataSize exceeded", err)
+	}
+}
+
+// benchmarkResolve resolves the document published with the records, and reports the queries per resolution.
+func benchmarkResolve(b *testing.B, rrs []*ResorceRecord) {
+	backend := useBackend(b, testZone(b, "example.com.", rrs...))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Resolve("did:dnssec:example.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(backend.queries.Load())/float64(b.N), "queries/op")
+}
+
+func BenchmarkResolveEncodings(b *testing.B) {
+	for _, n := range []int{1, 10, 50} {
+		doc := largeDocument(b, n)
+
+		b.Run(fmt.Sprintf("tree/services=%d", n), func(b *testing.B) {
+			benchmarkResolve(b, doc.RRs("example.com."))
+		})
+		for _, compress := range []bool{false, true} {
+			rrs, err := doc.CompactRRs("example.com.", CompactOptions{Compress: compress})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("compact/compress=%v/services=%d", compress, n), func(b *testing.B) {
+				benchmarkResolve(b, rrs)
+			})
+		}
+	}
+}
@@ -1,16 +1,15 @@
 package core
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
-
-	"golang.org/x/net/idna"
 )
 
 type NodeValType int
@@ -147,7 +146,7 @@ type Node struct {
 	Key      string
 	Value    *NodeValue
 	Parent   *Node
-	Children *[]Node
+	Children *[]*Node
 }
 
 type ResorceRecord struct {
@@ -184,11 +183,23 @@ func mapToNode(key string, parent *Node, m map[string]interface{}) (*Node, error
 			Type:  ValTypeMap,
 			value: nil,
 		},
-		Children: &[]Node{},
+		Children: &[]*Node{},
 		Parent:   parent,
 	}
 
-	for k, v := range m {
+	// sort the keys so that the same document always produces the same records
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := m[k]
+		if v == nil {
+			return nil, fmt.Errorf("null is not supported; key = %s", k)
+		}
+
 		switch reflect.TypeOf(v).Kind() {
 		case reflect.Map:
 			if child, err := mapToNode(k, tree, v.(map[string]interface{})); err == nil {
@@ -224,10 +235,14 @@ func sliceToNode(key string, parent *Node, s []interface{}) (*Node, error) {
 			value: nil,
 		},
 		Parent:   parent,
-		Children: &[]Node{},
+		Children: &[]*Node{},
 	}
 
 	for i, v := range s {
+		if v == nil {
+			return nil, fmt.Errorf("null is not supported; index = %d", i)
+		}
+
 		switch reflect.TypeOf(v).Kind() {
 		case reflect.Map:
 			if child, err := mapToNode(strconv.Itoa(i), tree, v.(map[string]interface{})); err == nil {
@@ -296,14 +311,22 @@ func primitiveToNode(key string, parent *Node, v interface{}) (*Node, error) {
 	return nil, fmt.Errorf("invalid primitive type; value = %v, type = %v", v, reflect.TypeOf(v))
 }
 
+// AddChild appends the node to the children, and sets its parent to n.
 func (n *Node) AddChild(node *Node) {
-	*n.Children = append(*n.Children, *node)
+	node.Parent = n
+	*n.Children = append(*n.Children, node)
 }
 
+// GetChild returns the child with the key, or nil if not found.
+// The returned node is the one in the tree, so that changes made through it are kept.
 func (n *Node) GetChild(key string) *Node {
+	if n.Children == nil {
+		return nil
+	}
+
 	for _, child := range *n.Children {
 		if child.Key == key {
-			return &child
+			return child
 		}
 	}
 
@@ -328,101 +351,94 @@ func (n *Node) GetChildValue(key string) *NodeValue {
 // Data field are constructed as follows:
 //
 //	`v=did:dinsec; t=<type>; d=<data>`
-//	- type: the type of the value, "p" for premitives, "m" for map pointer, "a" for array pointer.
+//	- type: the type of the value, "p" for premitives, "m" for map pointer, "a" for array pointer
+//	  (and "r" for the reference published by DedupeRRs).
 //	- data: the base64 encoded data of the value for premitives, the stringified number of the children for array, or the comma-separated string of the base64-encoded key for map.
+//
+// The keys are encoded with base64url; use TreeRRs for the case-insensitive base32hex labels.
 func (n *Node) RRs(base string) []*ResorceRecord {
-	rrs := []*ResorceRecord{}
-	key := base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString([]byte(n.Key))
+	return n.treeRRs(base, LabelBase64)
+}
 
-	// check if the node is root
-	if n.Parent == nil && n.Value.Type == ValTypeMap {
-		keys := []string{}
-		recName := fmt.Sprintf("_did.%s", base)
+// TreeRRs returns the resource records of the node in the tree encoding with the label encoding.
+// It fails if the labels of any keys in a map collide, since DNS names are case-insensitive.
+func (n *Node) TreeRRs(base string, enc LabelEncoding) ([]*ResorceRecord, error) {
+	if err := checkLabelCollisions(n, enc); err != nil {
+		return nil, err
+	}
 
-		for _, child := range *n.Children {
-			rrs = append(rrs, child.RRs(recName)...)
-			keys = append(keys, base64.URLEncoding.
-				WithPadding(base64.NoPadding).EncodeToString([]byte(child.Key)))
-		}
+	return n.treeRRs(base, enc), nil
+}
 
-		rrs = append(rrs, &ResorceRecord{
-			Name:  recName,
+func (n *Node) treeRRs(base string, enc LabelEncoding) []*ResorceRecord {
+	return n.nodeRRs(fmt.Sprintf("_did.%s", base), enc, nil)
+}
+
+// nodeRRs returns the records of the node at the name, and the ones of its descendants under the name.
+// If the ref function returns a name for a descendant, the reference record to the name is
+// published in place of the records of its subtree.
+func (n *Node) nodeRRs(name string, enc LabelEncoding, ref func(n *Node) string) []*ResorceRecord {
+	rrs := []*ResorceRecord{}
+	record := func(tags ...string) *ResorceRecord {
+		return &ResorceRecord{
+			Name:  name,
 			Class: "IN",
 			Type:  "TXT",
 			TTL:   3600,
-			Data:  fmt.Sprintf("\"v=did:dnssec; t=m; d=%s\"", strings.Join(keys, ",")),
-		})
-
-		return rrs
-	}
-
-	recName := fmt.Sprintf("%s.%s", key, base)
-	if n.Parent.Value.Type == ValTypeArray {
-		recName = fmt.Sprintf("%s.%s", n.Key, base)
+			Data:  zoneData(formatRecord(enc.version(), tags...)),
+		}
 	}
 
 	switch n.Value.Type {
 	case ValTypeMap:
 		keys := []string{}
 		for _, child := range *n.Children {
-			rrs = append(rrs, child.RRs(recName)...)
-			keys = append(keys, base64.URLEncoding.
-				WithPadding(base64.NoPadding).EncodeToString([]byte(child.Key)))
+			label := enc.encode(child.Key)
+			rrs = append(rrs, child.childRRs(fmt.Sprintf("%s.%s", label, name), enc, ref)...)
+			keys = append(keys, label)
 		}
+		sort.Strings(keys)
 
-		rrs = append(rrs, &ResorceRecord{
-			Name:  recName,
-			Class: "IN",
-			Type:  "TXT",
-			TTL:   3600,
-			Data:  fmt.Sprintf("\"v=did:dnssec; t=m; d=%s\"", strings.Join(keys, ",")),
-		})
+		rrs = append(rrs, record("t", "m", "d", strings.Join(keys, ",")))
 
 	case ValTypeArray:
 		for _, child := range *n.Children {
-			rrs = append(rrs, child.RRs(recName)...)
+			rrs = append(rrs, child.childRRs(fmt.Sprintf("%s.%s", child.Key, name), enc, ref)...)
 		}
 
-		rrs = append(rrs, &ResorceRecord{
-			Name:  recName,
-			Class: "IN",
-			Type:  "TXT",
-			TTL:   3600,
-			Data:  fmt.Sprintf("\"v=did:dnssec; t=a; d=%d\"", len(*n.Children)),
-		})
+		rrs = append(rrs, record("t", "a", "d", strconv.Itoa(len(*n.Children))))
 
 	case ValTypeString:
-		rrs = append(rrs, &ResorceRecord{
-			Name:  recName,
-			Class: "IN",
-			Type:  "TXT",
-			TTL:   3600,
-			Data: fmt.Sprintf(
-				"\"v=did:dnssec; t=p; d=%s=%s\"",
-				n.Value.Type.String(),
+		rrs = append(rrs, record(
+			"t", "p", "d", n.Value.Type.String()+"="+
 				base64.URLEncoding.WithPadding(base64.NoPadding).
 					EncodeToString([]byte(n.Value.String())),
-			),
-		})
+		))
 
 	default:
-		rrs = append(rrs, &ResorceRecord{
-			Name:  recName,
-			Class: "IN",
-			Type:  "TXT",
-			TTL:   3600,
-			Data: fmt.Sprintf(
-				"\"v=did:dnssec; t=p; d=%s=%s\"",
-				n.Value.Type.String(), n.Value.String(),
-			),
-		})
+		rrs = append(rrs, record("t", "p", "d", n.Value.Type.String()+"="+n.Value.String()))
 	}
 
 	return rrs
 }
 
+func (n *Node) childRRs(name string, enc LabelEncoding, ref func(n *Node) string) []*ResorceRecord {
+	if ref != nil {
+		if target := ref(n); target != "" {
+			return []*ResorceRecord{referenceRR(name, enc, target)}
+		}
+	}
+
+	return n.nodeRRs(name, enc, ref)
+}
+
 func (n *Node) DumpRRs(f io.Writer, base string) error {
-	for _, rr := range n.RRs(base) {
+	return WriteRRs(f, n.RRs(base))
+}
+
+// WriteRRs writes the resource records to f in the zone file format, one record per line.
+func WriteRRs(f io.Writer, rrs []*ResorceRecord) error {
+	for _, rr := range rrs {
 		if _, err := f.Write([]byte(rr.String() + "\n")); err != nil {
 			return err
 		}
@@ -430,8 +446,22 @@ func (n *Node) DumpRRs(f io.Writer, base string) error {
 	return nil
 }
 
-func (n *Node) Print() error {
-	return recursivePrintTree(n, 0)
+// Print writes the tree of the node to w, one node per line indented by its depth.
+func (n *Node) Print(w io.Writer) error {
+	return recursivePrintTree(w, n, 0)
+}
+
+// Interface returns the value of the node as the types decoded from JSON:
+// a map[string]interface{}, a []interface{} or the primitive value.
+func (n *Node) Interface() (interface{}, error) {
+	switch n.Value.Type {
+	case ValTypeMap:
+		return nodeToMap(n)
+	case ValTypeArray:
+		return nodeToSlice(n)
+	default:
+		return n.Value.value, nil
+	}
 }
 
 func (n *Node) JSON() ([]byte, error) {
@@ -456,61 +486,176 @@ func (n *Node) JSON() ([]byte, error) {
 	return nil, fmt.Errorf("invalid node type; typ = %s", n.Value.Type.String())
 }
 
+// Resolve resolves the given DID into the document tree.
+// The wire format published under `_did.<base>` is detected automatically, where the base is
+// the domain of the DID or the name of its sub-identifiers (see DIDBase);
+// both the tree encoding and the compact encoding are supported.
+//
+// The did argument may have the `versionId` or `versionTime` query parameter
+// to resolve the previous version of the document.
 func Resolve(did string) (*Node, error) {
-	if err := validateDidSyntax(did); err != nil {
-		return nil, err
+	node, _, err := ResolveWithMetadata(did)
+	return node, err
+}
+
+// ResolveWithMetadata resolves the given DID into the document tree and its document metadata.
+func ResolveWithMetadata(didURL string) (*Node, *DocumentMetadata, error) {
+	return ResolveContext(context.Background(), didURL)
+}
+
+// ResolveContext is ResolveWithMetadata with the context.
+// The resolution is recorded as the OpenTelemetry span, with a child span per DNS query.
+func ResolveContext(ctx context.Context, didURL string) (node *Node, meta *DocumentMetadata, err error) {
+	ctx, end := startResolveSpan(withBudget(ctx), "resolve", didURL)
+	defer func() { end(meta, err) }()
+
+	node, name, txt, meta, err := lookupDocument(ctx, didURL)
+	if err != nil || node != nil {
+		return node, meta, err
+	}
+
+	if node, err = resolveRecords(ctx, name, "", nil, 0, txt); err != nil {
+		return nil, nil, err
 	}
 
-	fqdn := strings.Split(did, ":")[2]
-	node, err := resolve("_did."+fqdn+".", nil)
+	Logger().Info("resolved did", "did", meta.CanonicalID, "name", name)
+	return node, meta, nil
+}
+
+// lookupDocument looks up the root records of the document of the DID URL, following the delegation
+// with CNAME or DNAME and selecting the version by the query parameters.
+// The deactivated document and the document in the compact encoding are decoded and returned as
+// the node. For the tree encoding, the node is nil and the owner name and the records of the root
+// are returned, so that the caller looks up the rest of the tree as it needs.
+func lookupDocument(ctx context.Context, didURL string) (
+	node *Node, name string, txt []string, meta *DocumentMetadata, err error,
+) {
+	did, params, err := parseDIDURL(didURL)
 	if err != nil {
-		return nil, err
+		return nil, "", nil, nil, err
 	}
 
-	fmt.Println("resolving done")
-	return node, nil
+	base, err := DIDBase(did)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	name = "_did." + base
+	// validated by DIDBase
+	canonical, _ := CanonicalDID(did)
+
+	// the records may be delegated to another zone with CNAME or DNAME
+	txt, root, err := lookupTXT(ctx, name)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	location := ""
+	if root != name {
+		Logger().Info("following delegation", "name", name, "target", root)
+		location, name = root, root
+	}
+
+	// the version is selected first, since the versions before the deactivation are still resolvable
+	name, txt, meta, err = selectVersion(ctx, name, txt, params)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+	meta.CanonicalID = canonical
+	meta.Location = location
+
+	if ts, err := parseTombstone(txt); err != nil {
+		return nil, "", nil, nil, recordFormatError(name, txt, err)
+	} else if ts != nil {
+		node, err := deactivatedDocument(canonical)
+		if err != nil {
+			return nil, "", nil, nil, err
+		}
+		meta.Updated = ts
+		meta.Deactivated = true
+
+		Logger().Info("resolved deactivated did", "did", canonical)
+		return node, name, txt, meta, nil
+	}
+
+	if isCompact(txt) {
+		if err := checkCompactConflict(name, txt); err != nil {
+			return nil, "", nil, nil, recordFormatError(name, txt, err)
+		}
+		node, err := decodeCompact(ctx, name, txt)
+		if err != nil {
+			return nil, "", nil, nil, recordFormatError(name, txt, err)
+		}
+
+		Logger().Info("resolved did", "did", canonical, "name", name)
+		return node, name, txt, meta, nil
+	}
+
+	return nil, name, txt, meta, nil
 }
 
-func resolve(fqdn string, parent *Node) (*Node, error) {
-	fmt.Printf("resolving %s...\n", fqdn)
-	txt, err := net.LookupTXT(fqdn)
+// resolve looks up the records of the node with the key at the depth of the tree, and builds it.
+func resolve(ctx context.Context, fqdn string, key string, parent *Node, depth int) (*Node, error) {
+	// the children of the aliased node are looked up under its canonical name
+	txt, canonical, err := lookupTXT(ctx, fqdn)
 	if err != nil {
 		return nil, err
 	}
 
-	var rType rValType
-	var values []string
+	return resolveRecords(ctx, canonical, key, parent, depth, txt)
+}
 
-	for _, v := range txt {
-		if typ, vals, err := parseRecordValue(v); err != nil || typ == rValTypeInvalid {
-			continue
-		} else {
-			rType = typ
-			values = vals
-			break
+// resolveRecords builds the node with the key from its records, looking up the children.
+// The node is counted against the limits of the resolution before its children are looked up.
+func resolveRecords(ctx context.Context, fqdn string, key string, parent *Node, depth int, txt []string) (*Node, error) {
+	rType, enc, values, err := parseRecords(fqdn, txt)
+	if err != nil {
+		return nil, recordFormatError(fqdn, txt, err)
+	}
+
+	// the subtree published at another name takes the place of the node
+	if rType == rValTypeReference {
+		refCtx, err := followReference(ctx, fqdn, values[0])
+		if err != nil {
+			return nil, recordFormatError(fqdn, txt, err)
 		}
+		return resolve(refCtx, values[0], key, parent, depth)
 	}
+	ctx = withName(ctx, fqdn)
 
-	if len(values) == 0 {
-		return nil, fmt.Errorf("no valid record found")
+	children := 0
+	switch rType {
+	case rValTypeMapPointer:
+		children = len(values)
+	case rValTypeArrayPointer:
+		// the count is validated below; the limit is checked here before looping over it
+		children, _ = strconv.Atoi(values[0])
+	}
+	if err := budgetFrom(ctx).node(fqdn, depth, children); err != nil {
+		return nil, err
 	}
 
+	return buildNode(ctx, fqdn, key, parent, depth, rType, enc, values, txt)
+}
+
+// buildNode builds the node from its parsed records, resolving the children.
+// The node must have been counted against the limits of the resolution.
+func buildNode(ctx context.Context, fqdn string, key string, parent *Node, depth int,
+	rType rValType, enc LabelEncoding, values []string, txt []string) (*Node, error) {
 	var node *Node
 	if parent == nil {
 		node = &Node{
-			Key: "",
+			Key: key,
 			Value: &NodeValue{
 				Type:  ValTypeMap,
 				value: nil,
 			},
 			Parent:   parent,
-			Children: &[]Node{},
+			Children: &[]*Node{},
 		}
 	} else {
 		node = &Node{
-			Key:      strings.Split(fqdn, ".")[0],
+			Key:      key,
 			Parent:   parent,
-			Children: &[]Node{},
+			Children: &[]*Node{},
 		}
 	}
 
@@ -522,8 +667,13 @@ func resolve(fqdn string, parent *Node) (*Node, error) {
 				value: nil,
 			}
 
+			key, err := enc.decode(v)
+			if err != nil {
+				return nil, recordFormatError(fqdn, txt, err)
+			}
+
 			next := fmt.Sprintf("%s.%s", v, fqdn)
-			if child, err := resolve(next, node); err != nil {
+			if child, err := resolve(ctx, next, key, node, depth+1); err != nil {
 				return nil, err
 			} else {
 				node.AddChild(child)
@@ -531,12 +681,12 @@ func resolve(fqdn string, parent *Node) (*Node, error) {
 		}
 	case rValTypeArrayPointer:
 		if len(values) != 1 {
-			return nil, fmt.Errorf("got multiple values for array type; values = %v", values)
+			return nil, recordFormatError(fqdn, txt, fmt.Errorf("got multiple values for array type; values = %v", values))
 		}
 
 		count, err := strconv.Atoi(values[0])
 		if err != nil {
-			return nil, err
+			return nil, recordFormatError(fqdn, txt, err)
 		}
 
 		node.Value = &NodeValue{
@@ -545,7 +695,7 @@ func resolve(fqdn string, parent *Node) (*Node, error) {
 
 		for i := 0; i < count; i++ {
 			next := fmt.Sprintf("%d.%s", i, fqdn)
-			if child, err := resolve(next, node); err != nil {
+			if child, err := resolve(ctx, next, strconv.Itoa(i), node, depth+1); err != nil {
 				return nil, err
 			} else {
 				node.AddChild(child)
@@ -553,85 +703,143 @@ func resolve(fqdn string, parent *Node) (*Node, error) {
 		}
 
 	case rValTypePremitive:
-		if len(values) != 1 {
-			return nil, fmt.Errorf("got multiple values for premitive type; values = %v", values)
+		value, err := decodePrimitive(values)
+		if err != nil {
+			return nil, recordFormatError(fqdn, txt, err)
 		}
+		node.Value = value
+	}
 
-		typAndVal := strings.Split(values[0], "=")
-		if len(typAndVal) != 2 {
-			return nil, fmt.Errorf("invalid premitive value; got = %s", values[0])
-		}
+	return node, nil
+}
 
-		switch typAndVal[0] {
-		case "string":
-			str, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(typAndVal[1])
-			if err != nil {
-				return nil, err
-			}
+// parseRecords finds the first valid record in the TXT records of the name,
+// and returns its type, the label encoding of its version and its values.
+// The other valid records and the invalid records of the tree encoding are conflicts;
+// see SetStrict.
+func parseRecords(name string, txt []string) (rValType, LabelEncoding, []string, error) {
+	var found []string
+	var invalid []string
+	var typ rValType
+	var enc LabelEncoding
+	var vals []string
 
-			node.Value = &NodeValue{
-				Type:  ValTypeString,
-				value: string(str),
+	for _, v := range txt {
+		t, e, vs, err := parseRecordValue(v)
+		if err != nil || t == rValTypeInvalid || len(vs) == 0 {
+			if isTreeRecord(v) {
+				invalid = append(invalid, v)
+			} else {
+				Logger().Debug("skipped invalid record", "record", v, "error", err)
 			}
+			continue
+		}
 
-		case "int":
-			i, err := strconv.Atoi(typAndVal[1])
-			if err != nil {
-				return nil, err
-			}
+		if len(found) == 0 {
+			typ, enc, vals = t, e, vs
+		}
+		found = append(found, v)
+	}
 
-			node.Value = &NodeValue{
-				Type:  ValTypeInt,
-				value: i,
-			}
+	if len(found) == 0 {
+		return rValTypeInvalid, LabelBase64, nil, fmt.Errorf("no valid record found")
+	}
 
-		case "float":
-			f, err := strconv.ParseFloat(typAndVal[1], 64)
-			if err != nil {
-				return nil, err
-			}
+	if len(found) > 1 {
+		if err := conflict(name, "multiple valid records", found); err != nil {
+			return rValTypeInvalid, LabelBase64, nil, err
+		}
+	}
+	if len(invalid) > 0 {
+		if err := conflict(name, "invalid records", invalid); err != nil {
+			return rValTypeInvalid, LabelBase64, nil, err
+		}
+	}
 
-			node.Value = &NodeValue{
-				Type:  ValTypeFloat,
-				value: f,
-			}
+	return typ, enc, vals, nil
+}
 
-		case "bool":
-			b, err := strconv.ParseBool(typAndVal[1])
-			if err != nil {
-				return nil, err
-			}
+// decodePrimitive decodes the `<type>=<value>` data of the primitive record.
+func decodePrimitive(values []string) (*NodeValue, error) {
+	if len(values) != 1 {
+		return nil, fmt.Errorf("got multiple values for premitive type; values = %v", values)
+	}
 
-			node.Value = &NodeValue{
-				Type:  ValTypeBool,
-				value: b,
-			}
+	typAndVal := strings.Split(values[0], "=")
+	if len(typAndVal) != 2 {
+		return nil, fmt.Errorf("invalid premitive value; got = %s", values[0])
+	}
 
-		default:
-			return nil, fmt.Errorf("invalid premitive type; got = %s", typAndVal[0])
+	switch typAndVal[0] {
+	case "string":
+		str, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(typAndVal[1])
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	return node, nil
+		return &NodeValue{
+			Type:  ValTypeString,
+			value: string(str),
+		}, nil
+
+	case "int":
+		i, err := strconv.Atoi(typAndVal[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return &NodeValue{
+			Type:  ValTypeInt,
+			value: i,
+		}, nil
+
+	case "float":
+		f, err := strconv.ParseFloat(typAndVal[1], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return &NodeValue{
+			Type:  ValTypeFloat,
+			value: f,
+		}, nil
+
+	case "bool":
+		b, err := strconv.ParseBool(typAndVal[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return &NodeValue{
+			Type:  ValTypeBool,
+			value: b,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid premitive type; got = %s", typAndVal[0])
+	}
 }
 
 func validateDidSyntax(did string) error {
 	ary := strings.Split(did, ":")
-	if len(ary) != 3 {
-		return fmt.Errorf("invalid did syntax; did = %s", did)
+	if ary[0] != "did" || len(ary) < 3 {
+		return fmt.Errorf("%w; did = %s", ErrInvalidDID, did)
 	}
 
-	if ary[0] != "did" {
-		return fmt.Errorf("invalid URI scheme; expected = did, actual = %s", ary[0])
+	if ary[1] != "dnssec" {
+		return fmt.Errorf("%w; expected = dnssec, actual = %s", ErrMethodNotSupported, ary[1])
 	}
 
-	if ary[1] != "dnssec" {
-		return fmt.Errorf("invalid DID method; expected = dnssec, actual = %s", ary[1])
+	// check if the ary[2] is a valid FQDN, which may be percent-encoded U-labels
+	if _, err := decodeDomain(ary[2]); err != nil {
+		return fmt.Errorf("%w: %v; did = %s", ErrInvalidDID, err, did)
 	}
 
-	// check if the ary[2] is a valid FQDN
-	if _, err := idna.Lookup.ToASCII(ary[2]); err != nil {
-		return fmt.Errorf("invalid domain name; got = %s", ary[2])
+	// the rest are the sub-identifiers (see DIDBase)
+	for _, v := range ary[3:] {
+		if _, err := decodeSubIdentifier(v); err != nil {
+			return fmt.Errorf("%w: %v; did = %s", ErrInvalidDID, err, did)
+		}
 	}
 
 	return nil
@@ -644,72 +852,69 @@ const (
 	rValTypeMapPointer rValType = iota
 	rValTypeArrayPointer
 	rValTypePremitive
+	rValTypeReference
 )
 
-func parseRecordValue(value string) (rValType, []string, error) {
-	// remove quotes and spaces
-	value = strings.Trim(value, "\"")
-	value = strings.Trim(value, "'")
-	value = strings.Trim(value, " ")
-	value = strings.ReplaceAll(value, " ", "")
-	value = strings.ReplaceAll(value, "\t", "")
-
-	ary := strings.Split(value, ";")
-	mapping := map[string]string{}
-
-	for _, v := range ary {
-		kv := strings.Split(v, "=")
-		if len(kv) != 2 && len(kv) != 3 {
-			return rValTypeInvalid, nil, fmt.Errorf("invalid record value; got = %s", value)
-		}
-
-		if len(kv) == 2 {
-			mapping[kv[0]] = kv[1]
-		} else {
-			mapping[kv[0]] = fmt.Sprintf("%s=%s", kv[1], kv[2])
-		}
+func parseRecordValue(value string) (rValType, LabelEncoding, []string, error) {
+	mapping, err := parseTagList(value)
+	if err != nil {
+		return rValTypeInvalid, LabelBase64, nil, err
 	}
 
-	if mapping["v"] != "did:dnssec" {
-		return rValTypeInvalid, nil, fmt.Errorf("invalid record version; got = %s", mapping["v"])
+	enc, ok := treeVersions[mapping["v"]]
+	if !ok {
+		return rValTypeInvalid, LabelBase64, nil, fmt.Errorf("invalid record version; got = %s", mapping["v"])
 	}
 
 	if mapping["d"] == "" {
-		return rValTypeInvalid, nil, fmt.Errorf("invalid record data; got = %s", mapping["d"])
+		return rValTypeInvalid, enc, nil, fmt.Errorf("invalid record data; got = %s", mapping["d"])
 	}
 
 	switch mapping["t"] {
 	case "m":
-		return rValTypeMapPointer, strings.Split(mapping["d"], ","), nil
+		return rValTypeMapPointer, enc, strings.Split(mapping["d"], ","), nil
 	case "a":
-		return rValTypeArrayPointer, strings.Split(mapping["d"], ","), nil
+		return rValTypeArrayPointer, enc, strings.Split(mapping["d"], ","), nil
 	case "p":
-		return rValTypePremitive, []string{mapping["d"]}, nil
+		return rValTypePremitive, enc, []string{mapping["d"]}, nil
+	case "r":
+		return rValTypeReference, enc, []string{mapping["d"]}, nil
 	default:
-		return rValTypeInvalid, nil, fmt.Errorf("invalid value type; got = %s, expected = p || a || m", mapping["t"])
+		return rValTypeInvalid, enc, nil, fmt.Errorf("invalid value type; got = %s, expected = p || a || m || r", mapping["t"])
+	}
+}
+
+// parseTagList parses the TXT record with ParseRecord into the map of the tags, including the version.
+func parseTagList(value string) (map[string]string, error) {
+	r, err := ParseRecord(value)
+	if err != nil {
+		return nil, err
 	}
+
+	mapping := map[string]string{"v": r.Version}
+	for _, t := range r.Tags {
+		mapping[t.Name] = t.Value
+	}
+
+	return mapping, nil
 }
 
 func nodeToMap(node *Node) (map[string]interface{}, error) {
 	m := map[string]interface{}{}
 
 	for _, child := range *node.Children {
-		bytes, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(child.Key)
-		if err != nil {
-			return nil, err
-		}
-		key := string(bytes)
+		key := child.Key
 
 		switch child.Value.Type {
 		case ValTypeMap:
-			if childMap, err := nodeToMap(&child); err == nil {
+			if childMap, err := nodeToMap(child); err == nil {
 				m[key] = childMap
 			} else {
 				return nil, err
 			}
 
 		case ValTypeArray:
-			if childSlice, err := nodeToSlice(&child); err == nil {
+			if childSlice, err := nodeToSlice(child); err == nil {
 				m[key] = childSlice
 			} else {
 				return nil, err
@@ -729,14 +934,14 @@ func nodeToSlice(node *Node) ([]interface{}, error) {
 	for _, child := range *node.Children {
 		switch child.Value.Type {
 		case ValTypeMap:
-			if childMap, err := nodeToMap(&child); err == nil {
+			if childMap, err := nodeToMap(child); err == nil {
 				s = append(s, childMap)
 			} else {
 				return nil, err
 			}
 
 		case ValTypeArray:
-			if childSlice, err := nodeToSlice(&child); err == nil {
+			if childSlice, err := nodeToSlice(child); err == nil {
 				s = append(s, childSlice)
 			} else {
 				return nil, err
@@ -750,24 +955,22 @@ func nodeToSlice(node *Node) ([]interface{}, error) {
 	return s, nil
 }
 
-func recursivePrintTree(tree *Node, depth int) error {
+func recursivePrintTree(w io.Writer, tree *Node, depth int) error {
 	indent := getIndent(depth)
 
 	key := tree.Key
-	if tree.Parent != nil && tree.Parent.Value.Type != ValTypeArray {
-		keyb, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(tree.Key)
-		if err != nil {
-			return err
-		}
-		key = string(keyb)
-	}
 
 	if tree.Value.Type != ValTypeMap && tree.Value.Type != ValTypeArray {
-		fmt.Printf("%s%s: %s (%s)\n", indent, key, tree.Value, tree.Value.Type.String())
-	} else {
-		fmt.Printf("%s%s: (%s)\n", indent, key, tree.Value.Type.String())
-		for _, child := range *tree.Children {
-			recursivePrintTree(&child, depth+1)
+		_, err := fmt.Fprintf(w, "%s%s: %s (%s)\n", indent, key, tree.Value, tree.Value.Type.String())
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s%s: (%s)\n", indent, key, tree.Value.Type.String()); err != nil {
+		return err
+	}
+	for _, child := range *tree.Children {
+		if err := recursivePrintTree(w, child, depth+1); err != nil {
+			return err
 		}
 	}
 
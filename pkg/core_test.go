@@ -0,0 +1,25 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNodePrint(t *testing.T) {
+	n := testDocument(t, `{"a":[1,"x"],"b":{"c":true}}`)
+
+	var buf bytes.Buffer
+	if err := n.Print(&buf); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+
+	want := ": (map)\n" +
+		"  a: (array)\n" +
+		"    0: 1 (float)\n" +
+		"    1: x (string)\n" +
+		"  b: (map)\n" +
+		"    c: true (bool)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Print() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Tombstone returns the tombstone record which marks the DID of the base as deactivated.
+// The base argument is the base domain name, and must be ended with a dot(root).
+//
+// The tombstone replaces all the document records under `_did.<base>`:
+//
+//	`v=did:dnssec; t=x; d=<time>`
+//	- time: the time when the DID is deactivated, in RFC 3339.
+func Tombstone(base string, at time.Time) *ResorceRecord {
+	return &ResorceRecord{
+		Name:  fmt.Sprintf("_did.%s", base),
+		Class: "IN",
+		Type:  "TXT",
+		TTL:   3600,
+		Data:  zoneData(formatRecord("did:dnssec", "t", "x", "d", at.UTC().Format(time.RFC3339))),
+	}
+}
+
+// DeactivateRRs returns the records under `_did.<base>` which deactivate the published document
+// at the time, replacing its current records (Published.RRs). The current version is archived
+// under `v<id>._did.<base>`, and the tombstone is published as the next version, so that the
+// previous versions are still resolved with the versionId and versionTime parameters.
+// The archives of the older versions (Published.Archived) are kept as they are.
+//
+// The document which is not versioned is archived as the version 1, whose time is unknown and
+// set to the zero time. If nothing is published, the published argument is nil, and only the
+// tombstone is returned. The base argument must be ended with a dot(root).
+func DeactivateRRs(published *Published, base string, at time.Time) ([]*ResorceRecord, error) {
+	root := fmt.Sprintf("_did.%s", base)
+	if published == nil {
+		return []*ResorceRecord{Tombstone(base, at)}, nil
+	}
+	if published.Location != "" {
+		return nil, fmt.Errorf("records are delegated to %s; deactivate them at the host as the base", published.Location)
+	}
+	if published.Deactivated {
+		return nil, fmt.Errorf("already deactivated; name = %s", root)
+	}
+
+	current := published.Version
+	archived := published.RRs
+	if current == nil {
+		current = &Version{ID: 1}
+		archived = append(append([]*ResorceRecord{}, published.RRs...), current.RR(root))
+	}
+
+	rrs := ArchiveRRs(archived, base, current.ID)
+	rrs = append(rrs, Tombstone(base, at), Version{ID: current.ID + 1, Time: at}.RR(root))
+
+	return rrs, nil
+}
+
+// parseTombstone finds the tombstone record in the TXT records.
+func parseTombstone(txt []string) (*time.Time, error) {
+	for _, v := range txt {
+		mapping, err := parseTagList(v)
+		if err != nil || mapping["v"] != "did:dnssec" || mapping["t"] != "x" {
+			continue
+		}
+
+		ts, err := time.Parse(time.RFC3339, mapping["d"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid deactivation time; got = %s", mapping["d"])
+		}
+
+		return &ts, nil
+	}
+
+	return nil, nil
+}
+
+// deactivatedDocument returns the minimal document of the deactivated DID, which only has the id.
+func deactivatedDocument(did string) (*Node, error) {
+	bytes, err := json.Marshal(map[string]interface{}{"id": did})
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateFromJSON(bytes)
+}
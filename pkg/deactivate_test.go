@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestResolveDeactivatedVersions(t *testing.T) {
+	base := "example.com."
+	v1 := testDocument(t, `{"id": "did:dnssec:example.com", "a": "v1"}`)
+	at := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	rrs := ArchiveRRs(append(v1.RRs(base), Version{ID: 1, Time: at.Add(-time.Hour)}.RR("_did."+base)), base, 1)
+	rrs = append(rrs, Tombstone(base, at), Version{ID: 2, Time: at}.RR("_did."+base))
+	useBackend(t, testZone(t, base, rrs...))
+
+	tests := []struct {
+		did         string
+		deactivated bool
+		versionID   string
+		wantErr     error
+	}{
+		{did: "did:dnssec:example.com", deactivated: true, versionID: "2"},
+		{did: "did:dnssec:example.com?versionId=2", deactivated: true, versionID: "2"},
+		{did: "did:dnssec:example.com?versionId=1", versionID: "1"},
+		{did: "did:dnssec:example.com?versionTime=2024-01-01T23:30:00Z", versionID: "1"},
+		{did: "did:dnssec:example.com?versionId=3", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.did, func(t *testing.T) {
+			node, meta, err := ResolveWithMetadata(tt.did)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if meta.Deactivated != tt.deactivated || meta.VersionID != tt.versionID {
+				t.Errorf("Resolve() meta = %+v, want deactivated = %v, versionId = %s", meta, tt.deactivated, tt.versionID)
+			}
+			if !tt.deactivated {
+				if a := node.GetChild("a"); a == nil || a.Value.String() != "v1" {
+					t.Errorf("Resolve() a = %v, want v1", a)
+				}
+			}
+		})
+	}
+}
+
+// applyUpdate returns the records changed with the update.
+func applyUpdate(rrs []*ResorceRecord, u *Update) []*ResorceRecord {
+	key := func(rr *ResorceRecord) string {
+		return strings.ToLower(dns.Fqdn(rr.Name)) + " " + rr.Type + " " + rr.Data
+	}
+	deleted := map[string]bool{}
+	for _, rr := range u.Delete {
+		deleted[key(rr)] = true
+	}
+	for _, name := range u.DeleteNames {
+		deleted[strings.ToLower(dns.Fqdn(name))] = true
+	}
+
+	res := []*ResorceRecord{}
+	for _, rr := range rrs {
+		if !deleted[key(rr)] && !deleted[strings.ToLower(dns.Fqdn(rr.Name))] {
+			res = append(res, rr)
+		}
+	}
+	return append(res, u.Add...)
+}
+
+func TestDeactivateRRs(t *testing.T) {
+	base := "example.com."
+	v1 := testDocument(t, `{"id": "did:dnssec:example.com", "a": "v1", "b": [1, 2]}`)
+	v2 := testDocument(t, `{"id": "did:dnssec:example.com", "a": "v2"}`)
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	compact, err := v1.CompactRRs(base, CompactOptions{})
+	if err != nil {
+		t.Fatalf("CompactRRs() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		// rrs is the records published as create and update do
+		rrs []*ResorceRecord
+		// versions maps the ids of the versions resolved after the deactivation to the values of "a"
+		versions map[string]string
+		current  string
+	}{
+		{
+			name:     "not versioned",
+			rrs:      v1.RRs(base),
+			versions: map[string]string{"1": "v1"},
+			current:  "2",
+		},
+		{
+			name:     "compact",
+			rrs:      compact,
+			versions: map[string]string{"1": "v1"},
+			current:  "2",
+		},
+		{
+			name: "versioned",
+			rrs: append(
+				ArchiveRRs(append(v1.RRs(base), Version{ID: 1, Time: t1}.RR("_did."+base)), base, 1),
+				append(v2.RRs(base), Version{ID: 2, Time: t2}.RR("_did."+base))...,
+			),
+			versions: map[string]string{"1": "v1", "2": "v2"},
+			current:  "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := useBackend(t, testZone(t, base, tt.rrs...))
+
+			published, err := LookupPublished(context.Background(), base)
+			if err != nil {
+				t.Fatalf("LookupPublished() error = %v", err)
+			}
+			rrs, err := DeactivateRRs(published, base, at)
+			if err != nil {
+				t.Fatalf("DeactivateRRs() error = %v", err)
+			}
+
+			// the update is applied to the zone as the dynamic update does
+			b.zones = []*Zone{testZone(t, base, applyUpdate(tt.rrs, DiffRRs(published.RRs, rrs))...)}
+
+			_, meta, err := ResolveWithMetadata("did:dnssec:example.com")
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if !meta.Deactivated || meta.VersionID != tt.current || !meta.Updated.Equal(at) {
+				t.Errorf("Resolve() meta = %+v, want deactivated version %s at %s", meta, tt.current, at)
+			}
+
+			for id, want := range tt.versions {
+				doc, meta, err := ResolveWithMetadata("did:dnssec:example.com?versionId=" + id)
+				if err != nil {
+					t.Fatalf("Resolve(versionId=%s) error = %v", id, err)
+				}
+				if a := doc.GetChild("a"); meta.Deactivated || a == nil || a.Value.String() != want {
+					t.Errorf("Resolve(versionId=%s) a = %v, deactivated = %v; want %s", id, a, meta.Deactivated, want)
+				}
+			}
+
+			// the deactivated document cannot be deactivated again
+			published, err = LookupPublished(context.Background(), base)
+			if err != nil {
+				t.Fatalf("LookupPublished() after the deactivation error = %v", err)
+			}
+			if _, err := DeactivateRRs(published, base, at); err == nil {
+				t.Errorf("DeactivateRRs() of the deactivated document succeeded")
+			}
+		})
+	}
+}
+
+func TestDeactivateRRsDelegated(t *testing.T) {
+	doc := testDocument(t, `{"id": "did:dnssec:example.com"}`)
+	useBackend(t,
+		testZone(t, "example.com.", aliasRR("CNAME", "_did.example.com.", "_did.host.example.net.")),
+		testZone(t, "example.net.", doc.RRs("host.example.net.")...),
+	)
+
+	published, err := LookupPublished(context.Background(), "example.com.")
+	if err != nil {
+		t.Fatalf("LookupPublished() error = %v", err)
+	}
+	if _, err := DeactivateRRs(published, "example.com.", time.Now()); err == nil {
+		t.Errorf("DeactivateRRs() of the delegated records succeeded")
+	}
+}
+
+func TestDeactivateRRsNotPublished(t *testing.T) {
+	rrs, err := DeactivateRRs(nil, "example.com.", time.Now())
+	if err != nil {
+		t.Fatalf("DeactivateRRs() error = %v", err)
+	}
+	if len(rrs) != 1 || rrs[0].Name != "_did.example.com." {
+		t.Errorf("DeactivateRRs() = %v, want the tombstone", rrs)
+	}
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// DelegationRR returns the record which delegates the records of the DID under `_did.<base>`
+// to the ones a hosting provider publishes under `_did.<host>`:
+//
+//	`_did.<base> CNAME _did.<host>`
+//
+// The resolver follows the alias, and looks up the whole tree, the versions and the tombstone
+// under the target. A DNAME at a name above the root, such as `_sub._did.<domain>`, delegates
+// all the sub-identifiers of the domain at once, and is followed as well.
+// Both arguments must be ended with a dot(root).
+func DelegationRR(base string, host string) *ResorceRecord {
+	return &ResorceRecord{
+		Name:  fmt.Sprintf("_did.%s", base),
+		Class: "IN",
+		Type:  "CNAME",
+		TTL:   3600,
+		Data:  dns.Fqdn(fmt.Sprintf("_did.%s", host)),
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// subLabel is the label under which the sub-identifiers of a domain are published.
+// It never collides with the labels of the tree encoding, since it is neither valid base32hex
+// nor the base64url of a valid UTF-8 string.
+const subLabel = "_sub"
+
+// maxSubIdentifierSize is the maximum size in bytes of a decoded sub-identifier,
+// whose base32hex label fits in the 63 bytes of a DNS label.
+const maxSubIdentifierSize = 39
+
+// DIDBase returns the base name of the DID, whose records are published under `_did.<base>`.
+//
+// The DID of a domain, `did:dnssec:example.com`, is published under the domain itself in A-labels.
+// The colon-separated sub-identifiers after the domain, such as `did:dnssec:example.com:service:billing`,
+// are percent-decoded and mapped to the base32hex labels under `_sub._did.<domain>`, the last one first:
+//
+//	<b32(billing)>.<b32(service)>._sub._did.example.com.
+//
+// The percent-encoded and the plain forms of the same character are the same identifier,
+// as URIs compare them; any other pair of identifiers is mapped to different names.
+func DIDBase(did string) (string, error) {
+	if err := validateDidSyntax(did); err != nil {
+		return "", err
+	}
+
+	ary := strings.Split(did, ":")
+	labels := []string{}
+	for i := len(ary) - 1; i >= 3; i-- {
+		// validated by validateDidSyntax
+		id, _ := decodeSubIdentifier(ary[i])
+		labels = append(labels, LabelBase32.encode(id))
+	}
+
+	domain, _ := decodeDomain(ary[2])
+	if len(labels) == 0 {
+		return dns.Fqdn(domain), nil
+	}
+
+	base := fmt.Sprintf("%s.%s._did.%s", strings.Join(labels, "."), subLabel, dns.Fqdn(domain))
+	if _, ok := dns.IsDomainName(base); !ok || len(base) > 255-len("_did.") {
+		return "", fmt.Errorf("%w: too long sub-identifiers; did = %s", ErrInvalidDID, did)
+	}
+
+	return base, nil
+}
+
+// decodeSubIdentifier percent-decodes the sub-identifier, which consists of the idchars of the DID syntax.
+func decodeSubIdentifier(s string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("empty sub-identifier")
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isIDChar(s[i]) && s[i] != '%' {
+			return "", fmt.Errorf("invalid character in sub-identifier; got = %s", s)
+		}
+	}
+
+	id, err := url.PathUnescape(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid percent-encoding in sub-identifier; got = %s", s)
+	}
+	if len(id) > maxSubIdentifierSize {
+		return "", fmt.Errorf("too long sub-identifier; got = %s, max = %d bytes", s, maxSubIdentifierSize)
+	}
+
+	return id, nil
+}
+
+// isIDChar reports whether the character is the idchar of the DID syntax other than the percent-encoding.
+func isIDChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '.' || c == '-' || c == '_'
+}
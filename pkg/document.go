@@ -0,0 +1,489 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DIDDocument is the typed representation of the DID document defined in DID Core.
+// The properties which are not defined here are kept in Extensions,
+// so that the document can be converted back to the Node without loss.
+type DIDDocument struct {
+	Context              interface{}                `json:"@context,omitempty"`
+	ID                   string                     `json:"id"`
+	Controller           *StringOrSet               `json:"controller,omitempty"`
+	AlsoKnownAs          []string                   `json:"alsoKnownAs,omitempty"`
+	VerificationMethod   []VerificationMethod       `json:"verificationMethod,omitempty"`
+	Authentication       []VerificationRelationship `json:"authentication,omitempty"`
+	AssertionMethod      []VerificationRelationship `json:"assertionMethod,omitempty"`
+	KeyAgreement         []VerificationRelationship `json:"keyAgreement,omitempty"`
+	CapabilityInvocation []VerificationRelationship `json:"capabilityInvocation,omitempty"`
+	CapabilityDelegation []VerificationRelationship `json:"capabilityDelegation,omitempty"`
+	Service              []Service                  `json:"service,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// VerificationMethod is the verification method of the DID document.
+type VerificationMethod struct {
+	ID                 string                 `json:"id"`
+	Type               string                 `json:"type"`
+	Controller         string                 `json:"controller"`
+	PublicKeyMultibase string                 `json:"publicKeyMultibase,omitempty"`
+	PublicKeyJwk       map[string]interface{} `json:"publicKeyJwk,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// VerificationRelationship is the entry of the verification relationships such as authentication.
+// The entry either refers to the verification method by its id, or embeds the verification method.
+type VerificationRelationship struct {
+	Reference string
+	Embedded  *VerificationMethod
+}
+
+// Service is the service of the DID document.
+type Service struct {
+	ID              string          `json:"id"`
+	Type            *StringOrSet    `json:"type,omitempty"`
+	ServiceEndpoint ServiceEndpoint `json:"serviceEndpoint"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// ServiceEndpoint is the endpoint of the service, which is a URI string, a map or a set of them.
+// Only one of the fields is set.
+type ServiceEndpoint struct {
+	URI string
+	Map map[string]interface{}
+	Set []interface{}
+}
+
+// StringOrSet is the property which is either a string or a set of strings.
+// Only one of the fields is set, and it is serialized in the shape it is parsed from,
+// so that a set of one string stays a set.
+type StringOrSet struct {
+	Value string
+	Set   []string
+}
+
+// StringOrSetOf returns the string if only one value is given, or the set of the values otherwise.
+func StringOrSetOf(values ...string) *StringOrSet {
+	if len(values) == 1 {
+		return &StringOrSet{Value: values[0]}
+	}
+
+	return &StringOrSet{Set: append([]string{}, values...)}
+}
+
+// Values returns the string or the strings of the set.
+func (s *StringOrSet) Values() []string {
+	if s == nil {
+		return nil
+	}
+	if s.Set != nil {
+		return s.Set
+	}
+
+	return []string{s.Value}
+}
+
+// NewDIDDocument converts the document tree into the typed DID document.
+func NewDIDDocument(n *Node) (*DIDDocument, error) {
+	bytes, err := n.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &DIDDocument{}
+	if err := json.Unmarshal(bytes, doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Node converts the typed DID document into the document tree.
+func (d *DIDDocument) Node() (*Node, error) {
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateFromJSON(bytes)
+}
+
+func (d DIDDocument) MarshalJSON() ([]byte, error) {
+	type alias DIDDocument
+	return marshalWithExtensions(alias(d), d.Extensions)
+}
+
+func (d *DIDDocument) UnmarshalJSON(bytes []byte) error {
+	type alias DIDDocument
+	a := alias{}
+	ext, err := unmarshalWithExtensions(bytes, &a)
+	if err != nil {
+		return err
+	}
+
+	*d = DIDDocument(a)
+	d.Extensions = ext
+	return nil
+}
+
+func (v VerificationMethod) MarshalJSON() ([]byte, error) {
+	type alias VerificationMethod
+	return marshalWithExtensions(alias(v), v.Extensions)
+}
+
+func (v *VerificationMethod) UnmarshalJSON(bytes []byte) error {
+	type alias VerificationMethod
+	a := alias{}
+	ext, err := unmarshalWithExtensions(bytes, &a)
+	if err != nil {
+		return err
+	}
+
+	*v = VerificationMethod(a)
+	v.Extensions = ext
+	return nil
+}
+
+func (s Service) MarshalJSON() ([]byte, error) {
+	type alias Service
+	return marshalWithExtensions(alias(s), s.Extensions)
+}
+
+func (s *Service) UnmarshalJSON(bytes []byte) error {
+	type alias Service
+	a := alias{}
+	ext, err := unmarshalWithExtensions(bytes, &a)
+	if err != nil {
+		return err
+	}
+
+	*s = Service(a)
+	s.Extensions = ext
+	return nil
+}
+
+func (r VerificationRelationship) MarshalJSON() ([]byte, error) {
+	if r.Embedded != nil {
+		return json.Marshal(r.Embedded)
+	}
+
+	return json.Marshal(r.Reference)
+}
+
+func (r *VerificationRelationship) UnmarshalJSON(bytes []byte) error {
+	if err := json.Unmarshal(bytes, &r.Reference); err == nil {
+		r.Embedded = nil
+		return nil
+	}
+
+	r.Reference = ""
+	r.Embedded = &VerificationMethod{}
+	return json.Unmarshal(bytes, r.Embedded)
+}
+
+// ID returns the id of the verification method which the relationship refers to or embeds.
+func (r VerificationRelationship) ID() string {
+	if r.Embedded != nil {
+		return r.Embedded.ID
+	}
+
+	return r.Reference
+}
+
+func (e ServiceEndpoint) MarshalJSON() ([]byte, error) {
+	switch {
+	case e.Map != nil:
+		return json.Marshal(e.Map)
+	case e.Set != nil:
+		return json.Marshal(e.Set)
+	default:
+		return json.Marshal(e.URI)
+	}
+}
+
+func (e *ServiceEndpoint) UnmarshalJSON(bytes []byte) error {
+	*e = ServiceEndpoint{}
+
+	var v interface{}
+	if err := json.Unmarshal(bytes, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		e.URI = v
+	case map[string]interface{}:
+		e.Map = v
+	case []interface{}:
+		e.Set = v
+	default:
+		return fmt.Errorf("invalid service endpoint; got = %s", string(bytes))
+	}
+
+	return nil
+}
+
+func (s StringOrSet) MarshalJSON() ([]byte, error) {
+	if s.Set != nil {
+		return json.Marshal(s.Set)
+	}
+
+	return json.Marshal(s.Value)
+}
+
+func (s *StringOrSet) UnmarshalJSON(bytes []byte) error {
+	*s = StringOrSet{}
+
+	if err := json.Unmarshal(bytes, &s.Value); err == nil {
+		return nil
+	}
+
+	set := []string{}
+	if err := json.Unmarshal(bytes, &set); err != nil {
+		return err
+	}
+
+	s.Set = set
+	return nil
+}
+
+// marshalWithExtensions marshals the struct and merges the extension properties into it.
+func marshalWithExtensions(v interface{}, ext map[string]interface{}) ([]byte, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ext) == 0 {
+		return bytes, nil
+	}
+
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(bytes, &m); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for k := range ext {
+		if _, ok := m[k]; ok {
+			return nil, fmt.Errorf("extension property conflicts with the defined one; key = %s", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// append the extensions after the defined properties to keep their order
+	buf := bytes[:len(bytes)-1]
+	for i, k := range keys {
+		if len(m) > 0 || i > 0 {
+			buf = append(buf, ',')
+		}
+
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(ext[k])
+		if err != nil {
+			return nil, err
+		}
+
+		buf = append(append(append(buf, kb...), ':'), vb...)
+	}
+
+	return append(buf, '}'), nil
+}
+
+// unmarshalWithExtensions unmarshals the struct and returns the properties not defined in it.
+func unmarshalWithExtensions(bytes []byte, v interface{}) (map[string]interface{}, error) {
+	if err := json.Unmarshal(bytes, v); err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(bytes, &m); err != nil {
+		return nil, err
+	}
+
+	for _, name := range jsonFieldNames(reflect.TypeOf(v).Elem()) {
+		delete(m, name)
+	}
+
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	return m, nil
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Relationship returns the verification relationship of the given name (e.g. authentication).
+// It returns nil if the name is not a verification relationship.
+func (d *DIDDocument) Relationship(name string) *[]VerificationRelationship {
+	switch name {
+	case "authentication":
+		return &d.Authentication
+	case "assertionMethod":
+		return &d.AssertionMethod
+	case "keyAgreement":
+		return &d.KeyAgreement
+	case "capabilityInvocation":
+		return &d.CapabilityInvocation
+	case "capabilityDelegation":
+		return &d.CapabilityDelegation
+	default:
+		return nil
+	}
+}
+
+// AbsoluteID resolves the relative DID URL (e.g. #key-1) against the id of the document.
+func (d *DIDDocument) AbsoluteID(id string) string {
+	if strings.HasPrefix(id, "#") || strings.HasPrefix(id, "?") {
+		return d.ID + id
+	}
+
+	return id
+}
+
+// AddVerificationMethod adds the verification method and refers to it from the given relationships.
+func (d *DIDDocument) AddVerificationMethod(vm VerificationMethod, relationships ...string) error {
+	for _, rel := range relationships {
+		if d.Relationship(rel) == nil {
+			return fmt.Errorf("invalid verification relationship; got = %s", rel)
+		}
+	}
+
+	abs := d.AbsoluteID(vm.ID)
+	for _, v := range d.VerificationMethod {
+		if d.AbsoluteID(v.ID) == abs {
+			return fmt.Errorf("verification method already exists; id = %s", vm.ID)
+		}
+	}
+
+	d.VerificationMethod = append(d.VerificationMethod, vm)
+	for _, rel := range relationships {
+		r := d.Relationship(rel)
+		*r = append(*r, VerificationRelationship{Reference: vm.ID})
+	}
+
+	return nil
+}
+
+// RemoveVerificationMethod removes the verification method and the references to it.
+// If replaceWith is not empty, the references are replaced with it instead of being removed,
+// which keeps the relationships of the rotated key.
+func (d *DIDDocument) RemoveVerificationMethod(id string, replaceWith string) error {
+	abs := d.AbsoluteID(id)
+
+	found := false
+	vms := []VerificationMethod{}
+	for _, v := range d.VerificationMethod {
+		if d.AbsoluteID(v.ID) == abs {
+			found = true
+			continue
+		}
+		vms = append(vms, v)
+	}
+
+	for _, rel := range verificationRelationships {
+		r := d.Relationship(rel)
+		entries := []VerificationRelationship{}
+		for _, e := range *r {
+			if d.AbsoluteID(e.ID()) != abs {
+				entries = append(entries, e)
+				continue
+			}
+
+			found = true
+			if replaceWith != "" {
+				entries = append(entries, VerificationRelationship{Reference: replaceWith})
+			}
+		}
+		*r = entries
+	}
+
+	if !found {
+		return fmt.Errorf("verification method not found; id = %s", id)
+	}
+
+	d.VerificationMethod = vms
+	return nil
+}
+
+// ParseServiceEndpoint parses the service endpoint given as a URI, or as a JSON map or set.
+func ParseServiceEndpoint(s string) (ServiceEndpoint, error) {
+	e := ServiceEndpoint{}
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+		e.URI = s
+		return e, nil
+	}
+
+	err := json.Unmarshal([]byte(s), &e)
+	return e, err
+}
+
+// FindService returns the index of the service with the id, or -1 if not found.
+func (d *DIDDocument) FindService(id string) int {
+	abs := d.AbsoluteID(id)
+	for i, s := range d.Service {
+		if d.AbsoluteID(s.ID) == abs {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// AddService adds the service. The id must be unique in the document.
+func (d *DIDDocument) AddService(s Service) error {
+	abs := d.AbsoluteID(s.ID)
+	if d.FindService(s.ID) != -1 {
+		return fmt.Errorf("service already exists; id = %s", s.ID)
+	}
+
+	for _, vm := range d.VerificationMethod {
+		if d.AbsoluteID(vm.ID) == abs {
+			return fmt.Errorf("id is already used by the verification method; id = %s", s.ID)
+		}
+	}
+
+	d.Service = append(d.Service, s)
+	return nil
+}
+
+// RemoveService removes the service with the id.
+func (d *DIDDocument) RemoveService(id string) error {
+	i := d.FindService(id)
+	if i == -1 {
+		return fmt.Errorf("service not found; id = %s", id)
+	}
+
+	d.Service = append(d.Service[:i], d.Service[i+1:]...)
+	return nil
+}
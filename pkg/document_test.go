@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDIDDocumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{name: "controller string", doc: `{"controller":"did:dnssec:a.example","id":"did:dnssec:example.com"}`},
+		{name: "controller set of one", doc: `{"controller":["did:dnssec:a.example"],"id":"did:dnssec:example.com"}`},
+		{name: "controller set", doc: `{"controller":["did:dnssec:a.example","did:dnssec:b.example"],"id":"did:dnssec:example.com"}`},
+		{name: "service type set of one", doc: `{"id":"did:dnssec:example.com","service":[{"id":"#a","serviceEndpoint":"https://a.example","type":["A"]}]}`},
+		{name: "service without type", doc: `{"id":"did:dnssec:example.com","service":[{"id":"#a","serviceEndpoint":"https://a.example"}]}`},
+		{name: "extensions", doc: `{"id":"did:dnssec:example.com","x":{"y":[1,"z"]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := NewDIDDocument(testDocument(t, tt.doc))
+			if err != nil {
+				t.Fatalf("NewDIDDocument() error = %v", err)
+			}
+
+			n, err := doc.Node()
+			if err != nil {
+				t.Fatalf("Node() error = %v", err)
+			}
+			got, err := n.CanonicalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.doc {
+				t.Errorf("Node() = %s, want %s", got, tt.doc)
+			}
+		})
+	}
+}
+
+func TestStringOrSet(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{values: []string{"a"}, want: `"a"`},
+		{values: []string{"a", "b"}, want: `["a","b"]`},
+		{values: []string{}, want: `[]`},
+	}
+
+	for _, tt := range tests {
+		s := StringOrSetOf(tt.values...)
+		got, err := json.Marshal(s)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("json.Marshal(StringOrSetOf(%v)) = %s, %v, want %s", tt.values, got, err, tt.want)
+		}
+
+		parsed := &StringOrSet{}
+		if err := json.Unmarshal(got, parsed); err != nil || len(parsed.Values()) != len(tt.values) {
+			t.Errorf("json.Unmarshal(%s) = %v, %v, want %v", got, parsed.Values(), err, tt.values)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The errors returned by the resolution. Use errors.Is to test them,
+// and ErrorCode to map them onto the DID resolution error codes.
+var (
+	// ErrNotFound means the DID, or the requested version of its document, does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidDID means the DID does not conform to the DID syntax.
+	ErrInvalidDID = errors.New("invalid did")
+	// ErrInvalidDIDURL means the DID URL has the path, fragment or query which is not supported.
+	ErrInvalidDIDURL = errors.New("invalid did url")
+	// ErrMethodNotSupported means the DID is not of the dnssec method.
+	ErrMethodNotSupported = errors.New("did method not supported")
+	// ErrBogus means the recursive resolver failed to validate the DNSSEC signatures of the answer.
+	ErrBogus = errors.New("dnssec validation failed")
+	// ErrTimeout means no resolver answered in time.
+	ErrTimeout = errors.New("lookup timed out")
+	// ErrInsecure means an answer, including the ones along the chain of the aliases,
+	// is not validated as secure while DNSSEC is required; see SetRequireDNSSEC.
+	ErrInsecure = errors.New("dnssec not secure")
+	// ErrAliasLoop means the chain of the CNAME and DNAME aliases comes back to a name.
+	ErrAliasLoop = errors.New("alias loop")
+)
+
+// RecordFormatError is the error for the TXT records which do not conform to the wire format.
+type RecordFormatError struct {
+	// Name is the owner name of the records.
+	Name string
+	// Data is the content of the records joined with spaces.
+	Data string
+	Err  error
+}
+
+func (e *RecordFormatError) Error() string {
+	return fmt.Sprintf("invalid record; name = %s, data = %s: %v", e.Name, e.Data, e.Err)
+}
+
+func (e *RecordFormatError) Unwrap() error {
+	return e.Err
+}
+
+// recordFormatError wraps the error with RecordFormatError for the records of the name,
+// unless it is already the one for the records deeper in the tree or the limit is exceeded.
+func recordFormatError(name string, txt []string, err error) error {
+	var rfe *RecordFormatError
+	if errors.As(err, &rfe) || errors.Is(err, ErrLimitExceeded) {
+		return err
+	}
+
+	return &RecordFormatError{Name: name, Data: strings.Join(txt, " "), Err: err}
+}
+
+// ErrorCode returns the DID resolution error code of the error:
+// invalidDid, invalidDidUrl, methodNotSupported, notFound, invalidDidDocument or internalError.
+// The records which do not conform to the wire format are invalidDidDocument.
+// It returns the empty string for nil.
+func ErrorCode(err error) string {
+	var rfe *RecordFormatError
+	var rse *RecordSyntaxError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrMethodNotSupported):
+		return "methodNotSupported"
+	case errors.Is(err, ErrInvalidDID):
+		return "invalidDid"
+	case errors.Is(err, ErrInvalidDIDURL):
+		return "invalidDidUrl"
+	case errors.Is(err, ErrNotFound):
+		return "notFound"
+	case errors.As(err, &rfe), errors.As(err, &rse):
+		return "invalidDidDocument"
+	default:
+		return "internalError"
+	}
+}
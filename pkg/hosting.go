@@ -0,0 +1,293 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxDocumentSize is the maximum size of the request body of the hosting API.
+const maxDocumentSize = 1 << 20
+
+// Hosting is the REST API for the tenants to manage their DID documents served by the server:
+//
+//	GET    /dids/<did>  returns the document.
+//	PUT    /dids/<did>  creates or replaces the document with the one in the body.
+//	PATCH  /dids/<did>  applies the JSON Patch (application/json-patch+json) or
+//	                    the JSON Merge Patch (application/merge-patch+json) to the document.
+//	DELETE /dids/<did>  deletes the document.
+//
+// Every written document is validated, persisted in the store, and then served by swapping the zone,
+// so that the served records change atomically. The failed writes change neither.
+type Hosting struct {
+	store   *Store
+	tenants []*Tenant
+	build   func(docs map[string]*Node) (*Zone, error)
+	server  *Server
+	nonces  *nonceCache
+
+	// mu serializes the writes, and guards docs, which are the documents being served.
+	mu   sync.Mutex
+	docs map[string]*Node
+}
+
+// NewHosting returns the API managing the documents in the store, and the server serving them.
+// The build argument builds the zone from the documents, mapping their bases to them.
+func NewHosting(store *Store, tenants []*Tenant, build func(docs map[string]*Node) (*Zone, error)) (*Hosting, error) {
+	docs, err := store.All()
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := build(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hosting{
+		store:   store,
+		tenants: tenants,
+		build:   build,
+		server:  NewServer(zone),
+		nonces:  newNonceCache(),
+		docs:    docs,
+	}, nil
+}
+
+// Server returns the server of the documents.
+func (h *Hosting) Server() *Server {
+	return h.server
+}
+
+// Rebuild builds the zone of the documents again, and swaps it, such as to renew the signatures.
+func (h *Hosting) Rebuild() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	zone, err := h.build(h.docs)
+	if err != nil {
+		return err
+	}
+
+	h.server.SetZone(zone)
+	return nil
+}
+
+// httpError is the error of the request with the status code.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func statusError(status int, err error) error {
+	return &httpError{status: status, err: err}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Hosting) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the DID is taken from the escaped path, since its percent-encoded idchars must not be decoded
+	did, ok := strings.CutPrefix(r.URL.EscapedPath(), "/dids/")
+	if !ok || did == "" {
+		writeHTTPError(w, statusError(http.StatusNotFound, fmt.Errorf("not found; path = %s", r.URL.EscapedPath())))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDocumentSize))
+	if err != nil {
+		writeHTTPError(w, statusError(http.StatusRequestEntityTooLarge, err))
+		return
+	}
+
+	tenant, err := authenticate(h.tenants, h.nonces, r, body)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeHTTPError(w, statusError(http.StatusUnauthorized, err))
+		return
+	}
+
+	base, err := DIDBase(did)
+	if err != nil {
+		writeHTTPError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+	if !tenant.Manages(did) {
+		writeHTTPError(w, statusError(http.StatusForbidden, fmt.Errorf("did not managed by the tenant; tenant = %s, did = %s", tenant.Name, did)))
+		return
+	}
+
+	status := http.StatusOK
+	var doc *Node
+	switch r.Method {
+	case http.MethodGet:
+		doc, err = h.get(base)
+	case http.MethodPut:
+		doc, status, err = h.put(base, body)
+	case http.MethodPatch:
+		doc, err = h.patch(base, r.Header.Get("Content-Type"), body)
+	case http.MethodDelete:
+		err = h.delete(base)
+		status = http.StatusNoContent
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		err = statusError(http.StatusMethodNotAllowed, fmt.Errorf("method not allowed; got = %s", r.Method))
+	}
+	if err != nil {
+		Logger().Info("hosting request failed", "tenant", tenant.Name, "method", r.Method, "did", did, "error", err)
+		writeHTTPError(w, err)
+		return
+	}
+	Logger().Info("hosting request", "tenant", tenant.Name, "method", r.Method, "did", did)
+
+	if doc == nil {
+		w.WriteHeader(status)
+		return
+	}
+	bytes, err := doc.JSON()
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/did+json")
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
+
+func (h *Hosting) get(base string) (*Node, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	doc, ok := h.docs[base]
+	if !ok {
+		return nil, statusError(http.StatusNotFound, fmt.Errorf("%w; base = %s", ErrNotFound, base))
+	}
+	return doc, nil
+}
+
+func (h *Hosting) put(base string, body []byte) (*Node, int, error) {
+	doc, err := CreateFromJSON(body)
+	if err != nil {
+		return nil, 0, statusError(http.StatusBadRequest, err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := http.StatusOK
+	if _, ok := h.docs[base]; !ok {
+		status = http.StatusCreated
+	}
+	if err := h.commit(base, doc); err != nil {
+		return nil, 0, err
+	}
+	return doc, status, nil
+}
+
+func (h *Hosting) patch(base string, contentType string, body []byte) (*Node, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	old, ok := h.docs[base]
+	if !ok {
+		return nil, statusError(http.StatusNotFound, fmt.Errorf("%w; base = %s", ErrNotFound, base))
+	}
+
+	// the patch is applied to a copy, since the served document must not change if the patch fails
+	bytes, err := old.JSON()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := CreateFromJSON(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json-patch+json":
+		err = doc.ApplyPatch(body)
+	case "application/merge-patch+json":
+		err = doc.ApplyMergePatch(body)
+	default:
+		return nil, statusError(http.StatusUnsupportedMediaType, fmt.Errorf(
+			"unsupported patch type; got = %s, expected = application/json-patch+json || application/merge-patch+json", contentType))
+	}
+	if err != nil {
+		return nil, statusError(http.StatusUnprocessableEntity, err)
+	}
+
+	if err := h.commit(base, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (h *Hosting) delete(base string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.docs[base]; !ok {
+		return statusError(http.StatusNotFound, fmt.Errorf("%w; base = %s", ErrNotFound, base))
+	}
+
+	return h.commit(base, nil)
+}
+
+// commit validates the document, stores it, and swaps the zone with the one of the documents
+// including it. The nil document deletes the one under the base. It must be called with h.mu held.
+func (h *Hosting) commit(base string, doc *Node) error {
+	docs := map[string]*Node{}
+	for b, d := range h.docs {
+		docs[b] = d
+	}
+
+	if doc != nil {
+		if err := Validate(doc, base); err != nil {
+			return statusError(http.StatusUnprocessableEntity, err)
+		}
+		docs[base] = doc
+	} else {
+		delete(docs, base)
+	}
+
+	// the zone is built before the document is stored, so that nothing is changed if it fails
+	zone, err := h.build(docs)
+	if err != nil {
+		return statusError(http.StatusUnprocessableEntity, err)
+	}
+
+	if doc != nil {
+		err = h.store.Put(base, doc)
+	} else {
+		err = h.store.Delete(base)
+	}
+	if err != nil {
+		return err
+	}
+
+	h.server.SetZone(zone)
+	h.docs = docs
+	return nil
+}
+
+// writeHTTPError writes the error as `{"error": "<message>"}` with its status code,
+// which is 500 unless the error is an httpError.
+func writeHTTPError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
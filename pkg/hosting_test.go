@@ -0,0 +1,239 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// testHostingBuild builds the zone of example.com. from the documents.
+// It fails for the documents with the "fail" property, as the builds failing after the validation.
+func testHostingBuild(docs map[string]*Node) (*Zone, error) {
+	rrs := []*ResorceRecord{}
+	for base, doc := range docs {
+		if doc.GetChildValue("fail") != nil {
+			return nil, errors.New("build failed")
+		}
+		r, err := doc.TreeRRs(base, LabelBase64)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, r...)
+	}
+
+	return NewZone("example.com.", rrs, ZoneOptions{})
+}
+
+// testHosting returns the hosting API of the store in the directory, with the tenants of testTenants.
+func testHosting(t *testing.T, dir string) *Hosting {
+	t.Helper()
+
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	tenants, _, _ := testTenants(t)
+	h, err := NewHosting(store, tenants, testHostingBuild)
+	if err != nil {
+		t.Fatalf("NewHosting() error = %v", err)
+	}
+	return h
+}
+
+// serveHosting sends the request to the API with alice's token.
+func serveHosting(h *Hosting, method string, path string, contentType string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "https://api.example.com"+path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer alice-token")
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// servedTXT returns the TXT records served at the name.
+func servedTXT(h *Hosting, name string) []string {
+	txt := []string{}
+	for _, rr := range h.Server().Zone().answer(testQuery(name, dns.TypeTXT, false)).Answer {
+		if rr, ok := rr.(*dns.TXT); ok {
+			txt = append(txt, strings.Join(rr.Txt, ""))
+		}
+	}
+	return txt
+}
+
+func TestHosting(t *testing.T) {
+	h := testHosting(t, t.TempDir())
+	backend := useBackend(t)
+	const path = "/dids/did:dnssec:a.example.com"
+
+	steps := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		// served is the value of "n" served in the zone after the request, or empty if not served
+		served string
+	}{
+		{name: "get missing", method: http.MethodGet, status: http.StatusNotFound},
+		{name: "create", method: http.MethodPut, body: `{"id": "did:dnssec:a.example.com", "n": 1}`, status: http.StatusCreated, served: "1"},
+		{name: "get", method: http.MethodGet, status: http.StatusOK, served: "1"},
+		{name: "replace", method: http.MethodPut, body: `{"id": "did:dnssec:a.example.com", "n": 2}`, status: http.StatusOK, served: "2"},
+		{name: "merge patch", method: http.MethodPatch, contentType: "application/merge-patch+json", body: `{"n": 3}`, status: http.StatusOK, served: "3"},
+		{name: "json patch", method: http.MethodPatch, contentType: "application/json-patch+json", body: `[{"op": "replace", "path": "/n", "value": 4}]`, status: http.StatusOK, served: "4"},
+		{name: "unsupported patch", method: http.MethodPatch, contentType: "application/json", body: `{"n": 5}`, status: http.StatusUnsupportedMediaType, served: "4"},
+		{name: "method not allowed", method: http.MethodPost, body: `{}`, status: http.StatusMethodNotAllowed, served: "4"},
+		{name: "delete", method: http.MethodDelete, status: http.StatusNoContent},
+		{name: "delete missing", method: http.MethodDelete, status: http.StatusNotFound},
+	}
+
+	for _, s := range steps {
+		w := serveHosting(h, s.method, path, s.contentType, s.body)
+		if w.Code != s.status {
+			t.Fatalf("%s: status = %d, want %d; body = %s", s.name, w.Code, s.status, w.Body)
+		}
+
+		// the document is resolved from the zone being served
+		backend.zones = []*Zone{h.Server().Zone()}
+		n := ""
+		if doc, err := Resolve("did:dnssec:a.example.com"); err == nil {
+			n = doc.GetChildValue("n").String()
+		} else if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: Resolve() error = %v", s.name, err)
+		}
+		if n != s.served {
+			t.Fatalf("%s: served n = %q, want %q", s.name, n, s.served)
+		}
+	}
+}
+
+func TestHostingTenantScope(t *testing.T) {
+	h := testHosting(t, t.TempDir())
+
+	tests := []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+	}{
+		{name: "own domain", path: "/dids/did:dnssec:a.example.com", auth: "Bearer alice-token", status: http.StatusCreated},
+		{name: "own subdomain", path: "/dids/did:dnssec:x.a.example.com", auth: "Bearer alice-token", status: http.StatusCreated},
+		{name: "domain of another tenant", path: "/dids/did:dnssec:b.example.com", auth: "Bearer alice-token", status: http.StatusForbidden},
+		{name: "parent domain", path: "/dids/did:dnssec:example.com", auth: "Bearer alice-token", status: http.StatusForbidden},
+		{name: "wrong token", path: "/dids/did:dnssec:a.example.com", auth: "Bearer bob-token", status: http.StatusUnauthorized},
+		{name: "no authorization", path: "/dids/did:dnssec:a.example.com", status: http.StatusUnauthorized},
+		{name: "invalid did", path: "/dids/did:web:a.example.com", auth: "Bearer alice-token", status: http.StatusBadRequest},
+		{name: "no did", path: "/dids/", auth: "Bearer alice-token", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			did := strings.TrimPrefix(tt.path, "/dids/")
+			r := httptest.NewRequest(http.MethodPut, "https://api.example.com"+tt.path, strings.NewReader(`{"id": "`+did+`"}`))
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d; body = %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestHostingEscapedPath(t *testing.T) {
+	h := testHosting(t, t.TempDir())
+
+	// the percent-encoded colon is a part of the sub-identifier, not a separator
+	const did = "did:dnssec:a.example.com:x%3Ay"
+	if w := serveHosting(h, http.MethodPut, "/dids/"+did, "", `{"id": "`+did+`"}`); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body = %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	base, _ := DIDBase(did)
+	decoded, _ := DIDBase("did:dnssec:a.example.com:x:y")
+	if _, err := h.get(base); err != nil {
+		t.Errorf("get(%s) error = %v", base, err)
+	}
+	if _, err := h.get(decoded); err == nil {
+		t.Errorf("get(%s) succeeded; the document is stored under the decoded DID", decoded)
+	}
+}
+
+func TestHostingRollback(t *testing.T) {
+	dir := t.TempDir()
+	h := testHosting(t, dir)
+	const path = "/dids/did:dnssec:a.example.com"
+
+	if w := serveHosting(h, http.MethodPut, path, "", `{"id": "did:dnssec:a.example.com", "n": 1}`); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body = %s", w.Code, http.StatusCreated, w.Body)
+	}
+	served := servedTXT(h, "_did.a.example.com.")
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "invalid json", method: http.MethodPut, body: `{`, status: http.StatusBadRequest},
+		{name: "id of another did", method: http.MethodPut, body: `{"id": "did:dnssec:x.a.example.com"}`, status: http.StatusUnprocessableEntity},
+		{name: "invalid document", method: http.MethodPut, body: `{"id": "did:dnssec:a.example.com", "controller": 1}`, status: http.StatusUnprocessableEntity},
+		{name: "build failure", method: http.MethodPut, body: `{"id": "did:dnssec:a.example.com", "fail": true}`, status: http.StatusUnprocessableEntity},
+		{name: "patch to invalid", method: http.MethodPatch, contentType: "application/merge-patch+json", body: `{"id": null}`, status: http.StatusUnprocessableEntity},
+		{name: "failing patch", method: http.MethodPatch, contentType: "application/json-patch+json", body: `[{"op": "remove", "path": "/x"}]`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveHosting(h, tt.method, path, tt.contentType, tt.body); w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body = %s", w.Code, tt.status, w.Body)
+			}
+
+			// neither the served document, the store nor the zone is changed
+			doc, err := h.get("a.example.com.")
+			if err != nil || doc.GetChildValue("n").String() != "1" {
+				t.Errorf("get() = %v, %v; want the previous document", doc, err)
+			}
+			stored, err := h.store.Get("a.example.com.")
+			if err != nil || stored.GetChildValue("n").String() != "1" {
+				t.Errorf("store.Get() = %v, %v; want the previous document", stored, err)
+			}
+			if got := servedTXT(h, "_did.a.example.com."); strings.Join(got, "\n") != strings.Join(served, "\n") {
+				t.Errorf("served TXT = %v, want %v", got, served)
+			}
+		})
+	}
+}
+
+func TestHostingPersistence(t *testing.T) {
+	dir := t.TempDir()
+	h := testHosting(t, dir)
+
+	serveHosting(h, http.MethodPut, "/dids/did:dnssec:a.example.com", "", `{"id": "did:dnssec:a.example.com", "n": 1}`)
+	serveHosting(h, http.MethodPut, "/dids/did:dnssec:x.a.example.com", "", `{"id": "did:dnssec:x.a.example.com"}`)
+	serveHosting(h, http.MethodDelete, "/dids/did:dnssec:x.a.example.com", "", "")
+	served := servedTXT(h, "_did.a.example.com.")
+
+	// the documents are served again from the store after the restart
+	restarted := testHosting(t, dir)
+	if w := serveHosting(restarted, http.MethodGet, "/dids/did:dnssec:a.example.com", "", ""); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d; body = %s", w.Code, http.StatusOK, w.Body)
+	}
+	if w := serveHosting(restarted, http.MethodGet, "/dids/did:dnssec:x.a.example.com", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status of the deleted document = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got := servedTXT(restarted, "_did.a.example.com."); len(got) == 0 || strings.Join(got, "\n") != strings.Join(served, "\n") {
+		t.Errorf("served TXT = %v, want %v", got, served)
+	}
+}
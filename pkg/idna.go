@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// The internationalized domain names are converted into the A-labels (`xn--...`) with the UTS #46
+// lookup profile of IDNA2008, so that the queries and the zone files only have ASCII names.
+// Since the DID syntax does not allow non-ASCII characters, the U-labels in a DID are percent-encoded
+// in UTF-8 (e.g. `did:dnssec:%E4%BE%8B.jp`), and the canonical form of the DID has the A-labels.
+
+// NormalizeDomain returns the FQDN of the domain in lowercase A-labels,
+// under which the records are published. The domain may be in U-labels.
+// The A-labels are validated as well, so that the ones which do not decode into valid U-labels,
+// such as `xn--zz`, are rejected.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+
+	a, err := toASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain name; got = %s: %v", domain, err)
+	}
+	if _, ok := dns.IsDomainName(a); !ok || a == "" {
+		return "", fmt.Errorf("invalid domain name; got = %s", domain)
+	}
+
+	return dns.Fqdn(a), nil
+}
+
+// toASCII converts the domain into the A-labels with the lookup profile.
+// The labels starting with an underscore, such as _sub, are not hostnames and kept as they are,
+// since the profile does not allow them; the other labels are converted one by one in that case.
+func toASCII(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	underscored := false
+	for _, label := range labels {
+		underscored = underscored || strings.HasPrefix(label, "_")
+	}
+	if !underscored {
+		return idna.Lookup.ToASCII(domain)
+	}
+
+	for i, label := range labels {
+		if strings.HasPrefix(label, "_") && isASCII(label) {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+
+		a, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = a
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// DIDFromDomain returns the canonical DID of the domain, which may be in U-labels.
+func DIDFromDomain(domain string) (string, error) {
+	fqdn, err := NormalizeDomain(domain)
+	if err != nil {
+		return "", err
+	}
+
+	return CanonicalDID("did:dnssec:" + strings.TrimSuffix(fqdn, "."))
+}
+
+// CanonicalDID returns the canonical form of the DID: the domain in lowercase A-labels,
+// and the sub-identifiers percent-encoded only where the DID syntax requires, with uppercase hex digits.
+// The DIDs with the same canonical form are the same DID, published under the same name.
+func CanonicalDID(did string) (string, error) {
+	if err := validateDidSyntax(did); err != nil {
+		return "", err
+	}
+
+	ary := strings.Split(did, ":")
+	// validated by validateDidSyntax
+	ary[2], _ = decodeDomain(ary[2])
+	for i := 3; i < len(ary); i++ {
+		id, _ := decodeSubIdentifier(ary[i])
+		ary[i] = encodeIDChars(id)
+	}
+
+	return strings.Join(ary, ":"), nil
+}
+
+// decodeDomain percent-decodes the domain of the DID, and converts it into lowercase A-labels.
+func decodeDomain(s string) (string, error) {
+	for i := 0; i < len(s); i++ {
+		if !isIDChar(s[i]) && s[i] != '%' {
+			return "", fmt.Errorf("invalid character in domain name, which must be percent-encoded; got = %s", s)
+		}
+	}
+
+	domain, err := url.PathUnescape(s)
+	if err != nil || !utf8.ValidString(domain) {
+		return "", fmt.Errorf("invalid percent-encoding in domain name; got = %s", s)
+	}
+
+	// the domain of the DID is not an FQDN, and has no trailing dot
+	a, err := idna.Lookup.ToASCII(domain)
+	if err != nil || a == "" || strings.HasSuffix(a, ".") {
+		return "", fmt.Errorf("invalid domain name; got = %s", s)
+	}
+
+	// the lookup profile does not check the empty labels and the lengths
+	if _, err := NormalizeDomain(a); err != nil {
+		return "", fmt.Errorf("invalid domain name; got = %s", s)
+	}
+
+	return a, nil
+}
+
+// encodeIDChars percent-encodes the bytes of s which are not the idchars of the DID syntax.
+func encodeIDChars(s string) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if isIDChar(s[i]) {
+			sb.WriteByte(s[i])
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", s[i])
+		}
+	}
+
+	return sb.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "example.com", want: "example.com."},
+		{in: "Example.COM.", want: "example.com."},
+		{in: "例.jp", want: "xn--fsq.jp."},
+		{in: "xn--fsq.jp", want: "xn--fsq.jp."},
+		{in: "XN--FSQ.JP", want: "xn--fsq.jp."},
+		{in: "Bücher.example", want: "xn--bcher-kva.example."},
+		{in: "faß.de", want: "xn--fa-hia.de."},
+		{in: "例。jp", want: "xn--fsq.jp."},
+		{in: "_sub._did.example.com", want: "_sub._did.example.com."},
+		{in: "c5h66p35._sub._did.例.jp", want: "c5h66p35._sub._did.xn--fsq.jp."},
+		{in: "xn--zz.example", wantErr: true},
+		{in: "xn--zz._sub.example", wantErr: true},
+		{in: "ab--cd.example", wantErr: true},
+		{in: "-example.com", wantErr: true},
+		{in: "example..com", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "a b.example", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := NormalizeDomain(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeDomain() = %s, error = %v, wantErr %v", got, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeDomain() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalDID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "did:dnssec:example.com", want: "did:dnssec:example.com"},
+		{in: "did:dnssec:EXAMPLE.com", want: "did:dnssec:example.com"},
+		{in: "did:dnssec:%E4%BE%8B.jp", want: "did:dnssec:xn--fsq.jp"},
+		{in: "did:dnssec:b%C3%BCcher.example", want: "did:dnssec:xn--bcher-kva.example"},
+		{in: "did:dnssec:example.com:a%20b", want: "did:dnssec:example.com:a%20b"},
+		{in: "did:dnssec:example.com:%61", want: "did:dnssec:example.com:a"},
+		{in: "did:dnssec:xn--zz.example", wantErr: true},
+		{in: "did:dnssec:%FF.jp", wantErr: true},
+		{in: "did:dnssec:a..b", wantErr: true},
+		{in: "did:dnssec:.example.com", wantErr: true},
+		{in: "did:dnssec:example.com.", wantErr: true},
+		{in: "did:dnssec:example.com%E3%80%82", wantErr: true},
+		{in: "did:dnssec:...", wantErr: true},
+		{in: "did:dnssec:.", wantErr: true},
+		{in: "did:dnssec:" + strings.Repeat("a", 64) + ".example", wantErr: true},
+		{in: "did:dnssec:" + strings.Repeat("a", 63) + ".example", want: "did:dnssec:" + strings.Repeat("a", 63) + ".example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := CanonicalDID(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CanonicalDID() = %s, error = %v, wantErr %v", got, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CanonicalDID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDIDBaseDomain(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "did:dnssec:example.com", want: "example.com."},
+		{in: "did:dnssec:%E4%BE%8B.jp", want: "xn--fsq.jp."},
+		{in: "did:dnssec:a..b", wantErr: true},
+		{in: "did:dnssec:.example.com", wantErr: true},
+		{in: "did:dnssec:example.com.", wantErr: true},
+		{in: "did:dnssec:...", wantErr: true},
+		{in: "did:dnssec:" + strings.Repeat("a", 64) + ".example", wantErr: true},
+		{in: "did:dnssec:" + strings.Repeat("a", 64) + ".example:x", wantErr: true},
+		{in: "did:dnssec:" + strings.Repeat("a", 63) + ".example", want: strings.Repeat("a", 63) + ".example."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := DIDBase(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DIDBase() = %s, error = %v, wantErr %v", got, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidDID) {
+				t.Errorf("DIDBase() error = %v, want %v", err, ErrInvalidDID)
+			}
+			if got != tt.want {
+				t.Errorf("DIDBase() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDIDFromDomain(t *testing.T) {
+	got, err := DIDFromDomain("例.jp")
+	if err != nil || got != "did:dnssec:xn--fsq.jp" {
+		t.Errorf("DIDFromDomain() = %s, %v, want did:dnssec:xn--fsq.jp", got, err)
+	}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// KeyType is the type of the key pair used for the verification method.
+type KeyType int
+
+const (
+	KeyTypeEd25519 KeyType = iota
+	KeyTypeSecp256k1
+	KeyTypeP256
+)
+
+func (k KeyType) String() string {
+	return [...]string{"ed25519", "secp256k1", "p256"}[k]
+}
+
+// ParseKeyType parses the name of the key type, "ed25519", "secp256k1" or "p256".
+func ParseKeyType(s string) (KeyType, error) {
+	switch s {
+	case "ed25519":
+		return KeyTypeEd25519, nil
+	case "secp256k1":
+		return KeyTypeSecp256k1, nil
+	case "p256":
+		return KeyTypeP256, nil
+	default:
+		return KeyTypeEd25519, fmt.Errorf("invalid key type; got = %s, expected = ed25519 || secp256k1 || p256", s)
+	}
+}
+
+// KeyFormat is the representation of the public key in the verification method.
+type KeyFormat int
+
+const (
+	// KeyFormatMultikey is the Multikey type with publicKeyMultibase.
+	KeyFormatMultikey KeyFormat = iota
+	// KeyFormatJWK is the JsonWebKey2020 type with publicKeyJwk.
+	KeyFormatJWK
+)
+
+func (k KeyFormat) String() string {
+	return [...]string{"multikey", "jwk"}[k]
+}
+
+// ParseKeyFormat parses the name of the key format, "multikey" or "jwk".
+func ParseKeyFormat(s string) (KeyFormat, error) {
+	switch s {
+	case "multikey":
+		return KeyFormatMultikey, nil
+	case "jwk":
+		return KeyFormatJWK, nil
+	default:
+		return KeyFormatMultikey, fmt.Errorf("invalid key format; got = %s, expected = multikey || jwk", s)
+	}
+}
+
+// multicodecPrefixes is the varint-encoded multicodec of the public keys.
+var multicodecPrefixes = map[KeyType][]byte{
+	KeyTypeEd25519:   {0xed, 0x01},
+	KeyTypeSecp256k1: {0xe7, 0x01},
+	KeyTypeP256:      {0x80, 0x24},
+}
+
+// KeyPair is the generated key pair.
+type KeyPair struct {
+	Type KeyType
+
+	// x and y are the coordinates of the public key; y is nil for Ed25519.
+	x, y []byte
+	// d is the private key.
+	d []byte
+	// compressed is the public key in the compressed form used by Multikey.
+	compressed []byte
+}
+
+// GenerateKey generates a new key pair of the given type.
+func GenerateKey(typ KeyType) (*KeyPair, error) {
+	switch typ {
+	case KeyTypeEd25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		return &KeyPair{Type: typ, x: pub, d: priv.Seed(), compressed: pub}, nil
+
+	case KeyTypeSecp256k1:
+		priv, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		pub := priv.PubKey()
+		return &KeyPair{
+			Type:       typ,
+			x:          pad32(pub.X()),
+			y:          pad32(pub.Y()),
+			d:          priv.Serialize(),
+			compressed: pub.SerializeCompressed(),
+		}, nil
+
+	case KeyTypeP256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		return &KeyPair{
+			Type:       typ,
+			x:          pad32(priv.X),
+			y:          pad32(priv.Y),
+			d:          pad32(priv.D),
+			compressed: elliptic.MarshalCompressed(elliptic.P256(), priv.X, priv.Y),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid key type; got = %d", typ)
+	}
+}
+
+// PublicKeyMultibase returns the public key in the Multikey encoding:
+// the base58btc multibase of the multicodec-prefixed compressed public key.
+func (k *KeyPair) PublicKeyMultibase() string {
+	return "z" + base58Encode(append(append([]byte{}, multicodecPrefixes[k.Type]...), k.compressed...))
+}
+
+// PublicJWK returns the public key in the JWK (RFC 7517) form.
+func (k *KeyPair) PublicJWK() map[string]interface{} {
+	enc := base64.URLEncoding.WithPadding(base64.NoPadding)
+
+	switch k.Type {
+	case KeyTypeEd25519:
+		return map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   enc.EncodeToString(k.x),
+		}
+	case KeyTypeSecp256k1:
+		return map[string]interface{}{
+			"kty": "EC",
+			"crv": "secp256k1",
+			"x":   enc.EncodeToString(k.x),
+			"y":   enc.EncodeToString(k.y),
+		}
+	default:
+		return map[string]interface{}{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   enc.EncodeToString(k.x),
+			"y":   enc.EncodeToString(k.y),
+		}
+	}
+}
+
+// PrivateJWK returns the key pair in the JWK form including the private key.
+func (k *KeyPair) PrivateJWK() map[string]interface{} {
+	jwk := k.PublicJWK()
+	jwk["d"] = base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(k.d)
+	return jwk
+}
+
+// VerificationMethod returns the verification method of the public key.
+func (k *KeyPair) VerificationMethod(id string, controller string, format KeyFormat) VerificationMethod {
+	if format == KeyFormatJWK {
+		return VerificationMethod{
+			ID:           id,
+			Type:         "JsonWebKey2020",
+			Controller:   controller,
+			PublicKeyJwk: k.PublicJWK(),
+		}
+	}
+
+	return VerificationMethod{
+		ID:                 id,
+		Type:               "Multikey",
+		Controller:         controller,
+		PublicKeyMultibase: k.PublicKeyMultibase(),
+	}
+}
+
+// pad32 returns the big-endian bytes of the coordinate padded to 32 bytes.
+func pad32(i *big.Int) []byte {
+	return i.FillBytes(make([]byte, 32))
+}
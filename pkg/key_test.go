@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// base58Decode decodes the base58btc string.
+func base58Decode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	n := new(big.Int)
+	for _, c := range s {
+		i := strings.IndexRune(alphabet, c)
+		if i < 0 {
+			t.Fatalf("invalid base58 character %q in %s", c, s)
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	zeros := len(s) - len(strings.TrimLeft(s, "1"))
+	return append(make([]byte, zeros), n.Bytes()...)
+}
+
+func TestBase58Encode(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want string
+	}{
+		{in: []byte{}, want: ""},
+		{in: []byte{0}, want: "1"},
+		{in: []byte{0, 0, 1}, want: "112"},
+		{in: []byte("Hello World!"), want: "2NEpo7TZRRrLZSi2U"},
+		{in: []byte{0xed, 0x01}, want: "K36"},
+	}
+
+	for _, tt := range tests {
+		if got := base58Encode(tt.in); got != tt.want {
+			t.Errorf("base58Encode(%x) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKeyPairEncodings(t *testing.T) {
+	enc := base64.URLEncoding.WithPadding(base64.NoPadding)
+
+	tests := []struct {
+		typ KeyType
+		// prefix is the well-known prefix of the Multikey of the type
+		prefix string
+		codec  []byte
+		kty    string
+		crv    string
+		// public returns the x and y of the public key of the private key d; y is nil for Ed25519
+		public func(d []byte) ([]byte, []byte)
+		// decompress returns the x and y of the compressed public key
+		decompress func(t *testing.T, b []byte) ([]byte, []byte)
+	}{
+		{
+			typ: KeyTypeEd25519, prefix: "z6Mk", codec: []byte{0xed, 0x01}, kty: "OKP", crv: "Ed25519",
+			public: func(d []byte) ([]byte, []byte) {
+				return ed25519.NewKeyFromSeed(d).Public().(ed25519.PublicKey), nil
+			},
+			decompress: func(t *testing.T, b []byte) ([]byte, []byte) { return b, nil },
+		},
+		{
+			typ: KeyTypeP256, prefix: "zDn", codec: []byte{0x80, 0x24}, kty: "EC", crv: "P-256",
+			public: func(d []byte) ([]byte, []byte) {
+				x, y := elliptic.P256().ScalarBaseMult(d)
+				return pad32(x), pad32(y)
+			},
+			decompress: func(t *testing.T, b []byte) ([]byte, []byte) {
+				x, y := elliptic.UnmarshalCompressed(elliptic.P256(), b)
+				if x == nil {
+					t.Fatalf("invalid compressed P-256 key %x", b)
+				}
+				return pad32(x), pad32(y)
+			},
+		},
+		{
+			typ: KeyTypeSecp256k1, prefix: "zQ3s", codec: []byte{0xe7, 0x01}, kty: "EC", crv: "secp256k1",
+			public: func(d []byte) ([]byte, []byte) {
+				pub := secp256k1.PrivKeyFromBytes(d).PubKey()
+				return pad32(pub.X()), pad32(pub.Y())
+			},
+			decompress: func(t *testing.T, b []byte) ([]byte, []byte) {
+				pub, err := secp256k1.ParsePubKey(b)
+				if err != nil {
+					t.Fatalf("secp256k1.ParsePubKey() error = %v", err)
+				}
+				return pad32(pub.X()), pad32(pub.Y())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			key, err := GenerateKey(tt.typ)
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+
+			// the private JWK has the public JWK and the private key which derives it
+			jwk := key.PrivateJWK()
+			if jwk["kty"] != tt.kty || jwk["crv"] != tt.crv {
+				t.Fatalf("PrivateJWK() kty = %v, crv = %v, want %s, %s", jwk["kty"], jwk["crv"], tt.kty, tt.crv)
+			}
+			d, err := enc.DecodeString(jwk["d"].(string))
+			if err != nil || len(d) != 32 {
+				t.Fatalf("PrivateJWK() d = %v, want 32 bytes in base64url", jwk["d"])
+			}
+			wantX, wantY := tt.public(d)
+
+			pub := key.PublicJWK()
+			if _, ok := pub["d"]; ok {
+				t.Errorf("PublicJWK() has d")
+			}
+			x, err := enc.DecodeString(pub["x"].(string))
+			if err != nil || !bytes.Equal(x, wantX) {
+				t.Errorf("PublicJWK() x = %v, want %s", pub["x"], enc.EncodeToString(wantX))
+			}
+			if wantY == nil {
+				if _, ok := pub["y"]; ok {
+					t.Errorf("PublicJWK() has y")
+				}
+			} else if y, err := enc.DecodeString(pub["y"].(string)); err != nil || !bytes.Equal(y, wantY) {
+				t.Errorf("PublicJWK() y = %v, want %s", pub["y"], enc.EncodeToString(wantY))
+			}
+
+			// the Multikey is the multicodec-prefixed compressed form of the same key
+			mb := key.PublicKeyMultibase()
+			if !strings.HasPrefix(mb, tt.prefix) {
+				t.Errorf("PublicKeyMultibase() = %s, want the prefix %s", mb, tt.prefix)
+			}
+			raw := base58Decode(t, mb[1:])
+			if !bytes.HasPrefix(raw, tt.codec) {
+				t.Fatalf("PublicKeyMultibase() multicodec = %x, want %x", raw, tt.codec)
+			}
+			mx, my := tt.decompress(t, raw[len(tt.codec):])
+			if !bytes.Equal(mx, wantX) || !bytes.Equal(my, wantY) {
+				t.Errorf("PublicKeyMultibase() key = %x, %x, want %x, %x", mx, my, wantX, wantY)
+			}
+
+			// the verification methods of both the formats are valid in the document
+			for _, format := range []KeyFormat{KeyFormatMultikey, KeyFormatJWK} {
+				vm := key.VerificationMethod("#key-1", "did:dnssec:example.com", format)
+				wantType := map[KeyFormat]string{KeyFormatMultikey: "Multikey", KeyFormatJWK: "JsonWebKey2020"}[format]
+				if vm.Type != wantType {
+					t.Errorf("VerificationMethod(%s) type = %s, want %s", format, vm.Type, wantType)
+				}
+				if (format == KeyFormatJWK) != (vm.PublicKeyJwk != nil) || (format == KeyFormatJWK) == (vm.PublicKeyMultibase != "") {
+					t.Errorf("VerificationMethod(%s) = %+v, want only the key of the format", format, vm)
+				}
+
+				b, err := json.Marshal(map[string]interface{}{
+					"id":                 "did:dnssec:example.com",
+					"verificationMethod": []interface{}{vm},
+					"authentication":     []string{"#key-1"},
+				})
+				if err != nil {
+					t.Fatalf("json.Marshal() error = %v", err)
+				}
+				if err := Validate(testDocument(t, string(b)), "example.com."); err != nil {
+					t.Errorf("Validate() of the %s method error = %v", format, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseKeyTypeAndFormat(t *testing.T) {
+	for _, typ := range []KeyType{KeyTypeEd25519, KeyTypeSecp256k1, KeyTypeP256} {
+		if got, err := ParseKeyType(typ.String()); err != nil || got != typ {
+			t.Errorf("ParseKeyType(%s) = %v, %v", typ, got, err)
+		}
+	}
+	if _, err := ParseKeyType("rsa"); err == nil {
+		t.Errorf("ParseKeyType(rsa) succeeded")
+	}
+
+	for _, f := range []KeyFormat{KeyFormatMultikey, KeyFormatJWK} {
+		if got, err := ParseKeyFormat(f.String()); err != nil || got != f {
+			t.Errorf("ParseKeyFormat(%s) = %v, %v", f, got, err)
+		}
+	}
+	if _, err := ParseKeyFormat("pem"); err == nil {
+		t.Errorf("ParseKeyFormat(pem) succeeded")
+	}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// LabelEncoding is the encoding of the map keys into the DNS labels of the tree encoding.
+// Each encoding has its own record version, so the records tell how their labels are encoded.
+type LabelEncoding int
+
+const (
+	// LabelBase64 encodes the keys with unpadded base64url (`v=did:dnssec`).
+	// Since DNS names are case-insensitive, the keys whose labels differ only by letter case collide.
+	LabelBase64 LabelEncoding = iota
+	// LabelBase32 encodes the keys with unpadded lowercase base32hex (`v=did:dnssec3`),
+	// which is safe against the case folding and the 0x20 randomization of the resolvers.
+	LabelBase32
+)
+
+// treeVersions maps the record versions of the tree encoding to their label encodings.
+var treeVersions = map[string]LabelEncoding{
+	"did:dnssec":  LabelBase64,
+	"did:dnssec3": LabelBase32,
+}
+
+var base32Label = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// ParseLabelEncoding parses the name of the label encoding: "base64" or "base32".
+func ParseLabelEncoding(s string) (LabelEncoding, error) {
+	switch s {
+	case "base64":
+		return LabelBase64, nil
+	case "base32":
+		return LabelBase32, nil
+	default:
+		return LabelBase64, fmt.Errorf("invalid label encoding; got = %s, expected = base64 || base32", s)
+	}
+}
+
+func (e LabelEncoding) String() string {
+	if e == LabelBase32 {
+		return "base32"
+	}
+	return "base64"
+}
+
+// version returns the record version of the tree encoding with the label encoding.
+func (e LabelEncoding) version() string {
+	if e == LabelBase32 {
+		return "did:dnssec3"
+	}
+	return "did:dnssec"
+}
+
+func (e LabelEncoding) encode(key string) string {
+	if e == LabelBase32 {
+		return strings.ToLower(base32Label.EncodeToString([]byte(key)))
+	}
+	return base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString([]byte(key))
+}
+
+func (e LabelEncoding) decode(label string) (string, error) {
+	var b []byte
+	var err error
+	if e == LabelBase32 {
+		// the resolvers may change the case of the names
+		b, err = base32Label.DecodeString(strings.ToUpper(label))
+	} else {
+		b, err = base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(label)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %s label; got = %s", e, label)
+	}
+
+	return string(b), nil
+}
+
+// checkLabelCollisions reports the map keys in the tree whose labels are the same
+// when compared case-insensitively, as DNS does.
+func checkLabelCollisions(n *Node, enc LabelEncoding) error {
+	if n.Value.Type != ValTypeMap && n.Value.Type != ValTypeArray {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for _, child := range *n.Children {
+		if n.Value.Type == ValTypeMap {
+			label := strings.ToLower(enc.encode(child.Key))
+			if other, ok := labels[label]; ok {
+				return fmt.Errorf("keys collide in the %s labels; keys = %q, %q, pointer = %s",
+					enc, other, child.Key, n.Pointer())
+			}
+			labels[label] = child.Key
+		}
+
+		if err := checkLabelCollisions(child, enc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
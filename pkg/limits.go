@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Limits bounds the resources a resolution may consume, to protect the resolver from hostile zones.
+// A zero field means no limit.
+type Limits struct {
+	// MaxDepth is the maximum depth of the nested maps and arrays in the document.
+	MaxDepth int
+	// MaxNodes is the maximum number of the nodes in the document.
+	MaxNodes int
+	// MaxQueries is the maximum number of the TXT lookups per resolution.
+	MaxQueries int
+	// MaxArrayLength is the maximum number of the elements of an array, or the keys of a map.
+	MaxArrayLength int
+	// MaxDataSize is the maximum total size in bytes of the TXT records and the decompressed payload.
+	MaxDataSize int
+	// MaxAliases is the maximum number of the CNAME and DNAME records followed by a lookup.
+	MaxAliases int
+}
+
+// DefaultLimits is the limits applied unless SetLimits is called.
+var DefaultLimits = Limits{
+	MaxDepth:       32,
+	MaxNodes:       10000,
+	MaxQueries:     10000,
+	MaxArrayLength: 1000,
+	MaxDataSize:    1 << 20,
+	MaxAliases:     8,
+}
+
+// ErrLimitExceeded is the error for the resolutions aborted by the limits.
+// The actual error is LimitError, which tells the limit exceeded.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError is the error for the resolution aborted by a limit.
+type LimitError struct {
+	// Limit is the name of the field of Limits (e.g. MaxQueries).
+	Limit string
+	Max   int
+	// Name is the owner name of the records which exceeded the limit, if any.
+	Name string
+}
+
+func (e *LimitError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s; limit = %s, max = %d", ErrLimitExceeded, e.Limit, e.Max)
+	}
+	return fmt.Sprintf("%s; limit = %s, max = %d, name = %s", ErrLimitExceeded, e.Limit, e.Max, e.Name)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+var limits atomic.Pointer[Limits]
+
+func init() {
+	l := DefaultLimits
+	limits.Store(&l)
+}
+
+// SetLimits sets the limits applied to the resolutions started after the call.
+func SetLimits(l Limits) {
+	limits.Store(&l)
+}
+
+// budget is the consumption of a resolution against the limits.
+type budget struct {
+	limits  Limits
+	queries int
+	nodes   int
+	size    int
+}
+
+type budgetKey struct{}
+
+// withBudget returns the context with a new budget, unless it already has one.
+func withBudget(ctx context.Context) context.Context {
+	if budgetFrom(ctx) != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, budgetKey{}, &budget{limits: *limits.Load()})
+}
+
+// budgetFrom returns the budget of the resolution, or nil if the context has none.
+// The methods of the nil budget check nothing.
+func budgetFrom(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetKey{}).(*budget)
+	return b
+}
+
+func exceeds(max int, v int) bool {
+	return max > 0 && v > max
+}
+
+// query consumes a lookup of the name.
+func (b *budget) query(name string) error {
+	if b == nil {
+		return nil
+	}
+
+	b.queries++
+	if exceeds(b.limits.MaxQueries, b.queries) {
+		return &LimitError{Limit: "MaxQueries", Max: b.limits.MaxQueries, Name: name}
+	}
+
+	return nil
+}
+
+// data consumes the size of the records of the name or the payload.
+func (b *budget) data(name string, size int) error {
+	if b == nil {
+		return nil
+	}
+
+	b.size += size
+	if exceeds(b.limits.MaxDataSize, b.size) {
+		return &LimitError{Limit: "MaxDataSize", Max: b.limits.MaxDataSize, Name: name}
+	}
+
+	return nil
+}
+
+// node consumes a node at the depth, with the number of its children.
+func (b *budget) node(name string, depth int, children int) error {
+	if b == nil {
+		return nil
+	}
+
+	b.nodes++
+	switch {
+	case exceeds(b.limits.MaxNodes, b.nodes):
+		return &LimitError{Limit: "MaxNodes", Max: b.limits.MaxNodes, Name: name}
+	case exceeds(b.limits.MaxDepth, depth):
+		return &LimitError{Limit: "MaxDepth", Max: b.limits.MaxDepth, Name: name}
+	case exceeds(b.limits.MaxArrayLength, children):
+		return &LimitError{Limit: "MaxArrayLength", Max: b.limits.MaxArrayLength, Name: name}
+	}
+
+	return nil
+}
+
+// aliases checks the number of the aliases followed by the lookup of the name.
+func (b *budget) aliases(name string, count int) error {
+	if b == nil || !exceeds(b.limits.MaxAliases, count) {
+		return nil
+	}
+
+	return &LimitError{Limit: "MaxAliases", Max: b.limits.MaxAliases, Name: name}
+}
+
+// tree consumes the nodes of the tree decoded at once, such as the document in the compact encoding.
+func (b *budget) tree(name string, n *Node, depth int) error {
+	children := 0
+	if n.Children != nil {
+		children = len(*n.Children)
+	}
+	if err := b.node(name, depth, children); err != nil {
+		return err
+	}
+
+	if n.Children != nil {
+		for _, child := range *n.Children {
+			if err := b.tree(name, child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// nestedDocument returns the document whose key `a` is nested to the depth.
+func nestedDocument(depth int) string {
+	return `{"id": "did:dnssec:example.com", ` + strings.Repeat(`"a": {`, depth) + `"b": 1` + strings.Repeat("}", depth) + "}"
+}
+
+func TestResolveLimits(t *testing.T) {
+	record := func(tags ...string) string {
+		return formatRecord(LabelBase64.version(), tags...)
+	}
+
+	large := []*ResorceRecord{}
+	for i := 0; i < 20; i++ {
+		large = append(large, txtRR("_did.example.com", record("t", "p", "d", "string="+strings.Repeat("A", 200)+fmt.Sprint(i))))
+	}
+
+	chain := []*ResorceRecord{}
+	for i := 0; i < 10; i++ {
+		chain = append(chain, aliasRR("CNAME", fmt.Sprintf("_did.%d.example.com", i), fmt.Sprintf("_did.%d.example.com", i+1)))
+	}
+
+	tests := []struct {
+		name   string
+		limits Limits
+		did    string
+		rrs    []*ResorceRecord
+		want   string
+	}{
+		{
+			name:   "huge array",
+			limits: DefaultLimits,
+			did:    "did:dnssec:example.com",
+			rrs:    []*ResorceRecord{txtRR("_did.example.com", record("t", "a", "d", "100000000"))},
+			want:   "MaxArrayLength",
+		},
+		{
+			name:   "deep nesting",
+			limits: Limits{MaxDepth: 4},
+			did:    "did:dnssec:example.com",
+			rrs:    testDocument(t, nestedDocument(8)).RRs("example.com."),
+			want:   "MaxDepth",
+		},
+		{
+			name:   "many nodes",
+			limits: Limits{MaxNodes: 5},
+			did:    "did:dnssec:example.com",
+			rrs:    testDocument(t, nestedDocument(8)).RRs("example.com."),
+			want:   "MaxNodes",
+		},
+		{
+			name:   "many queries",
+			limits: Limits{MaxQueries: 3},
+			did:    "did:dnssec:example.com",
+			rrs:    testDocument(t, nestedDocument(8)).RRs("example.com."),
+			want:   "MaxQueries",
+		},
+		{
+			name:   "large data",
+			limits: Limits{MaxDataSize: 1000},
+			did:    "did:dnssec:example.com",
+			rrs:    large,
+			want:   "MaxDataSize",
+		},
+		{
+			name:   "long alias chain",
+			limits: DefaultLimits,
+			did:    "did:dnssec:0.example.com",
+			rrs:    chain,
+			want:   "MaxAliases",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useLimits(t, tt.limits)
+			useBackend(t, testZone(t, "example.com.", tt.rrs...))
+
+			_, err := Resolve(tt.did)
+			var le *LimitError
+			if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &le) || le.Limit != tt.want {
+				t.Errorf("Resolve() error = %v, want %s exceeded", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveHugeArrayNotLookedUp(t *testing.T) {
+	b := useBackend(t, testZone(t, "example.com.",
+		txtRR("_did.example.com", formatRecord(LabelBase64.version(), "t", "a", "d", "100000000"))))
+
+	if _, err := Resolve("did:dnssec:example.com"); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Resolve() error = %v, want %v", err, ErrLimitExceeded)
+	}
+	if got := b.queries.Load(); got != 1 {
+		t.Errorf("queries = %d, want 1", got)
+	}
+}
+
+func TestQueryDIDLimits(t *testing.T) {
+	doc := testDocument(t, nestedDocument(8))
+	useBackend(t, testZone(t, "example.com.", doc.RRs("example.com.")...))
+
+	t.Run("depth of the subtree", func(t *testing.T) {
+		// the subtree starts at the depth of 4, and its leaf is at the depth of 9
+		useLimits(t, Limits{MaxDepth: 6})
+
+		_, err := QueryDID("did:dnssec:example.com", "$.a.a.a.a")
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != "MaxDepth" {
+			t.Errorf("QueryDID() error = %v, want MaxDepth exceeded", err)
+		}
+	})
+
+	t.Run("nodes counted once", func(t *testing.T) {
+		// root, id, 8 maps of a and b
+		useLimits(t, Limits{MaxNodes: 11})
+
+		results, err := QueryDID("did:dnssec:example.com", "$")
+		if err != nil {
+			t.Fatalf("QueryDID() error = %v", err)
+		}
+		want, _ := doc.JSON()
+		if got, _ := results[0].Node.JSON(); string(got) != string(want) {
+			t.Errorf("QueryDID() = %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(discardHandler{}))
+}
+
+// SetLogger sets the logger used by the package.
+// The package logs nothing by default. The lookups and the records skipped as invalid are logged
+// at the debug level, and the resolution results at the info level.
+// Passing nil restores the default.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(discardHandler{})
+	}
+	logger.Store(l)
+}
+
+// Logger returns the logger used by the package.
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+// discardHandler is the slog.Handler which drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
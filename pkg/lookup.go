@@ -0,0 +1,316 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// resolvConf is the path of the stub resolver configuration.
+var resolvConf = "/etc/resolv.conf"
+
+var (
+	serversOnce sync.Once
+	servers     []string
+	// serversOverride is the resolvers set by SetNameservers.
+	serversOverride atomic.Pointer[[]string]
+)
+
+var requireDNSSEC atomic.Bool
+
+// SetRequireDNSSEC sets whether the resolutions require DNSSEC.
+//
+// If required, the resolution fails with ErrInsecure unless every answer is validated as secure
+// by the resolver, including the answers along the chain of the aliases to the records.
+// The lookups falling back to the system resolver, which does not tell the status, fail as well.
+func SetRequireDNSSEC(r bool) {
+	requireDNSSEC.Store(r)
+}
+
+// SetNameservers sets the resolvers (host:port) to query in place of the ones in resolv.conf,
+// such as the authoritative server of the zone for testing. Passing nil restores the default.
+func SetNameservers(addrs []string) {
+	if len(addrs) == 0 {
+		serversOverride.Store(nil)
+		return
+	}
+	addrs = append([]string{}, addrs...)
+	serversOverride.Store(&addrs)
+}
+
+// nameservers returns the recursive resolvers in resolv.conf as host:port.
+// If the configuration is not available, it returns nil and the lookups fall back to net.LookupTXT.
+func nameservers() []string {
+	if addrs := serversOverride.Load(); addrs != nil {
+		return *addrs
+	}
+
+	serversOnce.Do(func() {
+		conf, err := dns.ClientConfigFromFile(resolvConf)
+		if err != nil {
+			Logger().Debug("resolv.conf is not available; using the system resolver", "error", err)
+			return
+		}
+
+		for _, s := range conf.Servers {
+			servers = append(servers, net.JoinHostPort(s, conf.Port))
+		}
+	})
+
+	return servers
+}
+
+// lookupTXT looks up the TXT records of the name, and returns them with the canonical name,
+// which is the owner name of the records after following the CNAME and DNAME aliases.
+//
+// The recursive resolver follows the chain of the aliases within an answer, and validates the
+// DNSSEC signatures of the zones along the chain. If it stops midway, the rest of the chain is
+// looked up from the last target. The aliases of the whole chain are counted against the limits,
+// and the chain which comes back to a name is ErrAliasLoop. If DNSSEC is required,
+// every answer along the chain must be secure.
+func lookupTXT(ctx context.Context, fqdn string) ([]string, string, error) {
+	name := fqdn
+	visited := map[string]bool{}
+	aliases := 0
+
+	for {
+		visited[strings.ToLower(dns.Fqdn(name))] = true
+
+		txt, q, err := lookupOnce(ctx, name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if requireDNSSEC.Load() && q.DNSSEC != DNSSECSecure {
+			if name != fqdn {
+				return nil, "", fmt.Errorf("%w; name = %s, alias = %s, dnssec = %s", ErrInsecure, fqdn, name, q.DNSSEC)
+			}
+			return nil, "", fmt.Errorf("%w; name = %s, dnssec = %s", ErrInsecure, fqdn, q.DNSSEC)
+		}
+
+		aliases += q.Aliases
+		if err := budgetFrom(ctx).aliases(fqdn, aliases); err != nil {
+			return nil, "", err
+		}
+
+		switch {
+		case q.Target == "":
+			return txt, name, nil
+		case len(txt) > 0:
+			Logger().Debug("followed aliases", "name", name, "target", q.Target)
+			return txt, q.Target, nil
+		case visited[strings.ToLower(q.Target)]:
+			return nil, "", fmt.Errorf("%w; name = %s, target = %s", ErrAliasLoop, fqdn, q.Target)
+		}
+
+		name = q.Target
+	}
+}
+
+// lookupOnce looks up the TXT records of the name with a query, and reports it to the trace hooks.
+// The lookup is counted against the limits of the resolution in the context.
+func lookupOnce(ctx context.Context, fqdn string) ([]string, QueryTrace, error) {
+	Logger().Debug("looking up txt records", "name", fqdn)
+
+	q := QueryTrace{Name: fqdn, Start: time.Now()}
+
+	b := budgetFrom(ctx)
+	if err := b.query(fqdn); err != nil {
+		return nil, q, err
+	}
+
+	txt, err := exchangeTXT(ctx, fqdn, &q)
+	q.Latency = time.Since(q.Start)
+	q.Records = len(txt)
+	q.Err = err
+	reportQuery(ctx, q)
+	recordLookup(ctx, q)
+
+	if err != nil {
+		Logger().Debug("lookup failed", "name", fqdn, "error", err)
+		return nil, q, err
+	}
+
+	size := 0
+	for _, v := range txt {
+		size += len(v)
+	}
+	if err := b.data(fqdn, size); err != nil {
+		return nil, q, err
+	}
+
+	return txt, q, nil
+}
+
+// exchangeTXT queries the recursive resolvers in order with the DO bit set,
+// and fills the server, rcode, TTL, DNSSEC status and alias target of the trace.
+// If the answer only has the aliases, it returns no records without an error.
+// The queries are aborted when the context is done.
+func exchangeTXT(ctx context.Context, fqdn string, q *QueryTrace) ([]string, error) {
+	addrs := nameservers()
+	if len(addrs) == 0 {
+		q.Server = "system"
+		q.DNSSEC = DNSSECUnknown
+
+		// the system resolver follows the aliases, but does not tell the canonical name with the records
+		if cname, err := net.DefaultResolver.LookupCNAME(ctx, fqdn); err == nil && !strings.EqualFold(cname, dns.Fqdn(fqdn)) {
+			q.Target = cname
+		}
+
+		txt, err := net.DefaultResolver.LookupTXT(ctx, fqdn)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, fmt.Errorf("%w; name = %s", ErrNotFound, fqdn)
+		}
+		if errors.As(err, &dnsErr) && dnsErr.IsTimeout {
+			return nil, fmt.Errorf("%w; name = %s", ErrTimeout, fqdn)
+		}
+		return txt, err
+	}
+
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(fqdn), dns.TypeTXT)
+	msg.SetEdns0(4096, true)
+
+	var lastErr error
+	for _, addr := range addrs {
+		q.Server = addr
+		resp, err := exchange(ctx, msg, addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, contextError(ctx, fqdn)
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				err = fmt.Errorf("%w; server = %s", ErrTimeout, addr)
+			}
+			lastErr = err
+			continue
+		}
+
+		q.Rcode = dns.RcodeToString[resp.Rcode]
+		q.DNSSEC = DNSSECInsecure
+		if resp.AuthenticatedData {
+			q.DNSSEC = DNSSECSecure
+		}
+
+		switch resp.Rcode {
+		case dns.RcodeSuccess:
+		case dns.RcodeNameError:
+			return nil, fmt.Errorf("%w; name = %s", ErrNotFound, fqdn)
+		case dns.RcodeServerFailure:
+			if isBogus(ctx, msg, addr) {
+				q.DNSSEC = DNSSECBogus
+				return nil, fmt.Errorf("%w; name = %s", ErrBogus, fqdn)
+			}
+			return nil, fmt.Errorf("lookup failed; name = %s, rcode = %s", fqdn, q.Rcode)
+		default:
+			return nil, fmt.Errorf("lookup failed; name = %s, rcode = %s", fqdn, q.Rcode)
+		}
+
+		target := canonicalName(fqdn, resp.Answer)
+		if !strings.EqualFold(target, dns.Fqdn(fqdn)) {
+			q.Target = target
+		}
+
+		txt := []string{}
+		for _, rr := range resp.Answer {
+			switch rr.(type) {
+			case *dns.CNAME, *dns.DNAME:
+				q.Aliases++
+			}
+
+			t, ok := rr.(*dns.TXT)
+			if !ok || !strings.EqualFold(t.Hdr.Name, target) {
+				continue
+			}
+
+			// the character strings of a record are concatenated as net.LookupTXT does
+			txt = append(txt, strings.Join(t.Txt, ""))
+			if q.TTL == 0 || t.Hdr.Ttl < q.TTL {
+				q.TTL = t.Hdr.Ttl
+			}
+		}
+
+		if len(txt) == 0 && q.Target == "" {
+			if q.Aliases > 0 {
+				return nil, fmt.Errorf("%w; name = %s", ErrAliasLoop, fqdn)
+			}
+			return nil, fmt.Errorf("%w: no txt record; name = %s", ErrNotFound, fqdn)
+		}
+		return txt, nil
+	}
+
+	return nil, fmt.Errorf("no resolver answered; name = %s: %w", fqdn, lastErr)
+}
+
+// isBogus reports whether the SERVFAIL answer is caused by the DNSSEC validation,
+// by asking the resolver again with the CD (checking disabled) bit set.
+func isBogus(ctx context.Context, msg *dns.Msg, addr string) bool {
+	cd := msg.Copy()
+	cd.CheckingDisabled = true
+
+	resp, err := exchange(ctx, cd, addr)
+	return err == nil && resp.Rcode != dns.RcodeServerFailure
+}
+
+// contextError returns the error for the lookup of the name aborted by the context,
+// which is ErrTimeout if the deadline is exceeded.
+func contextError(ctx context.Context, fqdn string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w; name = %s: %w", ErrTimeout, fqdn, ctx.Err())
+	}
+
+	return fmt.Errorf("lookup aborted; name = %s: %w", fqdn, ctx.Err())
+}
+
+// exchange sends the query to the nameserver. It is replaced with an in-memory backend in the tests.
+var exchange = exchangeNet
+
+// exchangeNet sends the query over UDP, and retries over TCP if the response is truncated.
+// The deadline of the context bounds both.
+func exchangeNet(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, error) {
+	resp, _, err := (&dns.Client{Net: "udp"}).ExchangeContext(ctx, msg, addr)
+	if err == nil && resp.Truncated {
+		resp, _, err = (&dns.Client{Net: "tcp"}).ExchangeContext(ctx, msg, addr)
+	}
+
+	return resp, err
+}
+
+// canonicalName follows the CNAME and DNAME records in the answer from the name,
+// and returns the name at the end of the chain.
+func canonicalName(name string, answer []dns.RR) string {
+	name = dns.Fqdn(name)
+
+	// the chain is not longer than the answer, unless it loops
+	for range answer {
+		next := ""
+		for _, rr := range answer {
+			switch a := rr.(type) {
+			case *dns.CNAME:
+				if strings.EqualFold(a.Hdr.Name, name) {
+					next = a.Target
+				}
+			case *dns.DNAME:
+				// the resolvers also synthesize the CNAME, which takes precedence
+				if next == "" && dns.IsSubDomain(a.Hdr.Name, name) && !strings.EqualFold(a.Hdr.Name, name) {
+					next = name[:len(name)-len(a.Hdr.Name)] + a.Target
+				}
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		name = dns.Fqdn(next)
+	}
+
+	return name
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// useRequireDNSSEC sets whether DNSSEC is required until the test ends.
+func useRequireDNSSEC(t testing.TB, r bool) {
+	t.Helper()
+
+	SetRequireDNSSEC(r)
+	t.Cleanup(func() { SetRequireDNSSEC(false) })
+}
+
+func TestLookupAliasLoop(t *testing.T) {
+	t.Run("across the answers", func(t *testing.T) {
+		useBackend(t, testZone(t, "example.com.",
+			aliasRR("CNAME", "_did.example.com", "_did.a.example.com"),
+			aliasRR("CNAME", "_did.a.example.com", "_did.example.com"),
+		))
+
+		if _, err := Resolve("did:dnssec:example.com"); !errors.Is(err, ErrAliasLoop) {
+			t.Errorf("Resolve() error = %v, want %v", err, ErrAliasLoop)
+		}
+	})
+
+	t.Run("within an answer", func(t *testing.T) {
+		useBackend(t)
+		exchange = func(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, error) {
+			m := &dns.Msg{}
+			m.SetReply(msg)
+			for _, rr := range []string{
+				"_did.example.com. 3600 IN CNAME _did.a.example.com.",
+				"_did.a.example.com. 3600 IN CNAME _did.example.com.",
+			} {
+				r, _ := dns.NewRR(rr)
+				m.Answer = append(m.Answer, r)
+			}
+			return m, nil
+		}
+
+		if _, err := Resolve("did:dnssec:example.com"); !errors.Is(err, ErrAliasLoop) {
+			t.Errorf("Resolve() error = %v, want %v", err, ErrAliasLoop)
+		}
+	})
+}
+
+func TestLookupRequireDNSSEC(t *testing.T) {
+	doc := testDocument(t, `{"id": "did:dnssec:example.com", "a": "b"}`)
+	zones := []*Zone{
+		testZone(t, "example.com.", aliasRR("CNAME", "_did.example.com", "_did.example.com.example.net")),
+		testZone(t, "example.net.", doc.RRs("example.com.example.net.")...),
+	}
+
+	tests := []struct {
+		name    string
+		require bool
+		secure  []string
+		wantErr error
+	}{
+		{name: "not required", require: false, secure: []string{"example.com."}},
+		{name: "secure chain", require: true, secure: []string{"example.com.", "example.net."}},
+		{name: "insecure alias", require: true, secure: []string{"example.net."}, wantErr: ErrInsecure},
+		{name: "insecure target", require: true, secure: []string{"example.com."}, wantErr: ErrInsecure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRequireDNSSEC(t, tt.require)
+			b := useBackend(t, zones...)
+			for _, origin := range tt.secure {
+				b.secure[origin] = true
+			}
+
+			_, meta, err := ResolveWithMetadata("did:dnssec:example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && meta.Location != "_did.example.com.example.net." {
+				t.Errorf("Location = %s, want _did.example.com.example.net.", meta.Location)
+			}
+		})
+	}
+}
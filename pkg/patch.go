@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// patchOperation is the operation of JSON Patch (RFC 6902).
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// ApplyPatch applies the JSON Patch (RFC 6902) document to the node.
+// The patch is applied atomically; the node is not changed if any operation fails.
+func (n *Node) ApplyPatch(patch []byte) error {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return fmt.Errorf("invalid json patch; %w", err)
+	}
+
+	target := n.Clone()
+	for i, op := range ops {
+		if err := target.applyOperation(op); err != nil {
+			return fmt.Errorf("failed to apply patch operation; index = %d, op = %s: %w", i, op.Op, err)
+		}
+	}
+
+	n.replaceWith(target)
+	return nil
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7396) document to the node.
+// The members with null in the patch are removed, and the maps are merged recursively.
+func (n *Node) ApplyMergePatch(patch []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(patch, &v); err != nil {
+		return fmt.Errorf("invalid json merge patch; %w", err)
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		// the patch which is not a map replaces the whole target
+		replacement, err := NewNode(v)
+		if err != nil {
+			return err
+		}
+		n.replaceWith(replacement)
+		return nil
+	}
+
+	target := n.Clone()
+	if target.Value.Type != ValTypeMap {
+		// the target which is not a map is merged as an empty map
+		target = emptyMapNode()
+	}
+	if err := target.mergePatch(m); err != nil {
+		return err
+	}
+
+	n.replaceWith(target)
+	return nil
+}
+
+func (n *Node) mergePatch(patch map[string]interface{}) error {
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := patch[k]
+		if v == nil {
+			n.RemoveChild(k)
+			continue
+		}
+
+		pm, isMap := v.(map[string]interface{})
+		child := n.GetChild(k)
+		if isMap && child != nil && child.Value.Type == ValTypeMap {
+			if err := child.mergePatch(pm); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if isMap {
+			// the nulls in the patch must not be left in the new member
+			child = emptyMapNode()
+			if err := child.mergePatch(pm); err != nil {
+				return err
+			}
+			n.SetChild(k, child)
+			continue
+		}
+
+		node, err := NewNode(v)
+		if err != nil {
+			return fmt.Errorf("%w; key = %s", err, k)
+		}
+		n.SetChild(k, node)
+	}
+
+	return nil
+}
+
+func emptyMapNode() *Node {
+	return &Node{
+		Value:    &NodeValue{Type: ValTypeMap},
+		Children: &[]*Node{},
+	}
+}
+
+func (n *Node) applyOperation(op patchOperation) error {
+	if op.Path == nil {
+		return fmt.Errorf("path is required")
+	}
+	path := *op.Path
+
+	value := func() (*Node, error) {
+		if op.Value == nil {
+			return nil, fmt.Errorf("value is required")
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(*op.Value, &v); err != nil {
+			return nil, err
+		}
+		return NewNode(v)
+	}
+
+	from := func() (*Node, error) {
+		if op.From == nil {
+			return nil, fmt.Errorf("from is required")
+		}
+		return n.Get(*op.From)
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			n.replaceWith(v)
+			return nil
+		}
+		return n.Insert(path, v)
+
+	case "remove":
+		return n.Delete(path)
+
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			n.replaceWith(v)
+			return nil
+		}
+		if _, err := n.Get(path); err != nil {
+			return err
+		}
+		return n.Set(path, v)
+
+	case "move":
+		src, err := from()
+		if err != nil {
+			return err
+		}
+		if path == *op.From {
+			return nil
+		}
+		if strings.HasPrefix(path, *op.From+"/") {
+			return fmt.Errorf("cannot move into its own child; from = %s, path = %s", *op.From, path)
+		}
+		if err := n.Delete(*op.From); err != nil {
+			return err
+		}
+		return n.Insert(path, src)
+
+	case "copy":
+		src, err := from()
+		if err != nil {
+			return err
+		}
+		return n.Insert(path, src.Clone())
+
+	case "test":
+		v, err := value()
+		if err != nil {
+			return err
+		}
+		actual, err := n.Get(path)
+		if err != nil {
+			return err
+		}
+
+		a, err := actual.CanonicalJSON()
+		if err != nil {
+			return err
+		}
+		e, err := v.CanonicalJSON()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(a, e) {
+			return fmt.Errorf("test failed; path = %s, expected = %s, actual = %s", path, e, a)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("invalid operation; got = %s", op.Op)
+	}
+}
+
+// replaceWith replaces the value and children of the node with the other one,
+// keeping the key and position of the node in its tree.
+func (n *Node) replaceWith(other *Node) {
+	n.Value = other.Value
+	n.Children = other.Children
+
+	if n.Children != nil {
+		for _, child := range *n.Children {
+			child.Parent = n
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package core
+
+import "testing"
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		// the examples of RFC 6902 appendix A, but those with the invalid JSON and the null
+		{
+			name:   "adding an object member",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:   `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:   "adding an array element",
+			target: `{"foo":["bar","baz"]}`,
+			patch:  `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:   `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:   "removing an object member",
+			target: `{"baz":"qux","foo":"bar"}`,
+			patch:  `[{"op":"remove","path":"/baz"}]`,
+			want:   `{"foo":"bar"}`,
+		},
+		{
+			name:   "removing an array element",
+			target: `{"foo":["bar","qux","baz"]}`,
+			patch:  `[{"op":"remove","path":"/foo/1"}]`,
+			want:   `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:   "replacing a value",
+			target: `{"baz":"qux","foo":"bar"}`,
+			patch:  `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:   `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:   "moving a value",
+			target: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:  `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:   `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:   "moving an array element",
+			target: `{"foo":["all","grass","cows","eat"]}`,
+			patch:  `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:   `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:   "testing a value: success",
+			target: `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch:  `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:   `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:    "testing a value: error",
+			target:  `{"baz":"qux"}`,
+			patch:   `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: true,
+		},
+		{
+			name:   "adding a nested member object",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:   `{"child":{"grandchild":{}},"foo":"bar"}`,
+		},
+		{
+			name:   "ignoring unrecognized elements",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:   `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:    "adding to a nonexistent target",
+			target:  `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:   "~ escape ordering",
+			target: `{"/":9,"~1":10}`,
+			patch:  `[{"op":"test","path":"/~01","value":10}]`,
+			want:   `{"/":9,"~1":10}`,
+		},
+		{
+			name:    "comparing strings and numbers",
+			target:  `{"/":9,"~1":10}`,
+			patch:   `[{"op":"test","path":"/~01","value":"10"}]`,
+			wantErr: true,
+		},
+		{
+			name:   "adding an array value",
+			target: `{"foo":["bar"]}`,
+			patch:  `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:   `{"foo":["bar",["abc","def"]]}`,
+		},
+		// the other cases of the operations
+		{
+			name:   "copying a value",
+			target: `{"a":{"b":[1]}}`,
+			patch:  `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			want:   `{"a":{"b":[1]},"c":{"b":[1,2]}}`,
+		},
+		{
+			name:   "copying an array element",
+			target: `{"a":["x","y"]}`,
+			patch:  `[{"op":"copy","from":"/a/1","path":"/a/0"}]`,
+			want:   `{"a":["y","x","y"]}`,
+		},
+		{
+			name:    "copying from a missing member",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"copy","from":"/b","path":"/c"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "moving into its own child",
+			target:  `{"a":{"b":1}}`,
+			patch:   `[{"op":"move","from":"/a","path":"/a/c"}]`,
+			wantErr: true,
+		},
+		{
+			name:   "moving to itself",
+			target: `{"a":{"b":1}}`,
+			patch:  `[{"op":"move","from":"/a","path":"/a"}]`,
+			want:   `{"a":{"b":1}}`,
+		},
+		{
+			name:    "replacing a missing member",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"replace","path":"/b","value":2}]`,
+			wantErr: true,
+		},
+		{
+			name:    "removing a missing member",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"remove","path":"/b"}]`,
+			wantErr: true,
+		},
+		{
+			name:   "replacing the whole document",
+			target: `{"a":1}`,
+			patch:  `[{"op":"replace","path":"","value":{"b":2}}]`,
+			want:   `{"b":2}`,
+		},
+		{
+			name:    "missing value",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"add","path":"/b"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"remove"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown operation",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"merge","path":"/a","value":2}]`,
+			wantErr: true,
+		},
+		{
+			name:    "failure after a successful operation",
+			target:  `{"a":1}`,
+			patch:   `[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":2}]`,
+			wantErr: true,
+		},
+		{
+			name:    "not an array",
+			target:  `{"a":1}`,
+			patch:   `{"op":"remove","path":"/a"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testValue(t, tt.target)
+			err := n.ApplyPatch([]byte(tt.patch))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := tt.want
+			if err != nil {
+				// the patch is applied atomically
+				want = compactJSON(t, testValue(t, tt.target))
+			}
+			if got := compactJSON(t, n); got != want {
+				t.Errorf("ApplyPatch() = %s, want %s", got, want)
+			}
+			checkParents(t, n)
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		// the examples of RFC 7396 appendix A, but those with the null in the target or the result
+		{name: "replace member", target: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", target: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "remove member", target: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{name: "remove one member", target: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "array to string", target: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "string to array", target: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{name: "nested merge", target: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "array replaced", target: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{name: "array patch", target: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{name: "map to array", target: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{name: "map to string", target: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{name: "array target", target: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{name: "new nested member", target: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		// the other cases
+		{name: "primitive target", target: `{"a":1}`, patch: `{"a":{"b":null,"c":2}}`, want: `{"a":{"c":2}}`},
+		{name: "null patch", target: `{"a":"foo"}`, patch: `null`, wantErr: true},
+		{name: "invalid json", target: `{"a":"foo"}`, patch: `{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testValue(t, tt.target)
+			err := n.ApplyMergePatch([]byte(tt.patch))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyMergePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := tt.want
+			if err != nil {
+				want = compactJSON(t, testValue(t, tt.target))
+			}
+			if got := compactJSON(t, n); got != want {
+				t.Errorf("ApplyMergePatch() = %s, want %s", got, want)
+			}
+			checkParents(t, n)
+		})
+	}
+}
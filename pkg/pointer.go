@@ -0,0 +1,340 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer parses the JSON Pointer (RFC 6901) into the reference tokens.
+// The empty pointer refers to the whole document and has no token.
+func ParsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("invalid json pointer; got = %s", ptr)
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		token, err := unescapeToken(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w; got = %s", err, ptr)
+		}
+		tokens[i] = token
+	}
+
+	return tokens, nil
+}
+
+// unescapeToken decodes "~1" to "/" and "~0" to "~" in the reference token.
+// Each "~" must be followed by "0" or "1".
+func unescapeToken(t string) (string, error) {
+	if !strings.Contains(t, "~") {
+		return t, nil
+	}
+
+	sb := strings.Builder{}
+	for i := 0; i < len(t); i++ {
+		if t[i] != '~' {
+			sb.WriteByte(t[i])
+			continue
+		}
+
+		if i+1 == len(t) || (t[i+1] != '0' && t[i+1] != '1') {
+			return "", fmt.Errorf("invalid escape in json pointer")
+		}
+		if t[i+1] == '0' {
+			sb.WriteByte('~')
+		} else {
+			sb.WriteByte('/')
+		}
+		i++
+	}
+
+	return sb.String(), nil
+}
+
+// FormatPointer formats the reference tokens into the JSON Pointer (RFC 6901).
+func FormatPointer(tokens []string) string {
+	sb := strings.Builder{}
+	for _, t := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+
+	return sb.String()
+}
+
+// NewNode creates the node from the value decoded from JSON:
+// a map[string]interface{}, a []interface{}, a string, a number or a bool.
+func NewNode(v interface{}) (*Node, error) {
+	if v == nil {
+		return nil, fmt.Errorf("null is not supported")
+	}
+
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid map type; type = %v", reflect.TypeOf(v))
+		}
+		return mapToNode("", nil, m)
+
+	case reflect.Slice:
+		s, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid slice type; type = %v", reflect.TypeOf(v))
+		}
+		return sliceToNode("", nil, s)
+
+	default:
+		return primitiveToNode("", nil, v)
+	}
+}
+
+// Clone returns the deep copy of the node, detached from its parent.
+func (n *Node) Clone() *Node {
+	clone := &Node{Key: n.Key}
+	if n.Value != nil {
+		value := *n.Value
+		clone.Value = &value
+	}
+
+	if n.Children != nil {
+		clone.Children = &[]*Node{}
+		for _, child := range *n.Children {
+			clone.AddChild(child.Clone())
+		}
+	}
+
+	return clone
+}
+
+// Pointer returns the JSON Pointer of the node from the root of its tree.
+func (n *Node) Pointer() string {
+	tokens := []string{}
+	for node := n; node.Parent != nil; node = node.Parent {
+		tokens = append([]string{node.Key}, tokens...)
+	}
+
+	return FormatPointer(tokens)
+}
+
+// Get returns the node referred by the JSON Pointer.
+func (n *Node) Get(ptr string) (*Node, error) {
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+
+	node := n
+	for i, t := range tokens {
+		child, err := node.child(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w; pointer = %s", err, FormatPointer(tokens[:i+1]))
+		}
+		node = child
+	}
+
+	return node, nil
+}
+
+// Set replaces the value referred by the JSON Pointer with the node.
+// For a map, the member is added if it does not exist. For an array, the index must exist.
+func (n *Node) Set(ptr string, value *Node) error {
+	parent, key, err := n.parentOf(ptr)
+	if err != nil {
+		return err
+	}
+
+	if parent.Value.Type == ValTypeArray {
+		i, err := arrayIndex(key, len(*parent.Children))
+		if err != nil {
+			return err
+		}
+		parent.replaceAt(i, value)
+		return nil
+	}
+
+	parent.SetChild(key, value)
+	return nil
+}
+
+// Insert adds the node at the JSON Pointer.
+// For a map, it adds or replaces the member. For an array, it inserts the node before the index
+// and renumbers the following elements; the index "-" or the length appends the node.
+func (n *Node) Insert(ptr string, value *Node) error {
+	parent, key, err := n.parentOf(ptr)
+	if err != nil {
+		return err
+	}
+
+	if parent.Value.Type != ValTypeArray {
+		parent.SetChild(key, value)
+		return nil
+	}
+
+	size := len(*parent.Children)
+	if key == "-" {
+		parent.Append(value)
+		return nil
+	}
+
+	i, err := arrayIndex(key, size+1)
+	if err != nil {
+		return err
+	}
+
+	value.Parent = parent
+	children := append((*parent.Children)[:i:i], value)
+	*parent.Children = append(children, (*parent.Children)[i:]...)
+	parent.renumber()
+	return nil
+}
+
+// Delete removes the node referred by the JSON Pointer.
+// The following elements of an array are renumbered.
+func (n *Node) Delete(ptr string) error {
+	parent, key, err := n.parentOf(ptr)
+	if err != nil {
+		return err
+	}
+
+	if parent.Value.Type == ValTypeArray {
+		i, err := arrayIndex(key, len(*parent.Children))
+		if err != nil {
+			return err
+		}
+		return parent.RemoveAt(i)
+	}
+
+	if !parent.RemoveChild(key) {
+		return fmt.Errorf("member not found; pointer = %s", ptr)
+	}
+	return nil
+}
+
+// SetChild adds the child with the key to the map, or replaces the existing one.
+func (n *Node) SetChild(key string, value *Node) {
+	value.Key = key
+	value.Parent = n
+
+	for i, child := range *n.Children {
+		if child.Key == key {
+			(*n.Children)[i] = value
+			return
+		}
+	}
+
+	*n.Children = append(*n.Children, value)
+}
+
+// RemoveChild removes the child with the key from the map, and reports whether it existed.
+func (n *Node) RemoveChild(key string) bool {
+	for i, child := range *n.Children {
+		if child.Key == key {
+			child.Parent = nil
+			*n.Children = append((*n.Children)[:i], (*n.Children)[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Append appends the node to the array.
+func (n *Node) Append(value *Node) {
+	value.Key = strconv.Itoa(len(*n.Children))
+	n.AddChild(value)
+}
+
+// RemoveAt removes the element at the index from the array, and renumbers the following elements.
+func (n *Node) RemoveAt(i int) error {
+	if n.Value.Type != ValTypeArray {
+		return fmt.Errorf("invalid node type for index; typ = %s", n.Value.Type.String())
+	}
+
+	if i < 0 || i >= len(*n.Children) {
+		return fmt.Errorf("index out of range; index = %d, length = %d", i, len(*n.Children))
+	}
+
+	(*n.Children)[i].Parent = nil
+	*n.Children = append((*n.Children)[:i], (*n.Children)[i+1:]...)
+	n.renumber()
+	return nil
+}
+
+func (n *Node) replaceAt(i int, value *Node) {
+	value.Key = strconv.Itoa(i)
+	value.Parent = n
+	(*n.Children)[i].Parent = nil
+	(*n.Children)[i] = value
+}
+
+func (n *Node) renumber() {
+	for i, child := range *n.Children {
+		child.Key = strconv.Itoa(i)
+	}
+}
+
+// child returns the child referred by the reference token.
+func (n *Node) child(token string) (*Node, error) {
+	switch n.Value.Type {
+	case ValTypeMap:
+		if child := n.GetChild(token); child != nil {
+			return child, nil
+		}
+		return nil, fmt.Errorf("member not found")
+
+	case ValTypeArray:
+		i, err := arrayIndex(token, len(*n.Children))
+		if err != nil {
+			return nil, err
+		}
+		return (*n.Children)[i], nil
+
+	default:
+		return nil, fmt.Errorf("cannot refer into %s", n.Value.Type.String())
+	}
+}
+
+// parentOf returns the container of the node referred by the JSON Pointer and its last token.
+func (n *Node) parentOf(ptr string) (*Node, string, error) {
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(tokens) == 0 {
+		return nil, "", fmt.Errorf("cannot change the root")
+	}
+
+	parent, err := n.Get(FormatPointer(tokens[:len(tokens)-1]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if parent.Value.Type != ValTypeMap && parent.Value.Type != ValTypeArray {
+		return nil, "", fmt.Errorf("cannot refer into %s; pointer = %s", parent.Value.Type.String(), ptr)
+	}
+
+	return parent, tokens[len(tokens)-1], nil
+}
+
+// arrayIndex parses the array index token, which must be less than the size.
+func arrayIndex(token string, size int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index; got = %s", token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i >= size {
+		return 0, fmt.Errorf("index out of range; index = %s, length = %d", token, size)
+	}
+
+	return i, nil
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// rfc6901Document is the example document of RFC 6901 section 5.
+const rfc6901Document = `{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8
+}`
+
+// compactJSON returns the value of the node as the compact JSON.
+func compactJSON(t *testing.T, n *Node) string {
+	t.Helper()
+
+	v, err := n.Interface()
+	if err != nil {
+		t.Fatalf("Interface() error = %v", err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return string(b)
+}
+
+// checkParents reports the nodes of the tree whose parent, key or pointer is inconsistent.
+func checkParents(t *testing.T, n *Node) {
+	t.Helper()
+
+	if n.Children == nil {
+		return
+	}
+	for i, child := range *n.Children {
+		if child.Parent != n {
+			t.Errorf("parent of %s is not its container", child.Pointer())
+		}
+		if n.Value.Type == ValTypeArray && child.Key != strconv.Itoa(i) {
+			t.Errorf("key of the element %d = %s", i, child.Key)
+		}
+		if got, err := n.Get(FormatPointer([]string{child.Key})); err != nil || got != child {
+			t.Errorf("Get() of the child %s = %v, %v", child.Key, got, err)
+		}
+		checkParents(t, child)
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		ptr     string
+		want    []string
+		wantErr bool
+	}{
+		{ptr: "", want: []string{}},
+		{ptr: "/", want: []string{""}},
+		{ptr: "/foo/0", want: []string{"foo", "0"}},
+		{ptr: "/a~1b", want: []string{"a/b"}},
+		{ptr: "/m~0n", want: []string{"m~n"}},
+		{ptr: "/~01", want: []string{"~1"}},
+		{ptr: "/~10", want: []string{"/0"}},
+		{ptr: "/~0~1", want: []string{"~/"}},
+		{ptr: "foo", wantErr: true},
+		{ptr: "/~", wantErr: true},
+		{ptr: "/a~", wantErr: true},
+		{ptr: "/~2", wantErr: true},
+		{ptr: "/~~01", wantErr: true},
+		{ptr: "/a~b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ptr, func(t *testing.T) {
+			got, err := ParsePointer(tt.ptr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePointer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePointer() = %q, want %q", got, tt.want)
+			}
+			if f := FormatPointer(got); f != tt.ptr {
+				if p, _ := ParsePointer(f); !reflect.DeepEqual(p, got) {
+					t.Errorf("FormatPointer() = %s, which does not parse back to %q", f, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNodeGet(t *testing.T) {
+	doc := testDocument(t, rfc6901Document)
+
+	tests := []struct {
+		ptr     string
+		want    string
+		wantErr bool
+	}{
+		// the examples of RFC 6901 section 5
+		{ptr: "", want: compactJSON(t, doc)},
+		{ptr: "/foo", want: `["bar","baz"]`},
+		{ptr: "/foo/0", want: `"bar"`},
+		{ptr: "/", want: `0`},
+		{ptr: "/a~1b", want: `1`},
+		{ptr: "/c%d", want: `2`},
+		{ptr: "/e^f", want: `3`},
+		{ptr: "/g|h", want: `4`},
+		{ptr: "/i\\j", want: `5`},
+		{ptr: "/k\"l", want: `6`},
+		{ptr: "/ ", want: `7`},
+		{ptr: "/m~0n", want: `8`},
+		{ptr: "/missing", wantErr: true},
+		{ptr: "/foo/2", wantErr: true},
+		{ptr: "/foo/-", wantErr: true},
+		{ptr: "/foo/01", wantErr: true},
+		{ptr: "/foo/bar", wantErr: true},
+		{ptr: "/a~1b/x", wantErr: true},
+		{ptr: "/m~n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ptr, func(t *testing.T) {
+			got, err := doc.Get(tt.ptr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if j := compactJSON(t, got); j != tt.want {
+				t.Errorf("Get() = %s, want %s", j, tt.want)
+			}
+			if tt.ptr != "" && got.Pointer() != tt.ptr {
+				t.Errorf("Pointer() = %s, want %s", got.Pointer(), tt.ptr)
+			}
+		})
+	}
+}
+
+func TestNodeChange(t *testing.T) {
+	const doc = `{"a":{"b":1},"c":["x","y","z"]}`
+
+	tests := []struct {
+		name    string
+		op      string
+		ptr     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "set member", op: "set", ptr: "/a/b", value: `2`, want: `{"a":{"b":2},"c":["x","y","z"]}`},
+		{name: "set new member", op: "set", ptr: "/a/d", value: `{"e":true}`, want: `{"a":{"b":1,"d":{"e":true}},"c":["x","y","z"]}`},
+		{name: "set element", op: "set", ptr: "/c/1", value: `"Y"`, want: `{"a":{"b":1},"c":["x","Y","z"]}`},
+		{name: "set element out of range", op: "set", ptr: "/c/3", value: `"w"`, wantErr: true},
+		{name: "set element -", op: "set", ptr: "/c/-", value: `"w"`, wantErr: true},
+		{name: "set root", op: "set", ptr: "", value: `1`, wantErr: true},
+		{name: "set into primitive", op: "set", ptr: "/a/b/c", value: `1`, wantErr: true},
+		{name: "set under missing", op: "set", ptr: "/x/y", value: `1`, wantErr: true},
+		{name: "insert member", op: "insert", ptr: "/a/d", value: `"v"`, want: `{"a":{"b":1,"d":"v"},"c":["x","y","z"]}`},
+		{name: "insert first", op: "insert", ptr: "/c/0", value: `"w"`, want: `{"a":{"b":1},"c":["w","x","y","z"]}`},
+		{name: "insert middle", op: "insert", ptr: "/c/1", value: `"w"`, want: `{"a":{"b":1},"c":["x","w","y","z"]}`},
+		{name: "insert at length", op: "insert", ptr: "/c/3", value: `"w"`, want: `{"a":{"b":1},"c":["x","y","z","w"]}`},
+		{name: "insert -", op: "insert", ptr: "/c/-", value: `["w"]`, want: `{"a":{"b":1},"c":["x","y","z",["w"]]}`},
+		{name: "insert out of range", op: "insert", ptr: "/c/4", value: `"w"`, wantErr: true},
+		{name: "insert leading zero", op: "insert", ptr: "/c/01", value: `"w"`, wantErr: true},
+		{name: "delete member", op: "delete", ptr: "/a/b", want: `{"a":{},"c":["x","y","z"]}`},
+		{name: "delete first", op: "delete", ptr: "/c/0", want: `{"a":{"b":1},"c":["y","z"]}`},
+		{name: "delete middle", op: "delete", ptr: "/c/1", want: `{"a":{"b":1},"c":["x","z"]}`},
+		{name: "delete last", op: "delete", ptr: "/c/2", want: `{"a":{"b":1},"c":["x","y"]}`},
+		{name: "delete missing member", op: "delete", ptr: "/a/x", wantErr: true},
+		{name: "delete out of range", op: "delete", ptr: "/c/3", wantErr: true},
+		{name: "delete root", op: "delete", ptr: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testDocument(t, doc)
+
+			var err error
+			switch tt.op {
+			case "set":
+				err = n.Set(tt.ptr, testValue(t, tt.value))
+			case "insert":
+				err = n.Insert(tt.ptr, testValue(t, tt.value))
+			case "delete":
+				err = n.Delete(tt.ptr)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s() error = %v, wantErr %v", tt.op, err, tt.wantErr)
+			}
+			if err != nil {
+				if got, want := compactJSON(t, n), compactJSON(t, testDocument(t, doc)); got != want {
+					t.Errorf("document after the failure = %s, want %s", got, want)
+				}
+				return
+			}
+
+			if got := compactJSON(t, n); got != tt.want {
+				t.Errorf("document = %s, want %s", got, tt.want)
+			}
+			// the parents, keys and pointers follow the change, and the arrays are renumbered
+			checkParents(t, n)
+		})
+	}
+}
+
+// testValue returns the node of the JSON value.
+func testValue(t *testing.T, s string) *Node {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	n, err := NewNode(v)
+	if err != nil {
+		t.Fatalf("NewNode() error = %v", err)
+	}
+	return n
+}
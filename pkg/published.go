@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Published is the records of a document as published in the DNS, and the way they are published.
+type Published struct {
+	// Location is the name of the root records if `_did.<base>` is delegated to another zone.
+	Location string
+	// RRs is the TXT records of the current document under `_did.<base>`.
+	// The references to the shared subtrees are included, but not the records of the subtrees.
+	RRs []*ResorceRecord
+	// Archived is the TXT records of the previous versions under `v<id>._did.<base>`.
+	Archived []*ResorceRecord
+	// Compact reports whether the current document is in the compact encoding,
+	// and CompactOptions is the options it is encoded with.
+	Compact        bool
+	CompactOptions CompactOptions
+	// Labels is the label encoding of the current document in the tree encoding.
+	Labels LabelEncoding
+	// Version is the current version, or nil if the document is not versioned.
+	Version *Version
+	// Deactivated reports whether the tombstone is published.
+	Deactivated bool
+}
+
+// Names returns the owner names of the records, without duplicates.
+func (p *Published) Names() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, rr := range append(append([]*ResorceRecord{}, p.RRs...), p.Archived...) {
+		if !seen[rr.Name] {
+			seen[rr.Name] = true
+			names = append(names, rr.Name)
+		}
+	}
+
+	return names
+}
+
+// LookupPublished looks up the records published under `_did.<base>`, such as to update or delete
+// them as they are actually served. The records of the tree encoding are walked by their labels
+// as published, whichever label encoding they use, and the previous versions under
+// `v<id>._did.<base>` are walked as well. The base argument must be ended with a dot(root).
+// It returns ErrNotFound if no record is published.
+func LookupPublished(ctx context.Context, base string) (*Published, error) {
+	ctx = withBudget(ctx)
+
+	txt, name, err := lookupTXT(ctx, "_did."+base)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Published{Labels: LabelBase64}
+	if name != "_did."+base {
+		p.Location = name
+	}
+	if p.Version, err = parseVersionRecord(txt); err != nil {
+		return nil, recordFormatError(name, txt, err)
+	}
+	if ts, err := parseTombstone(txt); err != nil {
+		return nil, recordFormatError(name, txt, err)
+	} else if ts != nil {
+		p.Deactivated = true
+	}
+	p.Compact = isCompact(txt)
+	if p.Compact {
+		if p.CompactOptions, err = compactOptions(txt); err != nil {
+			return nil, recordFormatError(name, txt, err)
+		}
+	} else if !p.Deactivated {
+		if _, enc, _, err := parseRecords(name, txt); err == nil {
+			p.Labels = enc
+		}
+	}
+
+	if p.RRs, err = walkPublished(ctx, name, txt); err != nil {
+		return nil, err
+	}
+
+	if p.Version != nil {
+		for id := 1; id < p.Version.ID; id++ {
+			vTxt, vName, err := lookupTXT(ctx, fmt.Sprintf("v%d.%s", id, name))
+			if errors.Is(err, ErrNotFound) {
+				// the version may have been pruned
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			rrs, err := walkPublished(ctx, vName, vTxt)
+			if err != nil {
+				return nil, err
+			}
+			p.Archived = append(p.Archived, rrs...)
+		}
+	}
+
+	return p, nil
+}
+
+// walkPublished returns the records of the name, and the ones of the children of the node
+// in the tree encoding.
+func walkPublished(ctx context.Context, name string, txt []string) ([]*ResorceRecord, error) {
+	rrs := []*ResorceRecord{}
+	for _, v := range txt {
+		rrs = append(rrs, &ResorceRecord{Name: name, Class: "IN", Type: "TXT", TTL: 3600, Data: zoneData(v)})
+	}
+
+	if isCompact(txt) {
+		return rrs, nil
+	}
+	if ts, _ := parseTombstone(txt); ts != nil {
+		return rrs, nil
+	}
+
+	rType, _, values, err := parseRecords(name, txt)
+	if err != nil {
+		return nil, recordFormatError(name, txt, err)
+	}
+
+	labels := []string{}
+	switch rType {
+	case rValTypeMapPointer:
+		labels = values
+	case rValTypeArrayPointer:
+		count, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, recordFormatError(name, txt, err)
+		}
+		if err := budgetFrom(ctx).node(name, 0, count); err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			labels = append(labels, strconv.Itoa(i))
+		}
+	}
+
+	for _, label := range labels {
+		childTxt, childName, err := lookupTXT(ctx, fmt.Sprintf("%s.%s", label, name))
+		if err != nil {
+			return nil, err
+		}
+		children, err := walkPublished(ctx, childName, childTxt)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, children...)
+	}
+
+	return rrs, nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupPublished(t *testing.T) {
+	base := "example.com."
+	doc := testDocument(t, `{"id": "did:dnssec:example.com", "Key": ["a", {"b": true}]}`)
+	old := testDocument(t, `{"id": "did:dnssec:example.com", "old": 1}`)
+	at := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	base32, err := doc.TreeRRs(base, LabelBase32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := doc.CompactRRs(base, CompactOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	versioned := append(ArchiveRRs(old.RRs(base), base, 1), append(doc.RRs(base), Version{ID: 2, Time: at}.RR("_did."+base))...)
+
+	tests := []struct {
+		name        string
+		rrs         []*ResorceRecord
+		compact     bool
+		labels      LabelEncoding
+		version     int
+		deactivated bool
+	}{
+		{name: "base64 labels", rrs: doc.RRs(base), labels: LabelBase64},
+		{name: "base32 labels", rrs: base32, labels: LabelBase32},
+		{name: "compact", rrs: compact, compact: true, labels: LabelBase64},
+		{name: "versioned", rrs: versioned, labels: LabelBase64, version: 2},
+		{name: "deactivated", rrs: []*ResorceRecord{Tombstone(base, at)}, labels: LabelBase64, deactivated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useBackend(t, testZone(t, base, tt.rrs...))
+
+			p, err := LookupPublished(context.Background(), base)
+			if err != nil {
+				t.Fatalf("LookupPublished() error = %v", err)
+			}
+
+			if p.Compact != tt.compact || p.Labels != tt.labels || p.Deactivated != tt.deactivated {
+				t.Errorf("LookupPublished() = {Compact: %v, Labels: %v, Deactivated: %v}, want {%v, %v, %v}",
+					p.Compact, p.Labels, p.Deactivated, tt.compact, tt.labels, tt.deactivated)
+			}
+			if (p.Version == nil && tt.version != 0) || (p.Version != nil && p.Version.ID != tt.version) {
+				t.Errorf("LookupPublished() Version = %v, want %d", p.Version, tt.version)
+			}
+
+			all := append(append([]*ResorceRecord{}, p.RRs...), p.Archived...)
+			if diff := DiffRRs(tt.rrs, all); len(diff.Add) > 0 || len(diff.Delete) > 0 {
+				t.Errorf("LookupPublished() RRs = %s, want %s", rrStrings(all), rrStrings(tt.rrs))
+			}
+			if tt.compact && !p.CompactOptions.Compress {
+				t.Errorf("LookupPublished() CompactOptions = %+v, want compressed", p.CompactOptions)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		useBackend(t, testZone(t, base))
+
+		if _, err := LookupPublished(context.Background(), base); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupPublished() error = %v, want %v", err, ErrNotFound)
+		}
+	})
+}
+
+func rrStrings(rrs []*ResorceRecord) string {
+	lines := []string{}
+	for _, rr := range rrs {
+		lines = append(lines, rr.String())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
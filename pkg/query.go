@@ -0,0 +1,902 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QueryResult is the value matched by the JSONPath expression.
+type QueryResult struct {
+	// Path is the normalized JSONPath of the value (e.g. $.service[0].id).
+	Path string
+	Node *Node
+}
+
+// Query evaluates the JSONPath expression against the node.
+//
+// The supported syntax is the subset of RFC 9535: the child segments (.name, ['name'], [0], [*],
+// [start:end:step] and the unions of them), the descendant segments (..name, ..*), and the filters
+// comparing the relative path with a literal (e.g. [?@.type == 'Multikey']).
+func (n *Node) Query(expr string) ([]QueryResult, error) {
+	segments, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluateJSONPath(segments, &nodeTarget{n})
+}
+
+// QueryDID resolves the DID and evaluates the JSONPath expression against its document.
+// With the tree encoding, only the records the expression needs are looked up.
+// The DID URL may have the versionId or versionTime query parameter as Resolve does.
+func QueryDID(didURL string, expr string) ([]QueryResult, error) {
+	return QueryDIDContext(context.Background(), didURL, expr)
+}
+
+// QueryDIDContext is QueryDID with the context.
+func QueryDIDContext(ctx context.Context, didURL string, expr string) (results []QueryResult, err error) {
+	ctx, end := startResolveSpan(withBudget(ctx), "query", didURL)
+	defer func() { end(nil, err) }()
+
+	segments, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	node, name, txt, _, err := lookupDocument(ctx, didURL)
+	if err != nil {
+		return nil, err
+	}
+	if node != nil {
+		return evaluateJSONPath(segments, &nodeTarget{node})
+	}
+
+	return evaluateJSONPath(segments, &dnsTarget{ctx: ctx, name: name, txt: txt, loaded: true})
+}
+
+// queryTarget is the value which the JSONPath expression is evaluated against.
+type queryTarget interface {
+	typ() (NodeValType, error)
+	// keys returns the keys of the map or the indices of the array in order.
+	keys() ([]string, error)
+	// get returns the child with the key, or nil if not found.
+	get(key string) (queryTarget, error)
+	node() (*Node, error)
+}
+
+// nodeTarget is the target backed by the node in memory.
+type nodeTarget struct {
+	n *Node
+}
+
+func (t *nodeTarget) typ() (NodeValType, error) {
+	return t.n.Value.Type, nil
+}
+
+func (t *nodeTarget) keys() ([]string, error) {
+	keys := []string{}
+	if t.n.Value.Type == ValTypeMap || t.n.Value.Type == ValTypeArray {
+		for _, child := range *t.n.Children {
+			keys = append(keys, child.Key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (t *nodeTarget) get(key string) (queryTarget, error) {
+	if t.n.Value.Type != ValTypeMap && t.n.Value.Type != ValTypeArray {
+		return nil, nil
+	}
+
+	if child := t.n.GetChild(key); child != nil {
+		return &nodeTarget{child}, nil
+	}
+
+	return nil, nil
+}
+
+func (t *nodeTarget) node() (*Node, error) {
+	return t.n, nil
+}
+
+// dnsTarget is the target backed by the records of the tree encoding.
+// The records are looked up when the target is first inspected.
+type dnsTarget struct {
+	ctx    context.Context
+	name   string
+	key    string
+	depth  int
+	txt    []string
+	loaded bool
+
+	rType  rValType
+	enc    LabelEncoding
+	values []string
+	value  *NodeValue
+
+	// children caches the targets of the children not to look up the same records twice.
+	children map[string]*dnsTarget
+}
+
+func (t *dnsTarget) load() error {
+	if t.value != nil || t.values != nil {
+		return nil
+	}
+
+	if !t.loaded {
+		txt, canonical, err := lookupTXT(t.ctx, t.name)
+		if err != nil {
+			return err
+		}
+		t.name = canonical
+		t.txt = txt
+		t.loaded = true
+	}
+
+	rType, enc, values, err := parseRecords(t.name, t.txt)
+	if err != nil {
+		return recordFormatError(t.name, t.txt, err)
+	}
+
+	if rType == rValTypeReference {
+		ctx, err := followReference(t.ctx, t.name, values[0])
+		if err != nil {
+			return recordFormatError(t.name, t.txt, err)
+		}
+		t.ctx, t.name, t.txt, t.loaded = ctx, values[0], nil, false
+		return t.load()
+	}
+	t.ctx = withName(t.ctx, t.name)
+	t.rType = rType
+	t.enc = enc
+	t.values = values
+
+	children := 0
+	switch rType {
+	case rValTypeMapPointer:
+		children = len(values)
+	case rValTypeArrayPointer:
+		children, _ = strconv.Atoi(values[0])
+	}
+	if err := budgetFrom(t.ctx).node(t.name, t.depth, children); err != nil {
+		return err
+	}
+
+	if rType == rValTypePremitive {
+		if t.value, err = decodePrimitive(values); err != nil {
+			return recordFormatError(t.name, t.txt, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *dnsTarget) typ() (NodeValType, error) {
+	if err := t.load(); err != nil {
+		return 0, err
+	}
+
+	switch t.rType {
+	case rValTypeMapPointer:
+		return ValTypeMap, nil
+	case rValTypeArrayPointer:
+		return ValTypeArray, nil
+	default:
+		return t.value.Type, nil
+	}
+}
+
+func (t *dnsTarget) keys() ([]string, error) {
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	switch t.rType {
+	case rValTypeMapPointer:
+		for _, v := range t.values {
+			key, err := t.enc.decode(v)
+			if err != nil {
+				return nil, recordFormatError(t.name, t.txt, err)
+			}
+			keys = append(keys, key)
+		}
+
+	case rValTypeArrayPointer:
+		count, err := strconv.Atoi(t.values[0])
+		if err != nil {
+			return nil, recordFormatError(t.name, t.txt, err)
+		}
+		for i := 0; i < count; i++ {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	}
+
+	return keys, nil
+}
+
+func (t *dnsTarget) get(key string) (queryTarget, error) {
+	keys, err := t.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, k := range keys {
+		if k != key {
+			continue
+		}
+
+		if child, ok := t.children[key]; ok {
+			cacheHitsTotal.Inc()
+			return child, nil
+		}
+
+		// the labels of the map are taken from the record as they are
+		label := key
+		if t.rType == rValTypeMapPointer {
+			label = t.values[i]
+		}
+
+		if t.children == nil {
+			t.children = map[string]*dnsTarget{}
+		}
+		t.children[key] = &dnsTarget{
+			ctx:   t.ctx,
+			name:  fmt.Sprintf("%s.%s", label, t.name),
+			key:   key,
+			depth: t.depth + 1,
+		}
+		return t.children[key], nil
+	}
+
+	return nil, nil
+}
+
+func (t *dnsTarget) node() (*Node, error) {
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	// the node has been counted by load, and its children are at the depth below it
+	return buildNode(t.ctx, t.name, t.key, nil, t.depth, t.rType, t.enc, t.values, t.txt)
+}
+
+type selectorKind int
+
+const (
+	selectorName selectorKind = iota
+	selectorIndex
+	selectorWildcard
+	selectorSlice
+	selectorFilter
+)
+
+type selector struct {
+	kind  selectorKind
+	name  string
+	index int
+	// start, end and step of the slice; nil means the default.
+	start, end, step *int
+	filter           *filter
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// filter compares the value at the relative path with the literal.
+// If op is empty, it tests the existence of the value.
+type filter struct {
+	path    []selector
+	op      string
+	literal interface{}
+}
+
+type matched struct {
+	path   string
+	target queryTarget
+}
+
+func evaluateJSONPath(segments []segment, root queryTarget) ([]QueryResult, error) {
+	current := []matched{{path: "$", target: root}}
+
+	for _, seg := range segments {
+		candidates := current
+		if seg.descendant {
+			candidates = []matched{}
+			for _, m := range current {
+				descendants, err := collectDescendants(m)
+				if err != nil {
+					return nil, err
+				}
+				candidates = append(candidates, descendants...)
+			}
+		}
+
+		next := []matched{}
+		for _, m := range candidates {
+			for _, sel := range seg.selectors {
+				res, err := applySelector(sel, m)
+				if err != nil {
+					return nil, err
+				}
+				next = append(next, res...)
+			}
+		}
+		current = next
+	}
+
+	results := []QueryResult{}
+	for _, m := range current {
+		node, err := m.target.node()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, QueryResult{Path: m.path, Node: node})
+	}
+
+	return results, nil
+}
+
+func collectDescendants(m matched) ([]matched, error) {
+	res := []matched{m}
+
+	children, err := applySelector(selector{kind: selectorWildcard}, m)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		descendants, err := collectDescendants(child)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, descendants...)
+	}
+
+	return res, nil
+}
+
+func applySelector(sel selector, m matched) ([]matched, error) {
+	typ, err := m.target.typ()
+	if err != nil {
+		return nil, err
+	}
+	if typ != ValTypeMap && typ != ValTypeArray {
+		return nil, nil
+	}
+
+	child := func(key string) ([]matched, error) {
+		t, err := m.target.get(key)
+		if err != nil || t == nil {
+			return nil, err
+		}
+
+		path := childPath(m.path, key)
+		if typ == ValTypeArray {
+			i, _ := strconv.Atoi(key)
+			path = indexPath(m.path, i)
+		}
+		return []matched{{path: path, target: t}}, nil
+	}
+
+	switch sel.kind {
+	case selectorName:
+		if typ != ValTypeMap {
+			return nil, nil
+		}
+		return child(sel.name)
+
+	case selectorIndex:
+		if typ != ValTypeArray {
+			return nil, nil
+		}
+		keys, err := m.target.keys()
+		if err != nil {
+			return nil, err
+		}
+		i := sel.index
+		if i < 0 {
+			i += len(keys)
+		}
+		if i < 0 || i >= len(keys) {
+			return nil, nil
+		}
+		return child(strconv.Itoa(i))
+
+	case selectorWildcard, selectorFilter:
+		keys, err := m.target.keys()
+		if err != nil {
+			return nil, err
+		}
+
+		res := []matched{}
+		for _, k := range keys {
+			c, err := child(k)
+			if err != nil {
+				return nil, err
+			}
+			if sel.kind == selectorFilter {
+				if ok, err := sel.filter.test(c[0].target); err != nil {
+					return nil, err
+				} else if !ok {
+					continue
+				}
+			}
+			res = append(res, c...)
+		}
+		return res, nil
+
+	case selectorSlice:
+		if typ != ValTypeArray {
+			return nil, nil
+		}
+		keys, err := m.target.keys()
+		if err != nil {
+			return nil, err
+		}
+
+		res := []matched{}
+		for _, i := range sliceIndices(sel, len(keys)) {
+			c, err := child(strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, c...)
+		}
+		return res, nil
+	}
+
+	return nil, nil
+}
+
+// sliceIndices returns the indices selected by the slice as defined in RFC 9535.
+func sliceIndices(sel selector, size int) []int {
+	step := 1
+	if sel.step != nil {
+		step = *sel.step
+	}
+	if step == 0 {
+		return nil
+	}
+
+	normalize := func(i int) int {
+		if i < 0 {
+			return i + size
+		}
+		return i
+	}
+
+	clamp := func(i, lower, upper int) int {
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+
+	indices := []int{}
+	if step > 0 {
+		start, end := 0, size
+		if sel.start != nil {
+			start = clamp(normalize(*sel.start), 0, size)
+		}
+		if sel.end != nil {
+			end = clamp(normalize(*sel.end), 0, size)
+		}
+		for i := start; i < end; i += step {
+			indices = append(indices, i)
+		}
+	} else {
+		start, end := size-1, -1
+		if sel.start != nil {
+			start = clamp(normalize(*sel.start), -1, size-1)
+		}
+		if sel.end != nil {
+			end = clamp(normalize(*sel.end), -1, size-1)
+		}
+		for i := start; i > end; i += step {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+func (f *filter) test(t queryTarget) (bool, error) {
+	for _, sel := range f.path {
+		res, err := applySelector(sel, matched{path: "@", target: t})
+		if err != nil {
+			return false, err
+		}
+		if len(res) == 0 {
+			return f.op == "!=", nil
+		}
+		t = res[0].target
+	}
+
+	if f.op == "" {
+		return true, nil
+	}
+
+	typ, err := t.typ()
+	if err != nil {
+		return false, err
+	}
+	if typ == ValTypeMap || typ == ValTypeArray {
+		return f.op == "!=", nil
+	}
+
+	node, err := t.node()
+	if err != nil {
+		return false, err
+	}
+
+	cmp, ok := compareLiteral(node.Value, f.literal)
+	if !ok {
+		return f.op == "!=", nil
+	}
+
+	switch f.op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// compareLiteral compares the value with the literal of the same kind.
+// It returns false if they are not comparable.
+func compareLiteral(v *NodeValue, literal interface{}) (int, bool) {
+	switch l := literal.(type) {
+	case string:
+		if v.Type != ValTypeString {
+			return 0, false
+		}
+		return strings.Compare(v.String(), l), true
+
+	case float64:
+		if v.Type != ValTypeInt && v.Type != ValTypeFloat {
+			return 0, false
+		}
+		switch f := v.Float(); {
+		case f < l:
+			return -1, true
+		case f > l:
+			return 1, true
+		default:
+			return 0, true
+		}
+
+	case bool:
+		if v.Type != ValTypeBool || v.Bool() != l {
+			return 1, v.Type == ValTypeBool
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// jsonPathParser parses the JSONPath expression.
+type jsonPathParser struct {
+	expr string
+	pos  int
+}
+
+func parseJSONPath(expr string) ([]segment, error) {
+	p := &jsonPathParser{expr: strings.TrimSpace(expr)}
+	if !p.consume("$") {
+		return nil, p.errorf("expression must start with $")
+	}
+
+	segments := []segment{}
+	for !p.eof() {
+		seg, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+func (p *jsonPathParser) eof() bool {
+	return p.pos >= len(p.expr)
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *jsonPathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *jsonPathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid jsonpath at %d: %s; expr = %s", p.pos, fmt.Sprintf(format, args...), p.expr)
+}
+
+func (p *jsonPathParser) parseSegment() (segment, error) {
+	switch {
+	case p.consume(".."):
+		if p.peek() == '[' {
+			sels, err := p.parseBracket()
+			return segment{descendant: true, selectors: sels}, err
+		}
+		sel, err := p.parseDotSelector()
+		return segment{descendant: true, selectors: []selector{sel}}, err
+
+	case p.consume("."):
+		sel, err := p.parseDotSelector()
+		return segment{selectors: []selector{sel}}, err
+
+	case p.peek() == '[':
+		sels, err := p.parseBracket()
+		return segment{selectors: sels}, err
+
+	default:
+		return segment{}, p.errorf("unexpected character %q", p.peek())
+	}
+}
+
+func (p *jsonPathParser) parseDotSelector() (selector, error) {
+	if p.consume("*") {
+		return selector{kind: selectorWildcard}, nil
+	}
+
+	name := p.parseName()
+	if name == "" {
+		return selector{}, p.errorf("name is expected")
+	}
+
+	return selector{kind: selectorName, name: name}, nil
+}
+
+func (p *jsonPathParser) parseName() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == '_' || c == '-' || c >= 0x80 ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9' && p.pos > start) {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	return p.expr[start:p.pos]
+}
+
+func (p *jsonPathParser) parseBracket() ([]selector, error) {
+	if !p.consume("[") {
+		return nil, p.errorf("[ is expected")
+	}
+
+	sels := []selector{}
+	for {
+		p.skipSpaces()
+		sel, err := p.parseBracketSelector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+
+		p.skipSpaces()
+		if p.consume("]") {
+			return sels, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf(", or ] is expected")
+		}
+	}
+}
+
+func (p *jsonPathParser) parseBracketSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return selector{kind: selectorWildcard}, nil
+
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return selector{kind: selectorName, name: s}, err
+
+	case c == '?':
+		p.pos++
+		f, err := p.parseFilter()
+		return selector{kind: selectorFilter, filter: f}, err
+
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+
+	default:
+		return selector{}, p.errorf("unexpected character %q", c)
+	}
+}
+
+func (p *jsonPathParser) parseIndexOrSlice() (selector, error) {
+	parts := []*int{}
+	for i := 0; i < 3; i++ {
+		p.skipSpaces()
+
+		var n *int
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			v, err := p.parseInt()
+			if err != nil {
+				return selector{}, err
+			}
+			n = &v
+		}
+		parts = append(parts, n)
+
+		p.skipSpaces()
+		if !p.consume(":") {
+			break
+		}
+	}
+
+	if len(parts) == 1 {
+		if parts[0] == nil {
+			return selector{}, p.errorf("index is expected")
+		}
+		return selector{kind: selectorIndex, index: *parts[0]}, nil
+	}
+
+	sel := selector{kind: selectorSlice, start: parts[0], end: parts[1]}
+	if len(parts) == 3 {
+		sel.step = parts[2]
+	}
+	return sel, nil
+}
+
+func (p *jsonPathParser) parseInt() (int, error) {
+	start := p.pos
+	p.consume("-")
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+
+	i, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", p.expr[start:p.pos])
+	}
+	return i, nil
+}
+
+func (p *jsonPathParser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	sb := strings.Builder{}
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated escape")
+			}
+			e := p.peek()
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonPathParser) parseFilter() (*filter, error) {
+	p.skipSpaces()
+	paren := p.consume("(")
+	p.skipSpaces()
+
+	if !p.consume("@") {
+		return nil, p.errorf("filter must start with @")
+	}
+
+	f := &filter{}
+	for p.peek() == '.' || p.peek() == '[' {
+		var seg segment
+		var err error
+		if p.consume(".") {
+			var sel selector
+			sel, err = p.parseDotSelector()
+			seg.selectors = []selector{sel}
+		} else {
+			seg.selectors, err = p.parseBracket()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(seg.selectors) != 1 || (seg.selectors[0].kind != selectorName && seg.selectors[0].kind != selectorIndex) {
+			return nil, p.errorf("filter path must consist of names and indices")
+		}
+		f.path = append(f.path, seg.selectors[0])
+	}
+
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			f.op = op
+			break
+		}
+	}
+
+	if f.op != "" {
+		p.skipSpaces()
+		literal, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		f.literal = literal
+	}
+
+	p.skipSpaces()
+	if paren && !p.consume(")") {
+		return nil, p.errorf(") is expected")
+	}
+
+	return f, nil
+}
+
+func (p *jsonPathParser) parseLiteral() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		return p.parseString()
+	case p.consume("true"):
+		return true, nil
+	case p.consume("false"):
+		return false, nil
+	default:
+		start := p.pos
+		for !p.eof() && strings.IndexByte("+-.eE0123456789", p.peek()) >= 0 {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+		if err != nil {
+			return nil, p.errorf("invalid literal %q", p.expr[start:p.pos])
+		}
+		return f, nil
+	}
+}
@@ -0,0 +1,255 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryDocument is the document the JSONPath expressions are evaluated against in the tests.
+const queryDocument = `{
+	"id": "did:dnssec:example.com",
+	"a": [0, 1, 2, 3, 4, 5],
+	"vm": [
+		{"id": "#k1", "type": "Multikey", "n": 1},
+		{"id": "#k2", "type": "JsonWebKey", "n": 2, "x": true},
+		{"id": "#k3", "type": "Multikey", "n": 3, "x": false}
+	],
+	"o": {"b": {"c": "d"}, "k 1": "v"}
+}`
+
+// queryResults formats the results as "<path>=<json>".
+func queryResults(t *testing.T, results []QueryResult) []string {
+	t.Helper()
+
+	got := []string{}
+	for _, r := range results {
+		got = append(got, r.Path+"="+compactJSON(t, r.Node))
+	}
+	return got
+}
+
+func TestQuery(t *testing.T) {
+	doc := testDocument(t, queryDocument)
+
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{name: "root", expr: "$", want: []string{"$=" + compactJSON(t, doc)}},
+
+		// the name selectors
+		{name: "dot name", expr: "$.id", want: []string{`$.id="did:dnssec:example.com"`}},
+		{name: "quoted name", expr: "$['id']", want: []string{`$.id="did:dnssec:example.com"`}},
+		{name: "double quoted name", expr: `$["o"]["k 1"]`, want: []string{`$.o["k 1"]="v"`}},
+		{name: "nested names", expr: "$.o.b.c", want: []string{`$.o.b.c="d"`}},
+		{name: "missing name", expr: "$.x", want: []string{}},
+		{name: "name of an array", expr: "$.a.x", want: []string{}},
+		{name: "name of a primitive", expr: "$.id.x", want: []string{}},
+
+		// the index selectors
+		{name: "index", expr: "$.a[1]", want: []string{"$.a[1]=1"}},
+		{name: "negative index", expr: "$.a[-1]", want: []string{"$.a[5]=5"}},
+		{name: "index out of range", expr: "$.a[6]", want: []string{}},
+		{name: "negative index out of range", expr: "$.a[-7]", want: []string{}},
+		{name: "index of a map", expr: "$.o[0]", want: []string{}},
+
+		// the wildcard selectors
+		{name: "wildcard of an array", expr: "$.vm[*].id", want: []string{`$.vm[0].id="#k1"`, `$.vm[1].id="#k2"`, `$.vm[2].id="#k3"`}},
+		{name: "wildcard of a map", expr: "$.o.*", want: []string{`$.o.b={"c":"d"}`, `$.o["k 1"]="v"`}},
+		{name: "wildcard of a primitive", expr: "$.id[*]", want: []string{}},
+
+		// the slice selectors
+		{name: "slice", expr: "$.a[1:3]", want: []string{"$.a[1]=1", "$.a[2]=2"}},
+		{name: "slice without start", expr: "$.a[:2]", want: []string{"$.a[0]=0", "$.a[1]=1"}},
+		{name: "slice without end", expr: "$.a[4:]", want: []string{"$.a[4]=4", "$.a[5]=5"}},
+		{name: "negative slice", expr: "$.a[-2:]", want: []string{"$.a[4]=4", "$.a[5]=5"}},
+		{name: "negative end", expr: "$.a[:-4]", want: []string{"$.a[0]=0", "$.a[1]=1"}},
+		{name: "stepped slice", expr: "$.a[::2]", want: []string{"$.a[0]=0", "$.a[2]=2", "$.a[4]=4"}},
+		{name: "stepped slice with bounds", expr: "$.a[1:5:3]", want: []string{"$.a[1]=1", "$.a[4]=4"}},
+		{name: "reversed slice", expr: "$.a[::-1]", want: []string{"$.a[5]=5", "$.a[4]=4", "$.a[3]=3", "$.a[2]=2", "$.a[1]=1", "$.a[0]=0"}},
+		{name: "negative step with bounds", expr: "$.a[4:1:-2]", want: []string{"$.a[4]=4", "$.a[2]=2"}},
+		{name: "zero step", expr: "$.a[1:3:0]", want: []string{}},
+		{name: "slice out of range", expr: "$.a[10:]", want: []string{}},
+		{name: "clamped slice", expr: "$.a[-10:2]", want: []string{"$.a[0]=0", "$.a[1]=1"}},
+		{name: "empty slice", expr: "$.a[3:1]", want: []string{}},
+		{name: "slice of a map", expr: "$.o[0:1]", want: []string{}},
+
+		// the unions
+		{name: "union of indices", expr: "$.a[0,2]", want: []string{"$.a[0]=0", "$.a[2]=2"}},
+		{name: "union of names", expr: "$['id', 'o']['b']", want: []string{`$.o.b={"c":"d"}`}},
+		{name: "union of a slice and an index", expr: "$.a[4:, 0]", want: []string{"$.a[4]=4", "$.a[5]=5", "$.a[0]=0"}},
+
+		// the descendant segments
+		{name: "descendant name", expr: "$..c", want: []string{`$.o.b.c="d"`}},
+		{name: "descendant ids", expr: "$..id", want: []string{`$.id="did:dnssec:example.com"`, `$.vm[0].id="#k1"`, `$.vm[1].id="#k2"`, `$.vm[2].id="#k3"`}},
+		{name: "descendant wildcard", expr: "$.o..*", want: []string{`$.o.b={"c":"d"}`, `$.o["k 1"]="v"`, `$.o.b.c="d"`}},
+		{name: "descendant bracket", expr: "$..[1]", want: []string{"$.a[1]=1", `$.vm[1]={"id":"#k2","n":2,"type":"JsonWebKey","x":true}`}},
+
+		// the filters
+		{name: "filter equal", expr: "$.vm[?@.type == 'Multikey'].id", want: []string{`$.vm[0].id="#k1"`, `$.vm[2].id="#k3"`}},
+		{name: "filter not equal", expr: "$.vm[?@.type != 'Multikey'].id", want: []string{`$.vm[1].id="#k2"`}},
+		{name: "filter greater", expr: "$.vm[?@.n > 1].id", want: []string{`$.vm[1].id="#k2"`, `$.vm[2].id="#k3"`}},
+		{name: "filter greater or equal", expr: "$.vm[?@.n >= 2].id", want: []string{`$.vm[1].id="#k2"`, `$.vm[2].id="#k3"`}},
+		{name: "filter less", expr: "$.vm[?@.n < 2].id", want: []string{`$.vm[0].id="#k1"`}},
+		{name: "filter less or equal", expr: "$.vm[?@.n <= 2].id", want: []string{`$.vm[0].id="#k1"`, `$.vm[1].id="#k2"`}},
+		{name: "filter string comparison", expr: "$.vm[?@.id < '#k2'].id", want: []string{`$.vm[0].id="#k1"`}},
+		{name: "filter bool", expr: "$.vm[?@.x == false].id", want: []string{`$.vm[2].id="#k3"`}},
+		{name: "filter in parentheses", expr: "$.vm[?(@.x == true)].id", want: []string{`$.vm[1].id="#k2"`}},
+		{name: "filter of the current value", expr: "$.a[?@ >= 4]", want: []string{"$.a[4]=4", "$.a[5]=5"}},
+		{name: "filter with an index path", expr: "$[?@[0] == 0]", want: []string{"$.a=[0,1,2,3,4,5]"}},
+		{name: "filter of different kinds", expr: "$.vm[?@.n == '1'].id", want: []string{}},
+		{name: "filter not equal of different kinds", expr: "$.vm[?@.n != '1'].id", want: []string{`$.vm[0].id="#k1"`, `$.vm[1].id="#k2"`, `$.vm[2].id="#k3"`}},
+		{name: "filter comparing a map", expr: "$.o[?@ == 'd']", want: []string{}},
+		{name: "filter existence", expr: "$.vm[?@.x].id", want: []string{`$.vm[1].id="#k2"`, `$.vm[2].id="#k3"`}},
+		{name: "filter existence of the nested", expr: "$.o[?@.c]", want: []string{`$.o.b={"c":"d"}`}},
+		{name: "filter missing with not equal", expr: "$.vm[?@.x != true].id", want: []string{`$.vm[0].id="#k1"`, `$.vm[2].id="#k3"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := doc.Query(tt.expr)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if got := queryResults(t, results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	doc := testDocument(t, queryDocument)
+
+	for _, expr := range []string{
+		"",
+		"id",
+		"$id",
+		"$.",
+		"$..",
+		"$.1a",
+		"$[",
+		"$[0",
+		"$[0 1]",
+		"$[]",
+		"$['id",
+		`$["id\`,
+		"$[x]",
+		"$[-]",
+		"$[1:x]",
+		"$[?]",
+		"$[?x == 1]",
+		"$[?@.* == 1]",
+		"$[?@[0:1]]",
+		"$[?@.a == ]",
+		"$[?@.a == x]",
+		"$[?(@.a == 1]",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := doc.Query(expr); err == nil || !strings.Contains(err.Error(), "invalid jsonpath") {
+				t.Errorf("Query() error = %v, want invalid jsonpath", err)
+			}
+		})
+	}
+}
+
+func TestQueryDID(t *testing.T) {
+	doc := testDocument(t, queryDocument)
+	b := useBackend(t, testZone(t, "example.com.", doc.RRs("example.com.")...))
+
+	resolved, err := Resolve("did:dnssec:example.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	all := b.queries.Load()
+
+	tests := []struct {
+		name string
+		expr string
+		want []string
+		// queries is the number of the lookups, which is less than that of the whole document
+		queries int64
+	}{
+		// the root and the id
+		{name: "member", expr: "$.id", want: []string{`$.id="did:dnssec:example.com"`}, queries: 2},
+		// the root, vm, and the id of vm[1]
+		{name: "index", expr: "$.vm[1].id", want: []string{`$.vm[1].id="#k2"`}, queries: 4},
+		// the root, a, and a[4] and a[5]
+		{name: "slice", expr: "$.a[-2:]", want: []string{"$.a[4]=4", "$.a[5]=5"}, queries: 4},
+		// the root, vm, the 3 elements and their type, and the ids of 2 of them
+		{name: "filter", expr: "$.vm[?@.type == 'Multikey'].id", want: []string{`$.vm[0].id="#k1"`, `$.vm[2].id="#k3"`}, queries: 10},
+		{name: "missing", expr: "$.x.y", want: []string{}, queries: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b.queries.Store(0)
+			results, err := QueryDID("did:dnssec:example.com", tt.expr)
+			if err != nil {
+				t.Fatalf("QueryDID() error = %v", err)
+			}
+			if got := queryResults(t, results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryDID() = %q, want %q", got, tt.want)
+			}
+
+			// the results are the same as those against the resolved document
+			local, _ := resolved.Query(tt.expr)
+			if got, want := queryResults(t, results), queryResults(t, local); !reflect.DeepEqual(got, want) {
+				t.Errorf("QueryDID() = %q, want %q as the resolved document", got, want)
+			}
+
+			if got := b.queries.Load(); got != tt.queries || got >= all {
+				t.Errorf("queries = %d, want %d of %d", got, tt.queries, all)
+			}
+		})
+	}
+
+	t.Run("invalid expression", func(t *testing.T) {
+		b.queries.Store(0)
+		if _, err := QueryDID("did:dnssec:example.com", "$["); err == nil {
+			t.Errorf("QueryDID() succeeded")
+		}
+		if got := b.queries.Load(); got != 0 {
+			t.Errorf("queries = %d, want none before the expression is parsed", got)
+		}
+	})
+}
+
+func TestQueryDIDEncodings(t *testing.T) {
+	doc := testDocument(t, queryDocument)
+	compact, err := doc.CompactRRs("c.example.com.", CompactOptions{})
+	if err != nil {
+		t.Fatalf("CompactRRs() error = %v", err)
+	}
+	rrs := append(doc.RRs("example.com."), compact...)
+	rrs = append(rrs, aliasRR("CNAME", "_did.d.example.com.", "_did.example.com."))
+	rrs = append(rrs, Tombstone("x.example.com.", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	useBackend(t, testZone(t, "example.com.", rrs...))
+
+	tests := []struct {
+		name string
+		did  string
+		expr string
+		want []string
+	}{
+		{name: "tree", did: "did:dnssec:example.com", expr: "$.vm[2].id", want: []string{`$.vm[2].id="#k3"`}},
+		{name: "compact", did: "did:dnssec:c.example.com", expr: "$.vm[2].id", want: []string{`$.vm[2].id="#k3"`}},
+		{name: "delegated", did: "did:dnssec:d.example.com", expr: "$.vm[2].id", want: []string{`$.vm[2].id="#k3"`}},
+		{name: "deactivated", did: "did:dnssec:x.example.com", expr: "$.id", want: []string{`$.id="did:dnssec:x.example.com"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := QueryDID(tt.did, tt.expr)
+			if err != nil {
+				t.Fatalf("QueryDID() error = %v", err)
+			}
+			if got := queryResults(t, results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryDID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
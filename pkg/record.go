@@ -0,0 +1,295 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Record is the content of a TXT record of the wire format: the tag-list similar to
+// DKIM (RFC 6376, Section 3.2) whose first tag is the version.
+//
+//	record    = "v" "=" version *( ";" tag-spec ) [ ";" ]
+//	tag-spec  = [FWS] tag-name [FWS] "=" [FWS] tag-value [FWS]
+//	tag-name  = ALPHA *( ALPHA / DIGIT / "_" )
+//	tag-value = quoted / *( VALCHAR / "\" CHAR )
+//	quoted    = DQUOTE *( any character but DQUOTE and "\" / "\" CHAR ) DQUOTE
+//
+// VALCHAR is any visible character but ";", and the whitespace between them.
+// The readers choose the records by the version they support, and ignore the tags they do not know,
+// so the new tags and versions can be published along with the current ones.
+type Record struct {
+	Version string
+	// Tags is the tags other than the version, in the order they appear.
+	Tags []Tag
+}
+
+// Tag is a tag of the record.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Get returns the value of the tag, including the version tag "v".
+func (r *Record) Get(name string) (string, bool) {
+	if name == "v" {
+		return r.Version, true
+	}
+
+	for _, t := range r.Tags {
+		if t.Name == name {
+			return t.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// RecordSyntaxError is the error for the record which does not conform to the grammar.
+type RecordSyntaxError struct {
+	Input string
+	// Offset is the byte offset in the input where the error is found.
+	Offset int
+	Msg    string
+}
+
+func (e *RecordSyntaxError) Error() string {
+	return fmt.Sprintf("invalid record at %d: %s; got = %s", e.Offset, e.Msg, e.Input)
+}
+
+// ParseRecord parses the content of the TXT record.
+// It fails with RecordSyntaxError if the record has a syntax error, a duplicated tag,
+// or does not start with the version tag.
+func ParseRecord(s string) (*Record, error) {
+	p := &recordParser{input: s}
+	r := &Record{}
+	seen := map[string]bool{}
+
+	for {
+		p.skipFWS()
+		if p.eof() {
+			break
+		}
+
+		start := p.pos
+		name, value, err := p.parseTag()
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, p.errorAt(start, "duplicated tag %q", name)
+		}
+		seen[name] = true
+
+		if len(seen) == 1 {
+			if name != "v" {
+				return nil, p.errorAt(start, "version tag must come first")
+			}
+			if value == "" {
+				return nil, p.errorAt(start, "version must not be empty")
+			}
+			r.Version = value
+		} else {
+			r.Tags = append(r.Tags, Tag{Name: name, Value: value})
+		}
+
+		p.skipFWS()
+		if !p.eof() && !p.consume(';') {
+			return nil, p.errorAt(p.pos, "';' expected")
+		}
+	}
+
+	if r.Version == "" {
+		return nil, p.errorAt(0, "version tag is required")
+	}
+
+	return r, nil
+}
+
+// FormatRecord formats the record into the content of the TXT record,
+// separating the tags with "; ". The values which cannot be written as they are, such as the ones
+// with ";" or the surrounding whitespace, are quoted. The tag names must conform to the grammar.
+func FormatRecord(r *Record) string {
+	sb := strings.Builder{}
+	sb.WriteString("v=")
+	sb.WriteString(formatTagValue(r.Version))
+
+	for _, t := range r.Tags {
+		sb.WriteString("; ")
+		sb.WriteString(t.Name)
+		sb.WriteString("=")
+		sb.WriteString(formatTagValue(t.Value))
+	}
+
+	return sb.String()
+}
+
+// formatRecord formats the record of the version with the tags given as the name-value pairs.
+func formatRecord(version string, tags ...string) string {
+	r := &Record{Version: version}
+	for i := 0; i+1 < len(tags); i += 2 {
+		r.Tags = append(r.Tags, Tag{Name: tags[i], Value: tags[i+1]})
+	}
+
+	return FormatRecord(r)
+}
+
+// zoneData quotes the content of the TXT record for the zone file.
+func zoneData(content string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(content) + `"`
+}
+
+func formatTagValue(v string) string {
+	needsQuote := v != strings.TrimFunc(v, isFWS)
+	for _, c := range v {
+		if c == ';' || c == '"' || c == '\\' || c < 0x20 || c == 0x7f {
+			needsQuote = true
+			break
+		}
+	}
+
+	if !needsQuote {
+		return v
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+func isFWS(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// recordParser parses the record of the grammar described in Record.
+type recordParser struct {
+	input string
+	pos   int
+}
+
+func (p *recordParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *recordParser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *recordParser) consume(c byte) bool {
+	if !p.eof() && p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *recordParser) skipFWS() {
+	for !p.eof() && isFWS(rune(p.peek())) {
+		p.pos++
+	}
+}
+
+func (p *recordParser) errorAt(pos int, format string, args ...interface{}) error {
+	return &RecordSyntaxError{Input: p.input, Offset: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseTag parses the tag-spec at the position, which must not be preceded by FWS.
+func (p *recordParser) parseTag() (string, string, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return "", "", err
+	}
+
+	p.skipFWS()
+	if !p.consume('=') {
+		return "", "", p.errorAt(p.pos, "'=' expected after the tag name %q", name)
+	}
+	p.skipFWS()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, value, nil
+}
+
+func (p *recordParser) parseName() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		isAlpha := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if isAlpha || (p.pos > start && ((c >= '0' && c <= '9') || c == '_')) {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	if p.pos == start {
+		return "", p.errorAt(start, "tag name expected")
+	}
+
+	return p.input[start:p.pos], nil
+}
+
+func (p *recordParser) parseValue() (string, error) {
+	if !p.eof() && p.peek() == '"' {
+		return p.parseQuoted()
+	}
+
+	sb := strings.Builder{}
+	// the whitespace is kept only if it is followed by another character of the value
+	pending := ""
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == ';':
+			return sb.String(), nil
+		case isFWS(rune(c)):
+			pending += string(c)
+			p.pos++
+			continue
+		case c == '\\':
+			if p.pos+1 >= len(p.input) {
+				return "", p.errorAt(p.pos, "unterminated escape")
+			}
+			p.pos++
+			c = p.peek()
+		case c == '"':
+			return "", p.errorAt(p.pos, "unexpected quote in the value")
+		case c < 0x20 || c == 0x7f:
+			return "", p.errorAt(p.pos, "invalid character %q", c)
+		}
+
+		sb.WriteString(pending)
+		pending = ""
+		sb.WriteByte(c)
+		p.pos++
+	}
+
+	return sb.String(), nil
+}
+
+func (p *recordParser) parseQuoted() (string, error) {
+	start := p.pos
+	p.pos++
+
+	sb := strings.Builder{}
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if p.eof() {
+				return "", p.errorAt(p.pos-1, "unterminated escape")
+			}
+			sb.WriteByte(p.peek())
+			p.pos++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", p.errorAt(start, "unterminated quoted value")
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRecord(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *Record
+		wantErr bool
+	}{
+		{in: "v=did:dnssec; t=p; d=string=YQ", want: &Record{Version: "did:dnssec", Tags: []Tag{{"t", "p"}, {"d", "string=YQ"}}}},
+		{in: " v = a ;t=b; ", want: &Record{Version: "a", Tags: []Tag{{"t", "b"}}}},
+		{in: `v=a; d="x; \"y\" "`, want: &Record{Version: "a", Tags: []Tag{{"d", `x; "y" `}}}},
+		{in: `v=a; d=x\;y  z`, want: &Record{Version: "a", Tags: []Tag{{"d", "x;y  z"}}}},
+		{in: "v=a; d=", want: &Record{Version: "a", Tags: []Tag{{"d", ""}}}},
+		{in: "", wantErr: true},
+		{in: "t=p; v=a", wantErr: true},
+		{in: "v=", wantErr: true},
+		{in: "v=a; v=b", wantErr: true},
+		{in: "v=a; t=p; t=m", wantErr: true},
+		{in: "v=a; 1t=p", wantErr: true},
+		{in: "v=a; t", wantErr: true},
+		{in: `v=a; d="x`, wantErr: true},
+		{in: `v=a; d=x"y`, wantErr: true},
+		{in: `v=a; d=x\`, wantErr: true},
+		{in: "v=a; d=\x01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRecord(tt.in)
+			if tt.wantErr {
+				var rse *RecordSyntaxError
+				if !errors.As(err, &rse) {
+					t.Errorf("ParseRecord() error = %v, want RecordSyntaxError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRecord() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "v=did:dnssec; t=m; d=a,b", want: "did:dnssec"},
+		{in: " v=did:dnssec:b32 ; t=?", want: "did:dnssec:b32"},
+		{in: `v="did:dnssec"; t`, want: "did:dnssec"},
+		{in: "t=p; v=did:dnssec", want: ""},
+		{in: "spf1 include:example.com", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := recordVersion(tt.in); got != tt.want {
+			t.Errorf("recordVersion(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCodeRecords(t *testing.T) {
+	_, syntaxErr := ParseRecord("v=a; t")
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: syntaxErr, want: "invalidDidDocument"},
+		{err: recordFormatError("_did.example.com.", []string{"v=a; t"}, syntaxErr), want: "invalidDidDocument"},
+		{err: recordFormatError("_did.example.com.", nil, ErrNotFound), want: "notFound"},
+		{err: &LimitError{Limit: "MaxDepth"}, want: "internalError"},
+	}
+
+	for _, tt := range tests {
+		if got := ErrorCode(tt.err); got != tt.want {
+			t.Errorf("ErrorCode(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func FuzzParseRecord(f *testing.F) {
+	for _, s := range []string{
+		"v=did:dnssec; t=p; d=string=YQ",
+		"v=did:dnssec2; t=c; i=0; d=abc",
+		`v=a; d="x; \"y\" "`,
+		`v=a; d=x\;y  z`,
+		" v = a ;t=b; ",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		r, err := ParseRecord(s)
+		if err != nil {
+			var rse *RecordSyntaxError
+			if !errors.As(err, &rse) {
+				t.Fatalf("ParseRecord(%q) error = %v, want RecordSyntaxError", s, err)
+			}
+			return
+		}
+
+		formatted := FormatRecord(r)
+		got, err := ParseRecord(formatted)
+		if err != nil {
+			t.Fatalf("ParseRecord(FormatRecord(%q)) = ParseRecord(%q) error = %v", s, formatted, err)
+		}
+		if !reflect.DeepEqual(got, r) {
+			t.Fatalf("ParseRecord(FormatRecord(%q)) = %+v, want %+v", s, got, r)
+		}
+		if recordVersion(s) != r.Version {
+			t.Fatalf("recordVersion(%q) = %q, want %q", s, recordVersion(s), r.Version)
+		}
+	})
+}
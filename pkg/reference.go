@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The reference record tells that the subtree of the node is published at another name,
+// possibly in another zone:
+//
+//	`v=did:dnssec; t=r; d=<fqdn>`
+//	- fqdn: the name of the records of the subtree, which are looked up in place of the node.
+//
+// The resolver follows the references, and fails if a reference leads back to the name of the node
+// or of any of its ancestors. DedupeRRs publishes the subtrees shared among the documents with them.
+
+func referenceRR(name string, enc LabelEncoding, target string) *ResorceRecord {
+	return &ResorceRecord{
+		Name:  name,
+		Class: "IN",
+		Type:  "TXT",
+		TTL:   3600,
+		Data:  zoneData(formatRecord(enc.version(), "t", "r", "d", target)),
+	}
+}
+
+// namePath is the names of the node being resolved and of its ancestors.
+type namePath struct {
+	name   string
+	parent *namePath
+}
+
+type namePathKey struct{}
+
+// withName returns the context with the name of the node pushed onto the path of the resolution.
+func withName(ctx context.Context, name string) context.Context {
+	parent, _ := ctx.Value(namePathKey{}).(*namePath)
+	return context.WithValue(ctx, namePathKey{}, &namePath{name: strings.ToLower(dns.Fqdn(name)), parent: parent})
+}
+
+// followReference checks the reference from the node at the name, and returns the context to resolve the target.
+func followReference(ctx context.Context, name string, target string) (context.Context, error) {
+	if _, ok := dns.IsDomainName(target); !ok {
+		return nil, fmt.Errorf("invalid reference target; got = %s", target)
+	}
+
+	ctx = withName(ctx, name)
+	t := strings.ToLower(dns.Fqdn(target))
+	for p, _ := ctx.Value(namePathKey{}).(*namePath); p != nil; p = p.parent {
+		if p.name == t {
+			return nil, fmt.Errorf("reference cycle; name = %s, target = %s", name, target)
+		}
+	}
+
+	Logger().Debug("following reference", "name", name, "target", target)
+	return ctx, nil
+}
+
+// DedupeRRs returns the records of the documents in the tree encoding, sharing their identical subtrees.
+// The docs argument maps the base names of the documents to them.
+//
+// The maps and arrays other than the roots, which appear more than once among the documents
+// and take fewer records when shared, are published once under `<hash>.<shared>`,
+// and referenced from the names where they appear. The shared argument must be ended with a dot(root).
+func DedupeRRs(docs map[string]*Node, shared string, enc LabelEncoding) ([]*ResorceRecord, error) {
+	bases := []string{}
+	for base, doc := range docs {
+		if err := checkLabelCollisions(doc, enc); err != nil {
+			return nil, err
+		}
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+
+	// the subtrees are identical if their records are, wherever they are published
+	hashes := map[*Node]string{}
+	sizes := map[string]int{}
+	nodes := map[string]*Node{}
+	var hash func(n *Node)
+	hash = func(n *Node) {
+		if n.Children == nil {
+			return
+		}
+		for _, child := range *n.Children {
+			hash(child)
+		}
+
+		rrs := n.nodeRRs("x.", enc, nil)
+		lines := []string{}
+		for _, rr := range rrs {
+			lines = append(lines, rr.String())
+		}
+		sort.Strings(lines)
+
+		sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+		h := strings.ToLower(base32Label.EncodeToString(sum[:15]))
+		hashes[n] = h
+		sizes[h] = len(rrs)
+		if _, ok := nodes[h]; !ok {
+			nodes[h] = n
+		}
+	}
+	for _, base := range bases {
+		hash(docs[base])
+	}
+
+	// start with sharing all the subtrees, and stop sharing the largest one which does not save records
+	// one by one, since its subtrees are referenced from where it appears once it is not shared
+	sharing := map[string]bool{}
+	for n, h := range hashes {
+		if n.Parent != nil {
+			sharing[h] = true
+		}
+	}
+	for {
+		uses := map[string]int{}
+		var count func(n *Node)
+		count = func(n *Node) {
+			for _, child := range *n.Children {
+				if h, ok := hashes[child]; ok {
+					if sharing[h] {
+						uses[h]++
+					} else {
+						count(child)
+					}
+				}
+			}
+		}
+		for _, base := range bases {
+			count(docs[base])
+		}
+		for h := range sharing {
+			count(nodes[h])
+		}
+
+		worst := ""
+		for h := range sharing {
+			if uses[h]*sizes[h] > uses[h]+sizes[h] {
+				continue
+			}
+			if worst == "" || sizes[h] > sizes[worst] || (sizes[h] == sizes[worst] && h < worst) {
+				worst = h
+			}
+		}
+		if worst == "" {
+			break
+		}
+		delete(sharing, worst)
+	}
+
+	ref := func(n *Node) string {
+		if h, ok := hashes[n]; ok && sharing[h] {
+			return fmt.Sprintf("%s.%s", h, shared)
+		}
+		return ""
+	}
+
+	rrs := []*ResorceRecord{}
+	for _, base := range bases {
+		rrs = append(rrs, docs[base].nodeRRs(fmt.Sprintf("_did.%s", base), enc, ref)...)
+	}
+
+	shares := []string{}
+	for h := range sharing {
+		shares = append(shares, h)
+	}
+	sort.Strings(shares)
+	for _, h := range shares {
+		rrs = append(rrs, nodes[h].nodeRRs(fmt.Sprintf("%s.%s", h, shared), enc, ref)...)
+	}
+
+	return rrs, nil
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFollowReference(t *testing.T) {
+	// the node at a.<root> is being resolved under the root
+	ctx := withName(withName(context.Background(), "_did.example.com."), "YQ._did.example.com")
+
+	tests := []struct {
+		name    string
+		from    string
+		target  string
+		wantErr bool
+	}{
+		{name: "another zone", from: "Yg.YQ._did.example.com.", target: "s.example.net."},
+		{name: "sibling", from: "Yg.YQ._did.example.com.", target: "Yw.YQ._did.example.com"},
+		{name: "child", from: "Yg.YQ._did.example.com.", target: "x.Yg.YQ._did.example.com."},
+		{name: "itself", from: "Yg.YQ._did.example.com.", target: "Yg.YQ._did.example.com.", wantErr: true},
+		{name: "itself without the root", from: "Yg.YQ._did.example.com.", target: "Yg.YQ._did.example.com", wantErr: true},
+		{name: "parent", from: "Yg.YQ._did.example.com.", target: "YQ._did.example.com.", wantErr: true},
+		{name: "root", from: "Yg.YQ._did.example.com.", target: "_did.example.com.", wantErr: true},
+		{name: "ancestor in another case", from: "Yg.YQ._did.example.com.", target: "_DID.Example.COM.", wantErr: true},
+		{name: "invalid target", from: "Yg.YQ._did.example.com.", target: "a..b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := followReference(ctx, tt.from, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("followReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// the name of the node is on the path to resolve the target, but not the target itself
+			p, _ := next.Value(namePathKey{}).(*namePath)
+			if p == nil || p.name != strings.ToLower(tt.from) || p.parent.name != "yq._did.example.com." {
+				t.Errorf("path of the target = %v, want %s on the path", p, tt.from)
+			}
+		})
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	root := txtRR("_did.example.com.", formatRecord(LabelBase64.version(), "t", "m", "d", "aWQ,YQ"))
+	id := txtRR("aWQ._did.example.com.", formatRecord(LabelBase64.version(), "t", "p", "d", "string=ZGlkOmRuc3NlYzpleGFtcGxlLmNvbQ"))
+	ref := func(name string, target string) *ResorceRecord {
+		return referenceRR(name, LabelBase64, target)
+	}
+	subtree := func(name string, doc string) []*ResorceRecord {
+		return testDocument(t, doc).nodeRRs(name, LabelBase64, nil)
+	}
+
+	// the chain of the references s0 -> s1 -> ... -> s19, which does not loop
+	chain := []*ResorceRecord{ref("YQ._did.example.com.", "s0.example.net.")}
+	for i := 0; i < 19; i++ {
+		chain = append(chain, ref(fmt.Sprintf("s%d.example.net.", i), fmt.Sprintf("s%d.example.net.", i+1)))
+	}
+	chain = append(chain, subtree("s19.example.net.", `{"b":1}`)...)
+
+	tests := []struct {
+		name   string
+		rrs    []*ResorceRecord
+		limits Limits
+		// want is the JSON of the document
+		want string
+		// wantErr is the substring of the error, or the limit exceeded with wantLimit
+		wantErr   string
+		wantLimit string
+	}{
+		{
+			name: "subtree in another zone",
+			rrs:  append([]*ResorceRecord{ref("YQ._did.example.com.", "s.example.net.")}, subtree("s.example.net.", `{"b":[1,{"c":"d"}]}`)...),
+			want: `{"a":{"b":[1,{"c":"d"}]},"id":"did:dnssec:example.com"}`,
+		},
+		{
+			name: "chain of references",
+			rrs:  chain,
+			want: `{"a":{"b":1},"id":"did:dnssec:example.com"}`,
+		},
+		{
+			name:    "reference to itself",
+			rrs:     []*ResorceRecord{ref("YQ._did.example.com.", "YQ._did.example.com.")},
+			wantErr: "reference cycle",
+		},
+		{
+			name:    "reference to the root",
+			rrs:     []*ResorceRecord{ref("YQ._did.example.com.", "_did.example.com.")},
+			wantErr: "reference cycle",
+		},
+		{
+			name: "reference to an ancestor from the subtree",
+			rrs: []*ResorceRecord{
+				ref("YQ._did.example.com.", "s.example.net."),
+				txtRR("s.example.net.", formatRecord(LabelBase64.version(), "t", "m", "d", "Yg,Yw")),
+				txtRR("Yg.s.example.net.", formatRecord(LabelBase64.version(), "t", "p", "d", "float=1")),
+				ref("Yw.s.example.net.", "_DID.example.com."),
+			},
+			wantErr: "reference cycle",
+		},
+		{
+			name: "loop of references",
+			rrs: []*ResorceRecord{
+				ref("YQ._did.example.com.", "s0.example.net."),
+				ref("s0.example.net.", "s1.example.net."),
+				ref("s1.example.net.", "s0.example.net."),
+			},
+			wantErr: "reference cycle",
+		},
+		{
+			// the depth is counted from the root of the document, not from the referenced name
+			name:      "depth of the referenced subtree",
+			rrs:       append([]*ResorceRecord{ref("YQ._did.example.com.", "s.example.net.")}, subtree("s.example.net.", `{"b":{"c":{"d":1}}}`)...),
+			limits:    Limits{MaxDepth: 3},
+			wantLimit: "MaxDepth",
+		},
+		{
+			// the chain is not a cycle, but each reference costs a lookup
+			name:      "chain of references over the limit",
+			rrs:       chain,
+			limits:    Limits{MaxQueries: 10},
+			wantLimit: "MaxQueries",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var com, net []*ResorceRecord
+			for _, rr := range append([]*ResorceRecord{root, id}, tt.rrs...) {
+				if strings.HasSuffix(rr.Name, ".example.net.") {
+					net = append(net, rr)
+				} else {
+					com = append(com, rr)
+				}
+			}
+			useBackend(t, testZone(t, "example.com.", com...), testZone(t, "example.net.", net...))
+			if tt.limits != (Limits{}) {
+				useLimits(t, tt.limits)
+			}
+
+			doc, err := Resolve("did:dnssec:example.com")
+			results, qerr := QueryDID("did:dnssec:example.com", "$.a")
+			for _, err := range []error{err, qerr} {
+				var le *LimitError
+				switch {
+				case tt.wantLimit != "":
+					if !errors.As(err, &le) || le.Limit != tt.wantLimit {
+						t.Errorf("error = %v, want %s exceeded", err, tt.wantLimit)
+					}
+				case tt.wantErr != "":
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Errorf("error = %v, want %s", err, tt.wantErr)
+					}
+				case err != nil:
+					t.Fatalf("error = %v", err)
+				}
+			}
+			if tt.wantErr != "" || tt.wantLimit != "" {
+				return
+			}
+
+			if got := compactJSON(t, doc); got != tt.want {
+				t.Errorf("Resolve() = %s, want %s", got, tt.want)
+			}
+			if got, want := compactJSON(t, results[0].Node), compactJSON(t, doc.GetChild("a")); got != want {
+				t.Errorf("QueryDID() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDedupeRRs(t *testing.T) {
+	const service = `[{"id": "#hub", "type": "LinkedDomains", "serviceEndpoint": "https://hub.example.org"}]`
+	docs := map[string]*Node{
+		"a.example.com.": testDocument(t, `{"id": "did:dnssec:a.example.com", "service": `+service+`, "k": {"x": 1}}`),
+		"b.example.com.": testDocument(t, `{"id": "did:dnssec:b.example.com", "service": `+service+`, "k": {"x": 2}, "n": {"x": 1}}`),
+		"c.example.com.": testDocument(t, `{"id": "did:dnssec:c.example.com", "service": `+service+`}`),
+	}
+
+	rrs, err := DedupeRRs(docs, "shared.example.com.", LabelBase64)
+	if err != nil {
+		t.Fatalf("DedupeRRs() error = %v", err)
+	}
+
+	// the service array is the only subtree shared, under <hash>.<shared>
+	roots := map[string]bool{}
+	for _, rr := range rrs {
+		if h := strings.TrimSuffix(rr.Name, ".shared.example.com."); h != rr.Name {
+			roots[h[strings.LastIndex(h, ".")+1:]+".shared.example.com."] = true
+		}
+	}
+	if len(roots) != 1 {
+		t.Fatalf("shared subtrees = %v, want the service only", roots)
+	}
+	var want string
+	for name := range roots {
+		want = referenceRR("", LabelBase64, name).Data
+	}
+	sharedRRs := 0
+	for _, rr := range rrs {
+		if strings.HasSuffix(rr.Name, ".shared.example.com.") {
+			sharedRRs++
+		}
+	}
+	if n := len(testValue(t, service).nodeRRs("x.", LabelBase64, nil)); sharedRRs != n {
+		t.Errorf("shared records = %d, want %d of the service published once", sharedRRs, n)
+	}
+
+	// it is referenced from every document
+	names := map[string]int{}
+	for _, rr := range rrs {
+		names[rr.Name]++
+	}
+	for base := range docs {
+		name := fmt.Sprintf("%s._did.%s", LabelBase64.encode("service"), base)
+		found := false
+		for _, rr := range rrs {
+			if rr.Name == name {
+				found = true
+				if rr.Data != want {
+					t.Errorf("record of %s = %s, want the reference %s", name, rr.Data, want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no record at %s", name)
+		}
+		// the elements are published once under the shared name
+		if names[fmt.Sprintf("MA.%s", name)] != 0 {
+			t.Errorf("the element of the service is published under %s", name)
+		}
+	}
+
+	// the map used twice does not save records when shared, and is published in place
+	for _, name := range []string{"aw._did.a.example.com.", "aw._did.b.example.com.", "bg._did.b.example.com."} {
+		for _, rr := range rrs {
+			if rr.Name == name && strings.Contains(rr.Data, "t=r") {
+				t.Errorf("record of %s = %s, want the map in place", name, rr.Data)
+			}
+		}
+	}
+
+	total := 0
+	for _, doc := range docs {
+		total += len(doc.RRs("example.com."))
+	}
+	if len(rrs) >= total {
+		t.Errorf("len(DedupeRRs()) = %d, want less than %d", len(rrs), total)
+	}
+
+	// the documents resolve from the deduplicated records as they are
+	useBackend(t, testZone(t, "example.com.", rrs...))
+	for base, doc := range docs {
+		did := "did:dnssec:" + strings.TrimSuffix(base, ".")
+		got, err := Resolve(did)
+		if err != nil {
+			t.Fatalf("Resolve(%s) error = %v", did, err)
+		}
+		if got, want := compactJSON(t, got), compactJSON(t, doc); got != want {
+			t.Errorf("Resolve(%s) = %s, want %s", did, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// Server is the authoritative nameserver of a zone. The zone can be swapped while serving,
+// and each query is answered from either the old or the new zone as a whole.
+type Server struct {
+	zone atomic.Pointer[Zone]
+}
+
+// NewServer returns the server of the zone.
+func NewServer(z *Zone) *Server {
+	s := &Server{}
+	s.zone.Store(z)
+	return s
+}
+
+// SetZone replaces the zone served by the server.
+func (s *Server) SetZone(z *Zone) {
+	s.zone.Store(z)
+}
+
+// Zone returns the zone served by the server.
+func (s *Server) Zone() *Zone {
+	return s.zone.Load()
+}
+
+// ServeDNS implements dns.Handler.
+// The answers over UDP are truncated to the size advertised with EDNS, or 512 bytes without it.
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := s.Zone().answer(r)
+
+	if _, ok := w.LocalAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+		m.Truncate(size)
+	}
+
+	rcode := dns.RcodeToString[m.Rcode]
+	serverQueriesTotal.WithLabelValues(rcode).Inc()
+	if len(r.Question) > 0 {
+		Logger().Debug("query", "name", r.Question[0].Name, "type", dns.TypeToString[r.Question[0].Qtype],
+			"rcode", rcode, "remote", w.RemoteAddr().String())
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		Logger().Debug("failed to write the answer", "remote", w.RemoteAddr().String(), "error", err)
+	}
+}
+
+// ListenAndServe serves the zone over both UDP and TCP at the address until the context is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	errc := make(chan error, 2)
+	go func() { errc <- (&dns.Server{PacketConn: pc, Handler: s}).ActivateAndServe() }()
+	go func() { errc <- (&dns.Server{Listener: l, Handler: s}).ActivateAndServe() }()
+	Logger().Info("serving zone", "zone", s.Zone().Origin(), "addr", addr)
+
+	// closing the listeners stops the servers
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errc:
+		return err
+	}
+}
+
+// answer returns the authoritative answer to the query from the zone.
+func (z *Zone) answer(r *dns.Msg) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Authoritative = true
+	m.RecursionAvailable = false
+
+	switch {
+	case r.Opcode != dns.OpcodeQuery:
+		return m.SetRcode(r, dns.RcodeNotImplemented)
+	case len(r.Question) != 1:
+		return m.SetRcode(r, dns.RcodeFormatError)
+	}
+
+	do := false
+	if opt := r.IsEdns0(); opt != nil {
+		do = opt.Do()
+		m.SetEdns0(4096, do)
+	}
+	dnssec := do && z.signed
+
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+	if (q.Qclass != dns.ClassINET && q.Qclass != dns.ClassANY) || !dns.IsSubDomain(z.origin, name) {
+		m.Authoritative = false
+		return m.SetRcode(r, dns.RcodeRefused)
+	}
+
+	rrsets, ok := z.rrsets[name]
+	if !ok {
+		var proof []dns.RR
+		if dnssec {
+			proof = z.closestEncloserProof(name)
+		}
+		m.Rcode = dns.RcodeNameError
+		m.Ns = z.negative(dnssec, proof...)
+		return m
+	}
+
+	types := []uint16{}
+	switch {
+	case q.Qtype == dns.TypeANY:
+		for t := range rrsets {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	case len(rrsets[q.Qtype]) > 0:
+		types = append(types, q.Qtype)
+	case len(rrsets[dns.TypeCNAME]) > 0:
+		// the resolver follows the alias
+		types = append(types, dns.TypeCNAME)
+	}
+
+	if len(types) == 0 {
+		var proof []dns.RR
+		if dnssec {
+			proof = z.nsec3(name)
+		}
+		m.Ns = z.negative(dnssec, proof...)
+		return m
+	}
+
+	for _, t := range types {
+		m.Answer = append(m.Answer, rrsets[t]...)
+		if dnssec {
+			m.Answer = append(m.Answer, z.sigs[name][t]...)
+		}
+	}
+
+	return m
+}
+
+// negative returns the authority section of the negative answer: the SOA record, whose TTL is
+// the negative caching TTL, and the proof of the nonexistence if signed.
+func (z *Zone) negative(dnssec bool, proof ...dns.RR) []dns.RR {
+	soa := dns.Copy(z.soa)
+	soa.Header().Ttl = z.soa.Minttl
+	ns := []dns.RR{soa}
+	if !dnssec {
+		return ns
+	}
+
+	for _, s := range z.sigs[z.origin][dns.TypeSOA] {
+		s = dns.Copy(s)
+		s.Header().Ttl = z.soa.Minttl
+		ns = append(ns, s)
+	}
+
+	return append(ns, proof...)
+}
+
+// closestEncloserProof returns the NSEC3 records proving the nonexistence of the name (RFC 5155 7.2.2):
+// the one matching the closest encloser, the one covering the next closer name,
+// and the one covering the wildcard at the closest encloser, with their signatures.
+func (z *Zone) closestEncloserProof(name string) []dns.RR {
+	// the origin exists, and the name is under it
+	next, ce := "", name
+	for {
+		i, _ := dns.NextLabel(ce, 0)
+		next, ce = ce, ce[i:]
+		if _, ok := z.rrsets[ce]; ok {
+			break
+		}
+	}
+
+	proof := []dns.RR{}
+	seen := map[string]bool{}
+	for _, n := range []string{ce, next, "*." + ce} {
+		rrs := z.nsec3(n)
+		if seen[rrs[0].Header().Name] {
+			continue
+		}
+		seen[rrs[0].Header().Name] = true
+		proof = append(proof, rrs...)
+	}
+
+	return proof
+}
@@ -0,0 +1,438 @@
+package core
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// recordingWriter is the dns.ResponseWriter which keeps the written message,
+// serving at the local address of the network.
+type recordingWriter struct {
+	local net.Addr
+	msg   *dns.Msg
+}
+
+func (w *recordingWriter) LocalAddr() net.Addr { return w.local }
+func (w *recordingWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53000}
+}
+func (w *recordingWriter) WriteMsg(m *dns.Msg) error   { w.msg = m; return nil }
+func (w *recordingWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *recordingWriter) Close() error                { return nil }
+func (w *recordingWriter) TsigStatus() error           { return nil }
+func (w *recordingWriter) TsigTimersOnly(bool)         {}
+func (w *recordingWriter) Hijack()                     {}
+
+// testQuery returns the query of the name and type, with the DO bit if do is set.
+func testQuery(name string, qtype uint16, do bool) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	if do {
+		m.SetEdns0(4096, true)
+	}
+	return m
+}
+
+// testSignedZone builds the zone of the origin from the records, signed with a generated key.
+func testSignedZone(t testing.TB, origin string, rrs ...*ResorceRecord) (*Zone, *dns.DNSKEY) {
+	t.Helper()
+
+	key, signer, err := GenerateZoneKey(origin)
+	if err != nil {
+		t.Fatalf("GenerateZoneKey() error = %v", err)
+	}
+	z, err := NewZone(origin, rrs, ZoneOptions{Key: key, Signer: signer})
+	if err != nil {
+		t.Fatalf("NewZone() error = %v", err)
+	}
+	return z, key
+}
+
+// verifySigs checks that every RRset in the records is signed by the key,
+// with the signature valid now.
+func verifySigs(t *testing.T, key *dns.DNSKEY, rrs []dns.RR) {
+	t.Helper()
+
+	type set struct {
+		name  string
+		rtype uint16
+	}
+	rrsets := map[set][]dns.RR{}
+	sigs := map[set][]*dns.RRSIG{}
+	for _, rr := range rrs {
+		if s, ok := rr.(*dns.RRSIG); ok {
+			k := set{strings.ToLower(s.Hdr.Name), s.TypeCovered}
+			sigs[k] = append(sigs[k], s)
+			continue
+		}
+		k := set{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+		rrsets[k] = append(rrsets[k], rr)
+	}
+
+	for k, rrset := range rrsets {
+		if len(sigs[k]) == 0 {
+			t.Errorf("RRset not signed; name = %s, type = %s", k.name, dns.TypeToString[k.rtype])
+			continue
+		}
+		for _, s := range sigs[k] {
+			if err := s.Verify(key, rrset); err != nil {
+				t.Errorf("RRSIG.Verify() error = %v; name = %s, type = %s", err, k.name, dns.TypeToString[k.rtype])
+			}
+			if !s.ValidityPeriod(time.Now()) {
+				t.Errorf("RRSIG not valid now; name = %s, type = %s", k.name, dns.TypeToString[k.rtype])
+			}
+		}
+	}
+	for k := range sigs {
+		if _, ok := rrsets[k]; !ok {
+			t.Errorf("RRSIG without the RRset; name = %s, type = %s", k.name, dns.TypeToString[k.rtype])
+		}
+	}
+}
+
+// nsec3s returns the NSEC3 records in the records.
+func nsec3s(rrs []dns.RR) []*dns.NSEC3 {
+	n := []*dns.NSEC3{}
+	for _, rr := range rrs {
+		if rr, ok := rr.(*dns.NSEC3); ok {
+			n = append(n, rr)
+		}
+	}
+	return n
+}
+
+func TestZoneAnswer(t *testing.T) {
+	z, err := NewZone("example.com.", []*ResorceRecord{
+		txtRR("_did.example.com.", "v=1"),
+		txtRR("a.b._did.example.com.", "v=2"),
+	}, ZoneOptions{NS: []string{"ns1.example.net", "ns2.example.net."}})
+	if err != nil {
+		t.Fatalf("NewZone() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		rcode     int
+		answer    []uint16
+		negative  bool
+		authority bool
+	}{
+		{name: "txt", qname: "_did.example.com.", qtype: dns.TypeTXT, rcode: dns.RcodeSuccess, answer: []uint16{dns.TypeTXT}, authority: true},
+		{name: "case insensitive", qname: "_DID.Example.COM.", qtype: dns.TypeTXT, rcode: dns.RcodeSuccess, answer: []uint16{dns.TypeTXT}, authority: true},
+		{name: "nodata", qname: "_did.example.com.", qtype: dns.TypeA, rcode: dns.RcodeSuccess, negative: true, authority: true},
+		{name: "empty non-terminal", qname: "b._did.example.com.", qtype: dns.TypeTXT, rcode: dns.RcodeSuccess, negative: true, authority: true},
+		{name: "nxdomain", qname: "c._did.example.com.", qtype: dns.TypeTXT, rcode: dns.RcodeNameError, negative: true, authority: true},
+		{name: "nxdomain below", qname: "x.a.b._did.example.com.", qtype: dns.TypeTXT, rcode: dns.RcodeNameError, negative: true, authority: true},
+		{name: "soa", qname: "example.com.", qtype: dns.TypeSOA, rcode: dns.RcodeSuccess, answer: []uint16{dns.TypeSOA}, authority: true},
+		{name: "ns", qname: "example.com.", qtype: dns.TypeNS, rcode: dns.RcodeSuccess, answer: []uint16{dns.TypeNS, dns.TypeNS}, authority: true},
+		{name: "any", qname: "example.com.", qtype: dns.TypeANY, rcode: dns.RcodeSuccess, answer: []uint16{dns.TypeNS, dns.TypeNS, dns.TypeSOA}, authority: true},
+		{name: "out of zone", qname: "example.org.", qtype: dns.TypeTXT, rcode: dns.RcodeRefused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := z.answer(testQuery(tt.qname, tt.qtype, false))
+
+			if m.Rcode != tt.rcode {
+				t.Errorf("Rcode = %s, want %s", dns.RcodeToString[m.Rcode], dns.RcodeToString[tt.rcode])
+			}
+			if m.Authoritative != tt.authority {
+				t.Errorf("Authoritative = %v, want %v", m.Authoritative, tt.authority)
+			}
+
+			got := []uint16{}
+			for _, rr := range m.Answer {
+				got = append(got, rr.Header().Rrtype)
+			}
+			if len(got) != len(tt.answer) {
+				t.Fatalf("Answer types = %v, want %v", got, tt.answer)
+			}
+			for i := range got {
+				if got[i] != tt.answer[i] {
+					t.Fatalf("Answer types = %v, want %v", got, tt.answer)
+				}
+			}
+
+			if !tt.negative {
+				if len(m.Ns) != 0 {
+					t.Errorf("Ns = %v, want none", m.Ns)
+				}
+				return
+			}
+			if len(m.Ns) != 1 {
+				t.Fatalf("Ns = %v, want the SOA record", m.Ns)
+			}
+			soa, ok := m.Ns[0].(*dns.SOA)
+			if !ok {
+				t.Fatalf("Ns[0] = %v, want the SOA record", m.Ns[0])
+			}
+			// the negative answers are cached for the minimum TTL of the SOA record
+			if soa.Hdr.Ttl != soa.Minttl {
+				t.Errorf("SOA TTL = %d, want %d", soa.Hdr.Ttl, soa.Minttl)
+			}
+		})
+	}
+}
+
+func TestZoneSynthesizedRecords(t *testing.T) {
+	z := testZone(t, "Example.COM", txtRR("_did.example.com.", "v=1"))
+
+	soa := z.answer(testQuery("example.com.", dns.TypeSOA, false)).Answer[0].(*dns.SOA)
+	if soa.Hdr.Name != "example.com." || soa.Ns != "ns.example.com." || soa.Mbox != "hostmaster.example.com." {
+		t.Errorf("SOA = %v, want the one of example.com. with ns.example.com.", soa)
+	}
+
+	ns := z.answer(testQuery("example.com.", dns.TypeNS, false)).Answer
+	if len(ns) != 1 || ns[0].(*dns.NS).Ns != "ns.example.com." {
+		t.Errorf("NS = %v, want ns.example.com.", ns)
+	}
+
+	if _, err := NewZone("example.com.", []*ResorceRecord{txtRR("_did.example.org.", "v=1")}, ZoneOptions{}); err == nil {
+		t.Errorf("NewZone() with the record out of zone succeeded")
+	}
+}
+
+func TestServerTruncation(t *testing.T) {
+	// the RRset of 40 records of 200 bytes does not fit in 4096 bytes
+	rrs := []*ResorceRecord{}
+	for i := 0; i < 40; i++ {
+		rrs = append(rrs, txtRR("_did.example.com.", strings.Repeat(string(rune('a'+i%26)), 199)+string(rune('a'+i/26))))
+	}
+	srv := NewServer(testZone(t, "example.com.", rrs...))
+
+	tests := []struct {
+		name      string
+		local     net.Addr
+		edns      bool
+		truncated bool
+	}{
+		{name: "udp", local: &net.UDPAddr{}, truncated: true},
+		{name: "udp with edns", local: &net.UDPAddr{}, edns: true, truncated: true},
+		{name: "tcp", local: &net.TCPAddr{}, truncated: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recordingWriter{local: tt.local}
+			srv.ServeDNS(w, testQuery("_did.example.com.", dns.TypeTXT, tt.edns))
+
+			if w.msg.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", w.msg.Truncated, tt.truncated)
+			}
+			if !tt.truncated && len(w.msg.Answer) != len(rrs) {
+				t.Errorf("len(Answer) = %d, want %d", len(w.msg.Answer), len(rrs))
+			}
+			if tt.truncated && w.msg.Len() > 4096 {
+				t.Errorf("Len() = %d, want up to 4096", w.msg.Len())
+			}
+		})
+	}
+}
+
+func TestZoneSigned(t *testing.T) {
+	z, key := testSignedZone(t, "example.com.",
+		txtRR("_did.example.com.", "v=1"),
+		txtRR("a.b._did.example.com.", "v=2"),
+	)
+
+	for _, q := range []struct {
+		name  string
+		qtype uint16
+	}{
+		{name: "_did.example.com.", qtype: dns.TypeTXT},
+		{name: "example.com.", qtype: dns.TypeSOA},
+		{name: "example.com.", qtype: dns.TypeNS},
+		{name: "example.com.", qtype: dns.TypeDNSKEY},
+		{name: "example.com.", qtype: dns.TypeNSEC3PARAM},
+	} {
+		m := z.answer(testQuery(q.name, q.qtype, true))
+		if len(m.Answer) == 0 {
+			t.Errorf("answer(%s, %s) has no answer", q.name, dns.TypeToString[q.qtype])
+		}
+		verifySigs(t, key, m.Answer)
+	}
+
+	// without the DO bit, the answer is not signed
+	m := z.answer(testQuery("_did.example.com.", dns.TypeTXT, false))
+	for _, rr := range m.Answer {
+		if rr.Header().Rrtype == dns.TypeRRSIG {
+			t.Errorf("answer without the DO bit has %v", rr)
+		}
+	}
+}
+
+func TestZoneNSEC3(t *testing.T) {
+	z, key := testSignedZone(t, "example.com.",
+		txtRR("_did.example.com.", "v=1"),
+		txtRR("a.b._did.example.com.", "v=2"),
+	)
+
+	t.Run("nodata", func(t *testing.T) {
+		m := z.answer(testQuery("_did.example.com.", dns.TypeA, true))
+		if m.Rcode != dns.RcodeSuccess || len(m.Answer) != 0 {
+			t.Fatalf("answer() = %s with %d answers, want NODATA", dns.RcodeToString[m.Rcode], len(m.Answer))
+		}
+		verifySigs(t, key, m.Ns)
+
+		n := nsec3s(m.Ns)
+		if len(n) != 1 || !n[0].Match("_did.example.com.") {
+			t.Fatalf("NSEC3 = %v, want the one matching the name", n)
+		}
+		for _, typ := range n[0].TypeBitMap {
+			if typ == dns.TypeA {
+				t.Errorf("NSEC3 type bitmap = %v, has A", n[0].TypeBitMap)
+			}
+		}
+		if !hasType(n[0].TypeBitMap, dns.TypeTXT) || !hasType(n[0].TypeBitMap, dns.TypeRRSIG) {
+			t.Errorf("NSEC3 type bitmap = %v, want TXT and RRSIG", n[0].TypeBitMap)
+		}
+	})
+
+	t.Run("empty non-terminal", func(t *testing.T) {
+		m := z.answer(testQuery("b._did.example.com.", dns.TypeTXT, true))
+		verifySigs(t, key, m.Ns)
+
+		n := nsec3s(m.Ns)
+		if len(n) != 1 || !n[0].Match("b._did.example.com.") || len(n[0].TypeBitMap) != 0 {
+			t.Fatalf("NSEC3 = %v, want the one matching the name without types", n)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		qname    string
+		encloser string
+		next     string
+	}{
+		{name: "child of the root", qname: "c._did.example.com.", encloser: "_did.example.com.", next: "c._did.example.com."},
+		{name: "below the empty non-terminal", qname: "x.y.b._did.example.com.", encloser: "b._did.example.com.", next: "y.b._did.example.com."},
+		{name: "child of the origin", qname: "www.example.com.", encloser: "example.com.", next: "www.example.com."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := z.answer(testQuery(tt.qname, dns.TypeTXT, true))
+			if m.Rcode != dns.RcodeNameError {
+				t.Fatalf("Rcode = %s, want NXDOMAIN", dns.RcodeToString[m.Rcode])
+			}
+			verifySigs(t, key, m.Ns)
+
+			// the closest encloser proof: the closest encloser exists, and the next closer name
+			// and the wildcard at the closest encloser do not
+			var match, next, wildcard bool
+			for _, n := range nsec3s(m.Ns) {
+				match = match || n.Match(tt.encloser)
+				next = next || n.Cover(tt.next)
+				wildcard = wildcard || n.Cover("*."+tt.encloser)
+			}
+			if !match || !next || !wildcard {
+				t.Errorf("NSEC3 proves closest encloser = %v, next closer = %v, wildcard = %v; want all",
+					match, next, wildcard)
+			}
+		})
+	}
+}
+
+func hasType(types []uint16, typ uint16) bool {
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// serveTestZone serves the zone over UDP and TCP at a free port of the loopback until the test ends,
+// and returns the address.
+func serveTestZone(t *testing.T, srv *Server) string {
+	t.Helper()
+
+	// the port free for UDP is used for TCP as well
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe(ctx, addr) }()
+	t.Cleanup(func() {
+		cancel()
+		<-errc
+	})
+
+	// wait until both of the servers answer
+	for _, network := range []string{"udp", "tcp"} {
+		c := &dns.Client{Net: network, Timeout: time.Second}
+		for i := 0; ; i++ {
+			if _, _, err := c.Exchange(testQuery(srv.Zone().Origin(), dns.TypeSOA, false), addr); err == nil {
+				break
+			} else if i == 50 {
+				t.Fatalf("server at %s/%s not answering: %v", addr, network, err)
+			}
+			select {
+			case err := <-errc:
+				t.Fatalf("ListenAndServe() error = %v", err)
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+
+	return addr
+}
+
+func TestServerListenAndServe(t *testing.T) {
+	// the document too large for UDP is resolved over TCP
+	large := testDocument(t, `{"id": "did:dnssec:example.com", "x": "`+strings.Repeat("x", 6000)+`"}`)
+	small := testDocument(t, `{"id": "did:dnssec:example.com"}`)
+
+	rrs := large.RRs("example.com.")
+	srv := NewServer(testZone(t, "example.com.", rrs...))
+	addr := serveTestZone(t, srv)
+	SetNameservers([]string{addr})
+	t.Cleanup(func() { SetNameservers(nil) })
+
+	name := ""
+	for _, rr := range rrs {
+		if len(rr.Data) > 6000 {
+			name = rr.Name
+		}
+	}
+	udp, _, err := (&dns.Client{Net: "udp"}).Exchange(testQuery(name, dns.TypeTXT, true), addr)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if !udp.Truncated {
+		t.Fatalf("answer over UDP is not truncated")
+	}
+
+	got, err := Resolve("did:dnssec:example.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want, _ := large.JSON()
+	if b, _ := got.JSON(); string(b) != string(want) {
+		t.Errorf("Resolve() = %.100s..., want %.100s...", b, want)
+	}
+
+	// the swapped zone is served at once
+	srv.SetZone(testZone(t, "example.com.", small.RRs("example.com.")...))
+
+	got, err = Resolve("did:dnssec:example.com")
+	if err != nil {
+		t.Fatalf("Resolve() after SetZone() error = %v", err)
+	}
+	want, _ = small.JSON()
+	if b, _ := got.JSON(); string(b) != string(want) {
+		t.Errorf("Resolve() after SetZone() = %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store is the file-based store of the DID documents hosted by the server.
+// Each document is stored as a JSON file named after the base of its DID in the directory,
+// and written atomically by renaming the temporary file over it.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenStore opens the store in the directory, creating it if it does not exist.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// path returns the path of the file of the document published under the base.
+func (s *Store) path(base string) string {
+	return filepath.Join(s.dir, strings.TrimSuffix(base, ".")+".json")
+}
+
+// Get returns the document published under the base.
+func (s *Store) Get(base string) (*Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bytes, err := os.ReadFile(s.path(base))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w; base = %s", ErrNotFound, base)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateFromJSON(bytes)
+}
+
+// Put stores the document published under the base, replacing the existing one.
+func (s *Store) Put(base string, doc *Node) error {
+	bytes, err := doc.JSON()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(bytes); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(base))
+}
+
+// Delete removes the document published under the base.
+func (s *Store) Delete(base string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(base))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w; base = %s", ErrNotFound, base)
+	}
+
+	return err
+}
+
+// All returns all the documents in the store, mapping the bases to them.
+func (s *Store) All() (map[string]*Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	docs := map[string]*Node{}
+	for _, path := range paths {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := CreateFromJSON(bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		docs[strings.TrimSuffix(filepath.Base(path), ".json")+"."] = doc
+	}
+
+	return docs, nil
+}
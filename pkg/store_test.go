@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	s, err := OpenStore(dir)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+
+	if _, err := s.Get("example.com."); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of the missing document error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("example.com."); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of the missing document error = %v, want ErrNotFound", err)
+	}
+
+	docs := map[string]string{
+		"example.com.":   `{"id":"did:dnssec:example.com","n":1}`,
+		"a.example.com.": `{"id":"did:dnssec:a.example.com"}`,
+		"b.example.com.": `{"id":"did:dnssec:b.example.com"}`,
+	}
+	for base, doc := range docs {
+		if err := s.Put(base, testDocument(t, doc)); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if err := s.Put("example.com.", testDocument(t, `{"id":"did:dnssec:example.com","n":2}`)); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	docs["example.com."] = `{"id":"did:dnssec:example.com","n":2}`
+	if err := s.Delete("b.example.com."); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	delete(docs, "b.example.com.")
+
+	// the documents persist in the directory, without the temporary files
+	reopened, err := OpenStore(dir)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	all, err := reopened.All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if len(all) != len(docs) {
+		t.Errorf("len(All()) = %d, want %d", len(all), len(docs))
+	}
+	for base, want := range docs {
+		got, err := reopened.Get(base)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", base, err)
+		}
+		for _, doc := range []*Node{got, all[base]} {
+			if doc == nil {
+				t.Errorf("All() has no %s", base)
+				continue
+			}
+			b, _ := doc.JSON()
+			w, _ := testDocument(t, want).JSON()
+			if string(b) != string(w) {
+				t.Errorf("document of %s = %s, want %s", base, b, w)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != len(docs) {
+		t.Errorf("files = %v, want only the documents", entries)
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrConflictingRecords is the error for the owner name with the records which disagree,
+// such as the multiple valid records or the unparseable ones, in the strict mode.
+var ErrConflictingRecords = errors.New("conflicting records")
+
+var strict atomic.Bool
+
+// SetStrict sets whether the resolutions are strict about the records at an owner name.
+//
+// In the strict mode, the resolution fails with ErrConflictingRecords if an owner name has
+// several valid records of the tree encoding, the unparseable records of its versions,
+// or the records of both the tree and compact encodings. In the lenient mode (the default),
+// the first valid record is used and the others are logged as warnings.
+func SetStrict(s bool) {
+	strict.Store(s)
+}
+
+// recordVersion returns the value of the version tag of the record, or the empty string if it has none.
+// Only the version tag is parsed with the grammar of ParseRecord; the rest may be invalid.
+func recordVersion(value string) string {
+	if r, err := ParseRecord(value); err == nil {
+		return r.Version
+	}
+
+	p := &recordParser{input: value}
+	p.skipFWS()
+	name, version, err := p.parseTag()
+	if err != nil || name != "v" {
+		return ""
+	}
+
+	return version
+}
+
+// isTreeRecord reports whether the record claims to be the node record of the tree encoding.
+// The version and tombstone records share the version, but not the types.
+func isTreeRecord(value string) bool {
+	if _, ok := treeVersions[recordVersion(value)]; !ok {
+		return false
+	}
+
+	if mapping, err := parseTagList(value); err == nil && (mapping["t"] == "v" || mapping["t"] == "x") {
+		return false
+	}
+
+	return true
+}
+
+// checkCompactConflict reports the records of the tree encoding mixed with the compact encoding,
+// which are left by a half-finished migration between the encodings.
+func checkCompactConflict(name string, txt []string) error {
+	var tree []string
+	for _, v := range txt {
+		if isTreeRecord(v) {
+			tree = append(tree, v)
+		}
+	}
+
+	if len(tree) == 0 {
+		return nil
+	}
+	return conflict(name, "records of the tree encoding mixed with the compact encoding", tree)
+}
+
+// conflict reports the records at the name which disagree; an error in the strict mode,
+// and a warning in the lenient mode.
+func conflict(name string, reason string, records []string) error {
+	if strict.Load() {
+		return fmt.Errorf("%w: %s; name = %s, records = %q", ErrConflictingRecords, reason, name, records)
+	}
+
+	Logger().Warn("conflicting records; "+reason, "name", name, "records", records)
+	return nil
+}
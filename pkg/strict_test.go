@@ -0,0 +1,78 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+// useStrict sets whether the resolutions are strict until the test ends.
+func useStrict(t testing.TB, s bool) {
+	t.Helper()
+
+	SetStrict(s)
+	t.Cleanup(func() { SetStrict(false) })
+}
+
+func TestStrict(t *testing.T) {
+	doc := testDocument(t, `{"id":"did:dnssec:example.com","n":1}`)
+	compact, err := testDocument(t, `{"id":"did:dnssec:example.com","n":3}`).CompactRRs("example.com.", CompactOptions{})
+	if err != nil {
+		t.Fatalf("CompactRRs() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		rrs  []*ResorceRecord
+		// want is the values of "n" which the lenient resolution may return
+		want []string
+	}{
+		{
+			name: "duplicate records",
+			rrs:  append(doc.RRs("example.com."), txtRR("bg._did.example.com.", "v=did:dnssec; t=p; d=float=2")),
+			want: []string{"1", "2"},
+		},
+		{
+			name: "invalid record of the tree encoding",
+			rrs:  append(doc.RRs("example.com."), txtRR("bg._did.example.com.", "v=did:dnssec; t=p; d=")),
+			want: []string{"1"},
+		},
+		{
+			name: "tree and compact encodings",
+			rrs:  append(compact, txtRR("_did.example.com.", "v=did:dnssec; t=m; d=aWQ,bg")),
+			want: []string{"3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useBackend(t, testZone(t, "example.com.", tt.rrs...))
+
+			t.Run("strict", func(t *testing.T) {
+				useStrict(t, true)
+
+				if _, err := Resolve("did:dnssec:example.com"); !errors.Is(err, ErrConflictingRecords) {
+					t.Errorf("Resolve() error = %v, want %v", err, ErrConflictingRecords)
+				}
+				if _, err := QueryDID("did:dnssec:example.com", "$.n"); !errors.Is(err, ErrConflictingRecords) {
+					t.Errorf("QueryDID() error = %v, want %v", err, ErrConflictingRecords)
+				}
+			})
+
+			t.Run("lenient", func(t *testing.T) {
+				useStrict(t, false)
+
+				doc, err := Resolve("did:dnssec:example.com")
+				if err != nil {
+					t.Fatalf("Resolve() error = %v", err)
+				}
+				n := doc.GetChildValue("n").String()
+				for _, want := range tt.want {
+					if n == want {
+						return
+					}
+				}
+				t.Errorf("n = %s, want one of %v", n, tt.want)
+			})
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the OpenTelemetry tracer of the package.
+const instrumentationName = "github.com/yum45f/did-dnssec/pkg"
+
+// The Prometheus collectors of the package. They are not registered by default;
+// call RegisterMetrics with the registry to expose them.
+var (
+	resolutionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "did_dnssec",
+		Name:      "resolutions_total",
+		Help:      "Number of DID resolutions by result (ok, deactivated or error).",
+	}, []string{"result"})
+
+	resolutionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "did_dnssec",
+		Name:      "resolution_duration_seconds",
+		Help:      "Latency of the DID resolutions.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	lookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "did_dnssec",
+		Name:      "lookups_total",
+		Help:      "Number of TXT lookups by rcode and DNSSEC status.",
+	}, []string{"rcode", "dnssec"})
+
+	lookupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "did_dnssec",
+		Name:      "lookup_duration_seconds",
+		Help:      "Latency of the TXT lookups.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
+	})
+
+	cacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "did_dnssec",
+		Name:      "cache_hits_total",
+		Help:      "Number of the records served from the cache instead of a lookup.",
+	})
+
+	validationFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "did_dnssec",
+		Name:      "validation_failures_total",
+		Help:      "Number of the documents rejected by Validate.",
+	})
+
+	serverQueriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "did_dnssec",
+		Name:      "server_queries_total",
+		Help:      "Number of the queries answered by the authoritative server by rcode.",
+	}, []string{"rcode"})
+)
+
+// RegisterMetrics registers the Prometheus collectors of the package to the registry.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		resolutionsTotal,
+		resolutionDuration,
+		lookupsTotal,
+		lookupDuration,
+		cacheHitsTotal,
+		validationFailuresTotal,
+		serverQueriesTotal,
+	} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tracer returns the tracer from the global TracerProvider, so that the provider set by
+// otel.SetTracerProvider after the package is loaded takes effect.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// startResolveSpan starts the span of the resolution of the DID.
+// The returned function ends the span and records the metrics of the result.
+func startResolveSpan(ctx context.Context, name string, did string) (context.Context, func(meta *DocumentMetadata, err error)) {
+	start := time.Now()
+	ctx, span := tracer().Start(ctx, name, trace.WithAttributes(attribute.String("did", did)))
+
+	return ctx, func(meta *DocumentMetadata, err error) {
+		result := "ok"
+		switch {
+		case err != nil:
+			result = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case meta != nil && meta.Deactivated:
+			result = "deactivated"
+		}
+		if meta != nil && meta.VersionID != "" {
+			span.SetAttributes(attribute.String("did.version_id", meta.VersionID))
+		}
+		span.SetAttributes(attribute.String("did.result", result))
+		span.End()
+
+		resolutionsTotal.WithLabelValues(result).Inc()
+		resolutionDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+// recordLookup records the span and metrics of the TXT query.
+func recordLookup(ctx context.Context, q QueryTrace) {
+	_, span := tracer().Start(ctx, "dns.query", trace.WithTimestamp(q.Start), trace.WithAttributes(
+		attribute.String("dns.question.name", q.Name),
+		attribute.String("dns.question.type", "TXT"),
+		attribute.String("dns.server", q.Server),
+		attribute.String("dns.rcode", q.Rcode),
+		attribute.Int64("dns.ttl", int64(q.TTL)),
+		attribute.String("dns.dnssec", q.DNSSEC),
+		attribute.Int("dns.records", q.Records),
+	))
+	if q.Err != nil {
+		span.RecordError(q.Err)
+		span.SetStatus(codes.Error, q.Err.Error())
+	}
+	span.End(trace.WithTimestamp(q.Start.Add(q.Latency)))
+
+	rcode := q.Rcode
+	if rcode == "" {
+		rcode = "NONE"
+	}
+	lookupsTotal.WithLabelValues(rcode, q.DNSSEC).Inc()
+	lookupDuration.Observe(q.Latency.Seconds())
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useSpanRecorder sets the TracerProvider exporting the spans into memory until the test ends.
+func useSpanRecorder(t testing.TB) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		tp.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+func spanAttribute(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestResolveSpans(t *testing.T) {
+	doc := testDocument(t, `{"id": "did:dnssec:example.com", "a": "b"}`)
+	useBackend(t, testZone(t, "example.com.", doc.RRs("example.com.")...))
+	exporter := useSpanRecorder(t)
+
+	if _, err := Resolve("did:dnssec:example.com"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if _, err := Resolve("did:dnssec:missing.example.com"); err == nil {
+		t.Fatalf("Resolve() error = nil, want not found")
+	}
+
+	roots := map[string]tracetest.SpanStub{}
+	queries := map[string][]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		switch s.Name {
+		case "resolve":
+			roots[spanAttribute(s, "did").AsString()] = s
+		case "dns.query":
+			queries[s.Parent.SpanID().String()] = append(queries[s.Parent.SpanID().String()], s)
+		default:
+			t.Errorf("unexpected span; name = %s", s.Name)
+		}
+	}
+
+	ok := roots["did:dnssec:example.com"]
+	if got := spanAttribute(ok, "did.result").AsString(); got != "ok" {
+		t.Errorf("did.result = %s, want ok", got)
+	}
+	// the root, id and a
+	if got := len(queries[ok.SpanContext.SpanID().String()]); got != 3 {
+		t.Errorf("dns.query spans = %d, want 3", got)
+	}
+	for _, q := range queries[ok.SpanContext.SpanID().String()] {
+		if got := spanAttribute(q, "dns.rcode").AsString(); got != "NOERROR" {
+			t.Errorf("dns.rcode = %s, want NOERROR; name = %s", got, spanAttribute(q, "dns.question.name").AsString())
+		}
+	}
+
+	failed := roots["did:dnssec:missing.example.com"]
+	if got := spanAttribute(failed, "did.result").AsString(); got != "error" {
+		t.Errorf("did.result = %s, want error", got)
+	}
+	if len(failed.Events) == 0 || failed.Events[0].Name != "exception" {
+		t.Errorf("events = %v, want the recorded error", failed.Events)
+	}
+	if got := spanAttribute(queries[failed.SpanContext.SpanID().String()][0], "dns.rcode").AsString(); got != "NXDOMAIN" {
+		t.Errorf("dns.rcode = %s, want NXDOMAIN", got)
+	}
+}
+
+func TestResolveMetrics(t *testing.T) {
+	doc := testDocument(t, `{"id": "did:dnssec:example.com", "a": "b"}`)
+	useBackend(t, testZone(t, "example.com.", doc.RRs("example.com.")...))
+
+	reg := prometheus.NewRegistry()
+	if err := RegisterMetrics(reg); err != nil {
+		t.Fatalf("RegisterMetrics() error = %v", err)
+	}
+
+	ok := testutil.ToFloat64(resolutionsTotal.WithLabelValues("ok"))
+	failed := testutil.ToFloat64(resolutionsTotal.WithLabelValues("error"))
+	lookups := testutil.ToFloat64(lookupsTotal.WithLabelValues("NOERROR", DNSSECInsecure))
+	nx := testutil.ToFloat64(lookupsTotal.WithLabelValues("NXDOMAIN", DNSSECInsecure))
+
+	Resolve("did:dnssec:example.com")
+	Resolve("did:dnssec:missing.example.com")
+
+	if got := testutil.ToFloat64(resolutionsTotal.WithLabelValues("ok")) - ok; got != 1 {
+		t.Errorf("resolutions_total{result=ok} += %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(resolutionsTotal.WithLabelValues("error")) - failed; got != 1 {
+		t.Errorf("resolutions_total{result=error} += %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(lookupsTotal.WithLabelValues("NOERROR", DNSSECInsecure)) - lookups; got != 3 {
+		t.Errorf("lookups_total{rcode=NOERROR} += %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(lookupsTotal.WithLabelValues("NXDOMAIN", DNSSECInsecure)) - nx; got != 1 {
+		t.Errorf("lookups_total{rcode=NXDOMAIN} += %v, want 1", got)
+	}
+
+	if n, err := testutil.GatherAndCount(reg, "did_dnssec_resolution_duration_seconds"); err != nil || n != 1 {
+		t.Errorf("GatherAndCount() = %d, %v, want 1", n, err)
+	}
+}
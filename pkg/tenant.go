@@ -0,0 +1,345 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The requests to the hosting API are authenticated in either of the ways:
+//
+//	`Authorization: Bearer <token>`
+//	- token: the token of the tenant, whose SHA-256 hash is in the tenant configuration.
+//
+//	`Authorization: Signature <tenant>:<time>:<nonce>:<signature>`
+//	- tenant: the name of the tenant.
+//	- time: the Unix time of the request, which must be within signatureSkew of the server's clock.
+//	- nonce: the random base64url string of the request, up to maxNonceLength characters;
+//	  a signed request is accepted only once.
+//	- signature: the base64url signature of
+//	  `<method>\n<host>\n<path>?<query>\n<time>\n<nonce>\n<hex of the SHA-256 of the body>`
+//	  with the key of the tenant; Ed25519, or ECDSA P-256 with SHA-256 in ASN.1 DER.
+//	  The host is in lowercase, and the path is escaped as in the request line;
+//	  the `?<query>` is omitted if the request has no query.
+//
+// SignRequest signs the request in the latter way.
+
+// signatureSkew is the maximum difference between the time of the signed request and the server's clock.
+const signatureSkew = 5 * time.Minute
+
+// maxNonceLength is the maximum length of the nonce of the signed request.
+const maxNonceLength = 64
+
+// Tenant is the tenant of the hosting API, who manages the DIDs of its domains.
+type Tenant struct {
+	Name string `json:"name"`
+	// TokenSHA256 is the SHA-256 hash of the bearer token in hex; the token itself is not stored.
+	TokenSHA256 string `json:"tokenSha256,omitempty"`
+	// PublicKeyJwk is the Ed25519 or P-256 public key to verify the signed requests.
+	PublicKeyJwk map[string]interface{} `json:"publicKeyJwk,omitempty"`
+	// Domains is the domains whose DIDs the tenant manages, including the DIDs of the subdomains
+	// and the ones with the sub-identifiers.
+	Domains []string `json:"domains"`
+
+	key crypto.PublicKey
+}
+
+// LoadTenants reads the tenants from the JSON array in the file.
+func LoadTenants(path string) ([]*Tenant, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := []*Tenant{}
+	if err := json.Unmarshal(bytes, &tenants); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := map[string]bool{}
+	for _, t := range tenants {
+		if err := t.init(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("%s: duplicated tenant; name = %s", path, t.Name)
+		}
+		names[t.Name] = true
+	}
+
+	return tenants, nil
+}
+
+// init checks the configuration of the tenant, and normalizes the domains and parses the key.
+func (t *Tenant) init() error {
+	if t.Name == "" || strings.Contains(t.Name, ":") {
+		return fmt.Errorf("invalid tenant name; got = %s", t.Name)
+	}
+	if t.TokenSHA256 == "" && t.PublicKeyJwk == nil {
+		return fmt.Errorf("tenant has neither token nor key; name = %s", t.Name)
+	}
+	if t.TokenSHA256 != "" {
+		if h, err := hex.DecodeString(t.TokenSHA256); err != nil || len(h) != sha256.Size {
+			return fmt.Errorf("invalid token hash; name = %s", t.Name)
+		}
+	}
+	if t.PublicKeyJwk != nil {
+		key, err := publicKeyFromJWK(t.PublicKeyJwk)
+		if err != nil {
+			return fmt.Errorf("invalid key; name = %s: %w", t.Name, err)
+		}
+		t.key = key
+	}
+
+	for i, d := range t.Domains {
+		fqdn, err := NormalizeDomain(d)
+		if err != nil {
+			return err
+		}
+		t.Domains[i] = fqdn
+	}
+
+	return nil
+}
+
+// Manages reports whether the tenant manages the DID.
+func (t *Tenant) Manages(did string) bool {
+	canonical, err := CanonicalDID(did)
+	if err != nil {
+		return false
+	}
+	domain := dns.Fqdn(strings.Split(canonical, ":")[2])
+
+	for _, d := range t.Domains {
+		if dns.IsSubDomain(d, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// publicKeyFromJWK returns the Ed25519 or P-256 public key of the JWK.
+func publicKeyFromJWK(jwk map[string]interface{}) (crypto.PublicKey, error) {
+	enc := base64.URLEncoding.WithPadding(base64.NoPadding)
+	coord := func(name string) ([]byte, error) {
+		s, _ := jwk[name].(string)
+		b, err := enc.DecodeString(s)
+		if err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid jwk coordinate; name = %s", name)
+		}
+		return b, nil
+	}
+
+	switch {
+	case jwk["kty"] == "OKP" && jwk["crv"] == "Ed25519":
+		x, err := coord("x")
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(x), nil
+
+	case jwk["kty"] == "EC" && jwk["crv"] == "P-256":
+		x, err := coord("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := coord("y")
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid jwk; the point is not on the curve")
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported jwk; kty = %v, crv = %v", jwk["kty"], jwk["crv"])
+	}
+}
+
+// signedMessage returns the message signed for the request.
+func signedMessage(method string, host string, uri string, ts string, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{method, strings.ToLower(host), uri, ts, nonce, hex.EncodeToString(sum[:])}, "\n"))
+}
+
+// requestHost returns the host the request is sent to.
+func requestHost(r *http.Request) string {
+	if r.Host != "" {
+		return r.Host
+	}
+	return r.URL.Host
+}
+
+// nonceCache is the nonces of the signed requests accepted within signatureSkew,
+// to reject the replays of them.
+type nonceCache struct {
+	mu sync.Mutex
+	// seen maps the tenants and the nonces to the time the requests expire.
+	seen map[string]time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{seen: map[string]time.Time{}}
+}
+
+// add records the nonce of the request of the tenant, which expires at the time.
+// It returns false if the nonce has been seen.
+func (c *nonceCache) add(tenant string, nonce string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range c.seen {
+		if now.After(exp) {
+			delete(c.seen, k)
+		}
+	}
+
+	key := tenant + ":" + nonce
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = expires
+	return true
+}
+
+// SignRequest signs the request to the hosting API with the key of the tenant,
+// an ed25519.PrivateKey or *ecdsa.PrivateKey of P-256, and sets the Authorization header.
+func SignRequest(r *http.Request, tenant string, key crypto.Signer) error {
+	body := []byte{}
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	n := make([]byte, 16)
+	if _, err := rand.Read(n); err != nil {
+		return err
+	}
+	nonce := base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(n)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	msg := signedMessage(r.Method, requestHost(r), r.URL.RequestURI(), ts, nonce, body)
+
+	var sig []byte
+	var err error
+	switch key.(type) {
+	case ed25519.PrivateKey:
+		sig, err = key.Sign(nil, msg, crypto.Hash(0))
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256(msg)
+		sig, err = key.Sign(nil, sum[:], crypto.SHA256)
+	default:
+		return fmt.Errorf("unsupported key; got = %T", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set("Authorization", fmt.Sprintf("Signature %s:%s:%s:%s",
+		tenant, ts, nonce, base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(sig)))
+	return nil
+}
+
+// authenticate returns the tenant of the request, whose body has been read into the argument.
+// The nonces of the signed requests are recorded in the cache.
+func authenticate(tenants []*Tenant, nonces *nonceCache, r *http.Request, body []byte) (*Tenant, error) {
+	scheme, cred, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	switch scheme {
+	case "Bearer":
+		sum := sha256.Sum256([]byte(cred))
+		h := hex.EncodeToString(sum[:])
+		for _, t := range tenants {
+			if t.TokenSHA256 != "" && subtle.ConstantTimeCompare([]byte(strings.ToLower(t.TokenSHA256)), []byte(h)) == 1 {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid token")
+
+	case "Signature":
+		parts := strings.Split(cred, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid signature; got = %s", cred)
+		}
+
+		var tenant *Tenant
+		for _, t := range tenants {
+			if t.Name == parts[0] && t.key != nil {
+				tenant = t
+			}
+		}
+		if tenant == nil {
+			return nil, fmt.Errorf("unknown tenant; got = %s", parts[0])
+		}
+
+		ts, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature time; got = %s", parts[1])
+		}
+		if d := time.Since(time.Unix(ts, 0)); d > signatureSkew || d < -signatureSkew {
+			return nil, fmt.Errorf("signature time out of range; got = %s", parts[1])
+		}
+
+		nonce := parts[2]
+		if _, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(nonce); err != nil ||
+			nonce == "" || len(nonce) > maxNonceLength {
+			return nil, fmt.Errorf("invalid signature nonce; got = %s", nonce)
+		}
+
+		sig, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature encoding")
+		}
+
+		msg := signedMessage(r.Method, requestHost(r), r.URL.RequestURI(), parts[1], nonce, body)
+		ok := false
+		switch key := tenant.key.(type) {
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(key, msg, sig)
+		case *ecdsa.PublicKey:
+			sum := sha256.Sum256(msg)
+			ok = ecdsa.VerifyASN1(key, sum[:], sig)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid signature; tenant = %s", tenant.Name)
+		}
+
+		// the nonce is recorded only for the valid signatures, so that the others cannot use it up;
+		// it is kept until the time of the request goes out of the range
+		if !nonces.add(tenant.Name, nonce, time.Unix(ts, 0).Add(signatureSkew)) {
+			return nil, fmt.Errorf("replayed signature; tenant = %s, nonce = %s", tenant.Name, nonce)
+		}
+		return tenant, nil
+
+	default:
+		return nil, fmt.Errorf("authorization required")
+	}
+}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTenants returns the tenants: alice with the bearer token "alice-token" managing a.example.com,
+// bob with the Ed25519 key managing b.example.com, and carol with the P-256 key managing c.example.com.
+func testTenants(t testing.TB) ([]*Tenant, ed25519.PrivateKey, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	enc := base64.URLEncoding.WithPadding(base64.NoPadding)
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+
+	token := sha256.Sum256([]byte("alice-token"))
+	tenants := []*Tenant{
+		{Name: "alice", TokenSHA256: hex.EncodeToString(token[:]), Domains: []string{"a.example.com"}},
+		{Name: "bob", PublicKeyJwk: map[string]interface{}{
+			"kty": "OKP", "crv": "Ed25519", "x": enc.EncodeToString(edPub),
+		}, Domains: []string{"b.example.com"}},
+		{Name: "carol", PublicKeyJwk: map[string]interface{}{
+			"kty": "EC", "crv": "P-256",
+			"x": enc.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			"y": enc.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		}, Domains: []string{"c.example.com"}},
+	}
+	for _, tenant := range tenants {
+		if err := tenant.init(); err != nil {
+			t.Fatalf("init() error = %v", err)
+		}
+	}
+
+	return tenants, edKey, ecKey
+}
+
+// authenticateRequest reads the body of the request, and authenticates it.
+func authenticateRequest(tenants []*Tenant, nonces *nonceCache, r *http.Request) (*Tenant, error) {
+	body := new(bytes.Buffer)
+	body.ReadFrom(r.Body)
+	return authenticate(tenants, nonces, r, body.Bytes())
+}
+
+// signedAt returns the Signature credential of bob's request signed at the time with the nonce.
+func signedAt(r *http.Request, key ed25519.PrivateKey, body string, ts time.Time, nonce string) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	sig := ed25519.Sign(key, signedMessage(r.Method, r.Host, r.URL.RequestURI(), t, nonce, []byte(body)))
+	return fmt.Sprintf("Signature bob:%s:%s:%s", t, nonce, base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(sig))
+}
+
+func TestAuthenticate(t *testing.T) {
+	tenants, edKey, ecKey := testTenants(t)
+	const target = "https://api.example.com/dids/did:dnssec:b.example.com?x=1"
+
+	tests := []struct {
+		name string
+		// request returns the request to authenticate
+		request func(t *testing.T) *http.Request
+		want    string
+	}{
+		{name: "bearer", want: "alice", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", "Bearer alice-token")
+			return r
+		}},
+		{name: "wrong bearer", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", "Bearer bob-token")
+			return r
+		}},
+		{name: "no authorization", request: func(t *testing.T) *http.Request {
+			return httptest.NewRequest(http.MethodGet, target, nil)
+		}},
+		{name: "ed25519", want: "bob", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"a":1}`))
+			if err := SignRequest(r, "bob", edKey); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+			return r
+		}},
+		{name: "p-256", want: "carol", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"a":1}`))
+			if err := SignRequest(r, "carol", ecKey); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+			return r
+		}},
+		{name: "key of another tenant", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, target, nil)
+			if err := SignRequest(r, "carol", edKey); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+			return r
+		}},
+		{name: "unknown tenant", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, target, nil)
+			if err := SignRequest(r, "mallory", edKey); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+			return r
+		}},
+		{name: "tenant without key", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, target, nil)
+			if err := SignRequest(r, "alice", edKey); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+			return r
+		}},
+		{name: "within skew", want: "bob", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", signedAt(r, edKey, "", time.Now().Add(-signatureSkew+time.Minute), "n1"))
+			return r
+		}},
+		{name: "expired", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", signedAt(r, edKey, "", time.Now().Add(-signatureSkew-time.Minute), "n1"))
+			return r
+		}},
+		{name: "future", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", signedAt(r, edKey, "", time.Now().Add(signatureSkew+time.Minute), "n1"))
+			return r
+		}},
+		{name: "empty nonce", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", signedAt(r, edKey, "", time.Now(), ""))
+			return r
+		}},
+		{name: "long nonce", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", signedAt(r, edKey, "", time.Now(), strings.Repeat("n", maxNonceLength+1)))
+			return r
+		}},
+		{name: "without nonce", request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			parts := strings.Split(signedAt(r, edKey, "", time.Now(), "n1"), ":")
+			r.Header.Set("Authorization", strings.Join(append(parts[:2], parts[3]), ":"))
+			return r
+		}},
+	}
+
+	// the signed parts of the request are tampered after signing
+	tampers := []struct {
+		name   string
+		tamper func(r *http.Request)
+	}{
+		{name: "method", tamper: func(r *http.Request) { r.Method = http.MethodDelete }},
+		{name: "host", tamper: func(r *http.Request) { r.Host = "api.example.org" }},
+		{name: "path", tamper: func(r *http.Request) { r.URL.Path = "/dids/did:dnssec:b.example.com:x" }},
+		{name: "query", tamper: func(r *http.Request) { r.URL.RawQuery = "x=2" }},
+		{name: "body", tamper: func(r *http.Request) { r.Body = http.NoBody }},
+	}
+	for _, tt := range tampers {
+		tamper := tt.tamper
+		tests = append(tests, struct {
+			name    string
+			request func(t *testing.T) *http.Request
+			want    string
+		}{name: "tampered " + tt.name, request: func(t *testing.T) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"a":1}`))
+			if err := SignRequest(r, "bob", edKey); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+			tamper(r)
+			return r
+		}})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authenticateRequest(tenants, newNonceCache(), tt.request(t))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("authenticate() = %s, want error", got.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticate() error = %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("authenticate() = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateReplay(t *testing.T) {
+	tenants, edKey, _ := testTenants(t)
+	nonces := newNonceCache()
+
+	r := httptest.NewRequest(http.MethodPut, "https://api.example.com/dids/did:dnssec:b.example.com", strings.NewReader(`{"a":1}`))
+	if err := SignRequest(r, "bob", edKey); err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+	replay := httptest.NewRequest(http.MethodPut, "https://api.example.com/dids/did:dnssec:b.example.com", strings.NewReader(`{"a":1}`))
+	replay.Header = r.Header.Clone()
+
+	if _, err := authenticateRequest(tenants, nonces, r); err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	if _, err := authenticateRequest(tenants, nonces, replay); err == nil {
+		t.Errorf("authenticate() of the replayed request succeeded")
+	}
+
+	// the nonce of an invalid signature is not recorded
+	forged := httptest.NewRequest(http.MethodGet, "https://api.example.com/dids/did:dnssec:b.example.com", nil)
+	forged.Header.Set("Authorization", "Signature bob:"+strconv.FormatInt(time.Now().Unix(), 10)+":n2:AAAA")
+	if _, err := authenticateRequest(tenants, nonces, forged); err == nil {
+		t.Fatalf("authenticate() of the forged request succeeded")
+	}
+	valid := httptest.NewRequest(http.MethodGet, "https://api.example.com/dids/did:dnssec:b.example.com", nil)
+	valid.Header.Set("Authorization", signedAt(valid, edKey, "", time.Now(), "n2"))
+	if _, err := authenticateRequest(tenants, nonces, valid); err != nil {
+		t.Errorf("authenticate() after the forged nonce error = %v", err)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	c := newNonceCache()
+
+	if !c.add("bob", "n1", time.Now().Add(-time.Second)) {
+		t.Fatalf("add() of the new nonce = false")
+	}
+	if !c.add("carol", "n2", time.Now().Add(time.Minute)) {
+		t.Fatalf("add() of the new nonce = false")
+	}
+	// the expired nonces are pruned
+	if len(c.seen) != 1 {
+		t.Errorf("len(seen) = %d, want 1", len(c.seen))
+	}
+	if !c.add("bob", "n1", time.Now().Add(time.Minute)) {
+		t.Errorf("add() of the expired nonce = false")
+	}
+	if c.add("carol", "n2", time.Now().Add(time.Minute)) {
+		t.Errorf("add() of the seen nonce = true")
+	}
+	if !c.add("bob", "n2", time.Now().Add(time.Minute)) {
+		t.Errorf("add() of the nonce of another tenant = false")
+	}
+}
+
+func TestTenantManages(t *testing.T) {
+	tenants, _, _ := testTenants(t)
+	alice := tenants[0]
+
+	tests := []struct {
+		did  string
+		want bool
+	}{
+		{did: "did:dnssec:a.example.com", want: true},
+		{did: "did:dnssec:A.Example.COM", want: true},
+		{did: "did:dnssec:x.a.example.com", want: true},
+		{did: "did:dnssec:a.example.com:sub", want: true},
+		{did: "did:dnssec:example.com", want: false},
+		{did: "did:dnssec:ba.example.com", want: false},
+		{did: "did:dnssec:b.example.com:a.example.com", want: false},
+		{did: "did:dnssec:a.example.com.evil.example", want: false},
+		{did: "did:web:a.example.com", want: false},
+		{did: "a.example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := alice.Manages(tt.did); got != tt.want {
+			t.Errorf("Manages(%s) = %v, want %v", tt.did, got, tt.want)
+		}
+	}
+}
+
+func TestTenantInit(t *testing.T) {
+	tests := []struct {
+		name   string
+		tenant Tenant
+	}{
+		{name: "empty name", tenant: Tenant{TokenSHA256: strings.Repeat("0", 64)}},
+		{name: "colon in name", tenant: Tenant{Name: "a:b", TokenSHA256: strings.Repeat("0", 64)}},
+		{name: "no credential", tenant: Tenant{Name: "a"}},
+		{name: "short token hash", tenant: Tenant{Name: "a", TokenSHA256: "00"}},
+		{name: "unsupported key", tenant: Tenant{Name: "a", PublicKeyJwk: map[string]interface{}{"kty": "RSA"}}},
+		{name: "invalid domain", tenant: Tenant{Name: "a", TokenSHA256: strings.Repeat("0", 64), Domains: []string{"a..b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tenant.init(); err == nil {
+				t.Errorf("init() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DNSSEC status of the answer, as reported by the recursive resolver with the AD bit.
+const (
+	DNSSECSecure   = "secure"
+	DNSSECInsecure = "insecure"
+	// DNSSECBogus is used when the answer fails the validation of the resolver.
+	DNSSECBogus = "bogus"
+	// DNSSECUnknown is used when the lookup falls back to the system resolver.
+	DNSSECUnknown = "unknown"
+)
+
+// QueryTrace is the report of a TXT query made during the resolution.
+// The Target is the canonical name of the answer if the name is an alias, and Aliases is the number
+// of the CNAME and DNAME records which lead to it.
+type QueryTrace struct {
+	Name    string        `json:"name"`
+	Server  string        `json:"server,omitempty"`
+	Rcode   string        `json:"rcode,omitempty"`
+	TTL     uint32        `json:"ttl"`
+	DNSSEC  string        `json:"dnssec,omitempty"`
+	Records int           `json:"records"`
+	Aliases int           `json:"aliases,omitempty"`
+	Target  string        `json:"target,omitempty"`
+	Start   time.Time     `json:"start"`
+	Latency time.Duration `json:"latency"`
+	Err     error         `json:"-"`
+}
+
+func (q QueryTrace) MarshalJSON() ([]byte, error) {
+	type trace QueryTrace
+	v := struct {
+		trace
+		Latency string `json:"latency"`
+		Error   string `json:"error,omitempty"`
+	}{trace: trace(q), Latency: q.Latency.String()}
+	if q.Err != nil {
+		v.Error = q.Err.Error()
+	}
+
+	return json.Marshal(v)
+}
+
+// TraceHook receives the reports of the queries made by the package.
+// OnQuery may be called concurrently, and must not block.
+type TraceHook interface {
+	OnQuery(q QueryTrace)
+}
+
+// TraceHookFunc adapts the function to TraceHook.
+type TraceHookFunc func(q QueryTrace)
+
+func (f TraceHookFunc) OnQuery(q QueryTrace) {
+	f(q)
+}
+
+type noopHook struct{}
+
+func (noopHook) OnQuery(QueryTrace) {}
+
+// hookHolder wraps the hook, since atomic.Pointer cannot point to an interface value directly.
+type hookHolder struct {
+	h TraceHook
+}
+
+var hook atomic.Pointer[hookHolder]
+
+func init() {
+	hook.Store(&hookHolder{noopHook{}})
+}
+
+// SetTraceHook sets the hook which receives the reports of all the queries of the process.
+// Passing nil removes the hook. Use WithTraceHook for the queries of a resolution.
+func SetTraceHook(h TraceHook) {
+	if h == nil {
+		h = noopHook{}
+	}
+	hook.Store(&hookHolder{h})
+}
+
+type traceHookKey struct{}
+
+// WithTraceHook returns the context with the hook, which receives the reports of the queries
+// made by the resolutions with the context, in addition to the hook set by SetTraceHook.
+func WithTraceHook(ctx context.Context, h TraceHook) context.Context {
+	return context.WithValue(ctx, traceHookKey{}, h)
+}
+
+// reportQuery reports the query to the hook in the context and the one set by SetTraceHook.
+func reportQuery(ctx context.Context, q QueryTrace) {
+	if h, ok := ctx.Value(traceHookKey{}).(TraceHook); ok && h != nil {
+		h.OnQuery(q)
+	}
+	hook.Load().h.OnQuery(q)
+}
+
+// TraceCollector is the TraceHook which records the queries in order.
+type TraceCollector struct {
+	mu      sync.Mutex
+	queries []QueryTrace
+}
+
+func (c *TraceCollector) OnQuery(q QueryTrace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queries = append(c.queries, q)
+}
+
+// Queries returns the recorded queries.
+func (c *TraceCollector) Queries() []QueryTrace {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]QueryTrace{}, c.queries...)
+}
+
+// JSON returns the recorded queries as a JSON array.
+func (c *TraceCollector) JSON() ([]byte, error) {
+	return json.MarshalIndent(c.Queries(), "", "  ")
+}
+
+// WriteTree writes the recorded queries indented by the depth of their names
+// from the first query, followed by the total latency.
+func (c *TraceCollector) WriteTree(w io.Writer) error {
+	queries := c.Queries()
+	if len(queries) == 0 {
+		_, err := fmt.Fprintln(w, "no query")
+		return err
+	}
+
+	base := dnsLabelCount(queries[0].Name)
+	var total time.Duration
+	for _, q := range queries {
+		depth := dnsLabelCount(q.Name) - base
+		if depth < 0 {
+			depth = 0
+		}
+
+		status := q.Rcode
+		if q.Err != nil {
+			status = strings.TrimSpace(q.Rcode + " error: " + q.Err.Error())
+		}
+
+		name := q.Name
+		if q.Target != "" {
+			name += " -> " + q.Target
+			// the records of the delegated root are looked up under the target
+			if depth == 0 {
+				base = dnsLabelCount(q.Target)
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "%s%s  %s ttl=%d dnssec=%s server=%s %s\n",
+			getIndent(depth), name, status, q.TTL, q.DNSSEC, q.Server, q.Latency); err != nil {
+			return err
+		}
+		total += q.Latency
+	}
+
+	_, err := fmt.Fprintf(w, "%d queries in %s\n", len(queries), total)
+	return err
+}
+
+func dnsLabelCount(name string) int {
+	return len(strings.Split(strings.TrimSuffix(name, "."), "."))
+}
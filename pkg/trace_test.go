@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithTraceHook(t *testing.T) {
+	a := testDocument(t, `{"id": "did:dnssec:a.example.com", "a": "b"}`)
+	b := testDocument(t, `{"id": "did:dnssec:b.example.com", "a": ["b", "c"]}`)
+	useBackend(t, testZone(t, "example.com.", append(a.RRs("a.example.com."), b.RRs("b.example.com.")...)...))
+
+	global := &TraceCollector{}
+	SetTraceHook(global)
+	t.Cleanup(func() { SetTraceHook(nil) })
+
+	tests := []struct {
+		did  string
+		want int
+	}{
+		{did: "did:dnssec:a.example.com", want: 3},
+		{did: "did:dnssec:b.example.com", want: 5},
+	}
+
+	collectors := make([]*TraceCollector, len(tests))
+	var wg sync.WaitGroup
+	for i, tt := range tests {
+		collectors[i] = &TraceCollector{}
+		wg.Add(1)
+		go func(ctx context.Context, did string) {
+			defer wg.Done()
+			if _, _, err := ResolveContext(ctx, did); err != nil {
+				t.Errorf("ResolveContext() error = %v", err)
+			}
+		}(WithTraceHook(context.Background(), collectors[i]), tt.did)
+	}
+	wg.Wait()
+
+	for i, tt := range tests {
+		queries := collectors[i].Queries()
+		if len(queries) != tt.want {
+			t.Errorf("queries of %s = %d, want %d", tt.did, len(queries), tt.want)
+		}
+		base, _ := DIDBase(tt.did)
+		for _, q := range queries {
+			if !strings.HasSuffix(q.Name, base) {
+				t.Errorf("query of %s = %s, want the names under %s", tt.did, q.Name, base)
+			}
+		}
+	}
+
+	if got := len(global.Queries()); got != 8 {
+		t.Errorf("queries of the process = %d, want 8", got)
+	}
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Update is the set of changes sent to the primary server with the dynamic update (RFC 2136).
+type Update struct {
+	// DeleteNames deletes all the TXT records at the names.
+	DeleteNames []string
+	// Delete deletes the specific records.
+	Delete []*ResorceRecord
+	// Add adds the records.
+	Add []*ResorceRecord
+}
+
+// UpdateConfig is the configuration to send the dynamic update.
+type UpdateConfig struct {
+	// Server is the address of the primary server (e.g. ns1.example.com:53).
+	Server string
+	// Zone is the zone to be updated, ended with a dot(root).
+	Zone string
+
+	// TSIGName, TSIGSecret and TSIGAlgorithm are used to sign the update if TSIGName is set.
+	// The secret is base64 encoded, and the algorithm defaults to hmac-sha256.
+	TSIGName      string
+	TSIGSecret    string
+	TSIGAlgorithm string
+}
+
+// Send sends the update to the primary server and waits for the response.
+func (u *Update) Send(cfg UpdateConfig) error {
+	msg, err := u.msg(cfg.Zone)
+	if err != nil {
+		return err
+	}
+
+	client := &dns.Client{Net: "tcp"}
+	if cfg.TSIGName != "" {
+		alg := cfg.TSIGAlgorithm
+		if alg == "" {
+			alg = dns.HmacSHA256
+		}
+
+		name := dns.Fqdn(cfg.TSIGName)
+		client.TsigSecret = map[string]string{name: cfg.TSIGSecret}
+		msg.SetTsig(name, dns.Fqdn(alg), 300, time.Now().Unix())
+	}
+
+	Logger().Debug("sending dynamic update", "server", cfg.Server, "zone", cfg.Zone,
+		"deletions", len(u.Delete)+len(u.DeleteNames), "additions", len(u.Add))
+	resp, rtt, err := client.Exchange(msg, cfg.Server)
+	if err != nil {
+		return err
+	}
+	Logger().Debug("received update response", "rcode", dns.RcodeToString[resp.Rcode], "rtt", rtt)
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update refused; rcode = %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	return nil
+}
+
+func (u *Update) msg(zone string) (*dns.Msg, error) {
+	msg := &dns.Msg{}
+	msg.SetUpdate(dns.Fqdn(zone))
+
+	for _, name := range u.DeleteNames {
+		msg.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(name),
+			Rrtype: dns.TypeTXT,
+		}}})
+	}
+
+	if len(u.Delete) > 0 {
+		rrs, err := toDNSRRs(u.Delete)
+		if err != nil {
+			return nil, err
+		}
+		msg.Remove(rrs)
+	}
+
+	if len(u.Add) > 0 {
+		rrs, err := toDNSRRs(u.Add)
+		if err != nil {
+			return nil, err
+		}
+		msg.Insert(rrs)
+	}
+
+	return msg, nil
+}
+
+func toDNSRRs(rrs []*ResorceRecord) ([]dns.RR, error) {
+	res := []dns.RR{}
+	for _, rr := range rrs {
+		r, err := dns.NewRR(rr.String())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, nil
+}
+
+// DiffRRs returns the minimal update which changes the records from old into new.
+// The records are compared by their names, types and data.
+func DiffRRs(old []*ResorceRecord, new []*ResorceRecord) *Update {
+	key := func(rr *ResorceRecord) string {
+		return strings.ToLower(dns.Fqdn(rr.Name)) + " " + rr.Type + " " + rr.Data
+	}
+
+	oldKeys := map[string]bool{}
+	for _, rr := range old {
+		oldKeys[key(rr)] = true
+	}
+
+	newKeys := map[string]bool{}
+	for _, rr := range new {
+		newKeys[key(rr)] = true
+	}
+
+	update := &Update{}
+	for _, rr := range old {
+		if !newKeys[key(rr)] {
+			update.Delete = append(update.Delete, rr)
+		}
+	}
+	for _, rr := range new {
+		if !oldKeys[key(rr)] {
+			update.Add = append(update.Add, rr)
+		}
+	}
+
+	return update
+}
+
+// Empty reports whether the update has no change.
+func (u *Update) Empty() bool {
+	return len(u.DeleteNames) == 0 && len(u.Delete) == 0 && len(u.Add) == 0
+}
+
+// Script returns the update in the nsupdate(1) script format.
+func (u *Update) Script(cfg UpdateConfig) string {
+	sb := strings.Builder{}
+	if cfg.Server != "" {
+		host, port, found := strings.Cut(cfg.Server, ":")
+		if found {
+			fmt.Fprintf(&sb, "server %s %s\n", host, port)
+		} else {
+			fmt.Fprintf(&sb, "server %s\n", host)
+		}
+	}
+	if cfg.Zone != "" {
+		fmt.Fprintf(&sb, "zone %s\n", dns.Fqdn(cfg.Zone))
+	}
+
+	for _, name := range u.DeleteNames {
+		fmt.Fprintf(&sb, "update delete %s TXT\n", dns.Fqdn(name))
+	}
+	for _, rr := range u.Delete {
+		fmt.Fprintf(&sb, "update delete %s %s %s\n", dns.Fqdn(rr.Name), rr.Type, rr.Data)
+	}
+	for _, rr := range u.Add {
+		fmt.Fprintf(&sb, "update add %s %d %s %s %s\n", dns.Fqdn(rr.Name), rr.TTL, rr.Class, rr.Type, rr.Data)
+	}
+
+	sb.WriteString("send\n")
+	return sb.String()
+}
@@ -0,0 +1,334 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// didPattern is the DID syntax defined in DID Core.
+var didPattern = regexp.MustCompile(
+	`^did:[a-z0-9]+:(?:(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2})*:)*(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2})+$`,
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var verificationRelationships = []string{
+	"authentication",
+	"assertionMethod",
+	"keyAgreement",
+	"capabilityInvocation",
+	"capabilityDelegation",
+}
+
+// ValidationError is the violation of the DID Core rules found in the document.
+type ValidationError struct {
+	// Path is the JSON path of the violating value (e.g. $.verificationMethod[0].id).
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors is the list of the violations, sorted by the path.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+
+	return fmt.Sprintf("invalid did document;\n%s", strings.Join(msgs, "\n"))
+}
+
+// Validate checks the document against the DID Core rules.
+// The base argument is the base domain name the document is published under, and the id of
+// the document must be the DID whose base is it (see DIDBase). If the base is empty, the id is not compared.
+//
+// It returns ValidationErrors if the document has any violation.
+func Validate(n *Node, base string) error {
+	v := &validator{ids: map[string]string{}}
+	v.validate(n, base)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	validationFailuresTotal.Inc()
+
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		return v.errs[i].Path < v.errs[j].Path
+	})
+	return v.errs
+}
+
+type validator struct {
+	docID string
+	// ids maps the absolute ids of the verification methods and services to their paths.
+	ids  map[string]string
+	refs []reference
+	errs ValidationErrors
+}
+
+type reference struct {
+	path string
+	id   string
+}
+
+func (v *validator) fail(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(n *Node, base string) {
+	if n.Value.Type != ValTypeMap {
+		v.fail("$", "document must be a map")
+		return
+	}
+
+	id := n.GetChild("id")
+	switch {
+	case id == nil:
+		v.fail("$", "id is required")
+	case id.Value.Type != ValTypeString:
+		v.fail("$.id", "id must be a string")
+	case !didPattern.MatchString(id.Value.String()):
+		v.fail("$.id", "id is not a valid DID; got = %s", id.Value.String())
+	default:
+		v.docID = id.Value.String()
+		if canonical, err := CanonicalDID(v.docID); err == nil && canonical != v.docID {
+			v.fail("$.id", "id is not in the canonical form; expected = %s, actual = %s", canonical, v.docID)
+		}
+		if base != "" {
+			actual, err := DIDBase(v.docID)
+			if err != nil || !strings.EqualFold(strings.TrimSuffix(actual, "."), strings.TrimSuffix(base, ".")) {
+				v.fail("$.id", "id does not match the base; base = %s, id = %s", base, v.docID)
+			}
+		}
+	}
+
+	if c := n.GetChild("controller"); c != nil {
+		v.validateDIDs(c, childPath("$", "controller"))
+	}
+
+	if aka := n.GetChild("alsoKnownAs"); aka != nil {
+		path := childPath("$", "alsoKnownAs")
+		if aka.Value.Type != ValTypeArray {
+			v.fail(path, "alsoKnownAs must be an array")
+		} else {
+			for i, child := range *aka.Children {
+				p := indexPath(path, i)
+				if child.Value.Type != ValTypeString {
+					v.fail(p, "alsoKnownAs must be a URI string")
+				} else if u, err := url.Parse(child.Value.String()); err != nil || u.Scheme == "" {
+					v.fail(p, "alsoKnownAs must be a URI; got = %s", child.Value.String())
+				}
+			}
+		}
+	}
+
+	if vms := n.GetChild("verificationMethod"); vms != nil {
+		path := childPath("$", "verificationMethod")
+		if vms.Value.Type != ValTypeArray {
+			v.fail(path, "verificationMethod must be an array")
+		} else {
+			for i, child := range *vms.Children {
+				v.validateVerificationMethod(child, indexPath(path, i))
+			}
+		}
+	}
+
+	for _, rel := range verificationRelationships {
+		r := n.GetChild(rel)
+		if r == nil {
+			continue
+		}
+
+		path := childPath("$", rel)
+		if r.Value.Type != ValTypeArray {
+			v.fail(path, "%s must be an array", rel)
+			continue
+		}
+
+		for i, child := range *r.Children {
+			p := indexPath(path, i)
+			switch child.Value.Type {
+			case ValTypeString:
+				if abs, ok := v.absoluteID(child.Value.String(), p); ok {
+					v.refs = append(v.refs, reference{path: p, id: abs})
+				}
+			case ValTypeMap:
+				v.validateVerificationMethod(child, p)
+			default:
+				v.fail(p, "%s must be a reference or a verification method", rel)
+			}
+		}
+	}
+
+	if services := n.GetChild("service"); services != nil {
+		path := childPath("$", "service")
+		if services.Value.Type != ValTypeArray {
+			v.fail(path, "service must be an array")
+		} else {
+			for i, child := range *services.Children {
+				v.validateService(child, indexPath(path, i))
+			}
+		}
+	}
+
+	for _, ref := range v.refs {
+		if _, ok := v.ids[ref.id]; !ok && v.isLocal(ref.id) {
+			v.fail(ref.path, "reference does not resolve to a verification method; id = %s", ref.id)
+		}
+	}
+}
+
+func (v *validator) validateVerificationMethod(n *Node, path string) {
+	if n.Value.Type != ValTypeMap {
+		v.fail(path, "verification method must be a map")
+		return
+	}
+
+	v.validateID(n, path)
+
+	if typ := n.GetChild("type"); typ == nil || typ.Value.Type != ValTypeString {
+		v.fail(path, "type of the verification method is required")
+	}
+
+	if c := n.GetChild("controller"); c == nil {
+		v.fail(path, "controller of the verification method is required")
+	} else if c.Value.Type != ValTypeString || !didPattern.MatchString(c.Value.String()) {
+		v.fail(childPath(path, "controller"), "controller is not a valid DID; got = %s", c.Value.String())
+	}
+
+	if n.GetChild("publicKeyJwk") != nil && n.GetChild("publicKeyMultibase") != nil {
+		v.fail(path, "verification method must not have both publicKeyJwk and publicKeyMultibase")
+	}
+}
+
+func (v *validator) validateService(n *Node, path string) {
+	if n.Value.Type != ValTypeMap {
+		v.fail(path, "service must be a map")
+		return
+	}
+
+	v.validateID(n, path)
+
+	if typ := n.GetChild("type"); typ == nil {
+		v.fail(path, "type of the service is required")
+	} else if typ.Value.Type != ValTypeString && typ.Value.Type != ValTypeArray {
+		v.fail(childPath(path, "type"), "type must be a string or a set of strings")
+	}
+
+	ep := n.GetChild("serviceEndpoint")
+	if ep == nil {
+		v.fail(path, "serviceEndpoint of the service is required")
+		return
+	}
+
+	v.validateServiceEndpoint(ep, childPath(path, "serviceEndpoint"), true)
+}
+
+func (v *validator) validateServiceEndpoint(n *Node, path string, allowSet bool) {
+	switch n.Value.Type {
+	case ValTypeString:
+		if u, err := url.Parse(n.Value.String()); err != nil || u.Scheme == "" {
+			v.fail(path, "serviceEndpoint must be a URI; got = %s", n.Value.String())
+		}
+	case ValTypeMap:
+	case ValTypeArray:
+		if !allowSet {
+			v.fail(path, "serviceEndpoint set must not be nested")
+			return
+		}
+		for i, child := range *n.Children {
+			v.validateServiceEndpoint(child, indexPath(path, i), false)
+		}
+	default:
+		v.fail(path, "serviceEndpoint must be a URI, a map or a set of them")
+	}
+}
+
+// validateID checks the id of the verification method or service, and registers it.
+func (v *validator) validateID(n *Node, path string) {
+	id := n.GetChild("id")
+	if id == nil {
+		v.fail(path, "id is required")
+		return
+	}
+
+	idPath := childPath(path, "id")
+	if id.Value.Type != ValTypeString {
+		v.fail(idPath, "id must be a string")
+		return
+	}
+
+	abs, ok := v.absoluteID(id.Value.String(), idPath)
+	if !ok {
+		return
+	}
+
+	if dup, ok := v.ids[abs]; ok {
+		v.fail(idPath, "duplicated id; id = %s, first defined at %s", abs, dup)
+		return
+	}
+	v.ids[abs] = idPath
+}
+
+// absoluteID resolves the relative DID URL against the id of the document.
+func (v *validator) absoluteID(id string, path string) (string, bool) {
+	if strings.HasPrefix(id, "#") || strings.HasPrefix(id, "?") {
+		if v.docID == "" {
+			v.fail(path, "relative reference cannot be resolved without the document id; got = %s", id)
+			return "", false
+		}
+		return v.docID + id, true
+	}
+
+	did, _, _ := strings.Cut(id, "#")
+	did, _, _ = strings.Cut(did, "?")
+	did, _, _ = strings.Cut(did, "/")
+	if !didPattern.MatchString(did) {
+		v.fail(path, "id is not a valid DID URL; got = %s", id)
+		return "", false
+	}
+
+	return id, true
+}
+
+// isLocal reports whether the DID URL refers to the document itself.
+func (v *validator) isLocal(id string) bool {
+	return v.docID != "" && strings.HasPrefix(id, v.docID) &&
+		(len(id) == len(v.docID) || strings.ContainsAny(id[len(v.docID):len(v.docID)+1], "#?/"))
+}
+
+func (v *validator) validateDIDs(n *Node, path string) {
+	switch n.Value.Type {
+	case ValTypeString:
+		if !didPattern.MatchString(n.Value.String()) {
+			v.fail(path, "not a valid DID; got = %s", n.Value.String())
+		}
+	case ValTypeArray:
+		for i, child := range *n.Children {
+			v.validateDIDs(child, indexPath(path, i))
+		}
+	default:
+		v.fail(path, "must be a DID or a set of DIDs")
+	}
+}
+
+func childPath(path string, key string) string {
+	if identifierPattern.MatchString(key) {
+		return path + "." + key
+	}
+
+	return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+}
+
+func indexPath(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const vm = `{"id": "#key-1", "type": "Multikey", "controller": "did:dnssec:example.com", "publicKeyMultibase": "z6Mk"}`
+
+	tests := []struct {
+		name string
+		doc  string
+		base string
+		// paths is the paths of the violations, or empty if the document is valid
+		paths []string
+	}{
+		{name: "minimal", doc: `{"id": "did:dnssec:example.com"}`},
+		{name: "matching base", doc: `{"id": "did:dnssec:example.com"}`, base: "example.com."},
+		{name: "matching base without root", doc: `{"id": "did:dnssec:example.com"}`, base: "example.com"},
+		{name: "full", doc: `{
+			"id": "did:dnssec:example.com",
+			"controller": ["did:dnssec:example.com", "did:web:example.org"],
+			"alsoKnownAs": ["https://example.com/"],
+			"verificationMethod": [` + vm + `],
+			"authentication": ["#key-1", "did:dnssec:example.com#key-1", "did:web:example.org#key-1"],
+			"assertionMethod": [{"id": "#key-2", "type": "JsonWebKey", "controller": "did:dnssec:example.com", "publicKeyJwk": {}}],
+			"service": [{"id": "#s", "type": ["A", "B"], "serviceEndpoint": ["https://a.example", {"uri": "https://b.example"}]}]
+		}`},
+
+		{name: "no id", doc: `{}`, paths: []string{"$"}},
+		{name: "id not a string", doc: `{"id": 1}`, paths: []string{"$.id"}},
+		{name: "id not a DID", doc: `{"id": "example.com"}`, paths: []string{"$.id"}},
+		{name: "id not canonical", doc: `{"id": "did:dnssec:Example.COM"}`, paths: []string{"$.id"}},
+		{name: "id and base mismatch", doc: `{"id": "did:dnssec:example.com"}`, base: "example.org.", paths: []string{"$.id"}},
+		{name: "id of the subdomain", doc: `{"id": "did:dnssec:a.example.com"}`, base: "example.com.", paths: []string{"$.id"}},
+		{name: "id with the sub-identifier", doc: `{"id": "did:dnssec:example.com:a"}`, base: "example.com.", paths: []string{"$.id"}},
+
+		{name: "controller not a string", doc: `{"id": "did:dnssec:example.com", "controller": 1}`, paths: []string{"$.controller"}},
+		{name: "controller not a DID", doc: `{"id": "did:dnssec:example.com", "controller": "example.com"}`, paths: []string{"$.controller"}},
+		{name: "controller set with a number", doc: `{"id": "did:dnssec:example.com", "controller": ["did:dnssec:a.example", 1]}`, paths: []string{"$.controller[1]"}},
+		{name: "alsoKnownAs not a URI", doc: `{"id": "did:dnssec:example.com", "alsoKnownAs": ["example"]}`, paths: []string{"$.alsoKnownAs[0]"}},
+
+		{name: "duplicated method ids", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [` + vm + `, ` + vm + `]}`,
+			paths: []string{"$.verificationMethod[1].id"}},
+		{name: "duplicated relative and absolute ids", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [` + vm + `,
+			{"id": "did:dnssec:example.com#key-1", "type": "Multikey", "controller": "did:dnssec:example.com"}]}`,
+			paths: []string{"$.verificationMethod[1].id"}},
+		{name: "duplicated service ids", doc: `{"id": "did:dnssec:example.com", "service": [
+			{"id": "#s", "type": "A", "serviceEndpoint": "https://a.example"},
+			{"id": "#s", "type": "B", "serviceEndpoint": "https://b.example"}]}`,
+			paths: []string{"$.service[1].id"}},
+		{name: "method and service with the same id", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [` + vm + `],
+			"service": [{"id": "#key-1", "type": "A", "serviceEndpoint": "https://a.example"}]}`,
+			paths: []string{"$.service[0].id"}},
+		{name: "embedded method with the id of a method", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [` + vm + `],
+			"authentication": [` + vm + `]}`,
+			paths: []string{"$.authentication[0].id"}},
+
+		{name: "dangling relative reference", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [` + vm + `],
+			"authentication": ["#key-2"]}`,
+			paths: []string{"$.authentication[0]"}},
+		{name: "dangling absolute reference", doc: `{"id": "did:dnssec:example.com", "assertionMethod": ["did:dnssec:example.com#key-1"]}`,
+			paths: []string{"$.assertionMethod[0]"}},
+		{name: "relationship not an array", doc: `{"id": "did:dnssec:example.com", "authentication": "#key-1"}`,
+			paths: []string{"$.authentication"}},
+		{name: "relationship with a number", doc: `{"id": "did:dnssec:example.com", "capabilityInvocation": [1]}`,
+			paths: []string{"$.capabilityInvocation[0]"}},
+
+		{name: "both publicKeyJwk and publicKeyMultibase", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [
+			{"id": "#key-1", "type": "Multikey", "controller": "did:dnssec:example.com", "publicKeyJwk": {}, "publicKeyMultibase": "z6Mk"}]}`,
+			paths: []string{"$.verificationMethod[0]"}},
+		{name: "method without type and controller", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [{"id": "#key-1"}]}`,
+			paths: []string{"$.verificationMethod[0]", "$.verificationMethod[0]"}},
+		{name: "method with invalid controller", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [
+			{"id": "#key-1", "type": "Multikey", "controller": "example.com"}]}`,
+			paths: []string{"$.verificationMethod[0].controller"}},
+		{name: "method with invalid id", doc: `{"id": "did:dnssec:example.com", "verificationMethod": [
+			{"id": "key-1", "type": "Multikey", "controller": "did:dnssec:example.com"}]}`,
+			paths: []string{"$.verificationMethod[0].id"}},
+
+		{name: "service without endpoint", doc: `{"id": "did:dnssec:example.com", "service": [{"id": "#s", "type": "A"}]}`,
+			paths: []string{"$.service[0]"}},
+		{name: "service with a nested endpoint set", doc: `{"id": "did:dnssec:example.com", "service": [
+			{"id": "#s", "type": "A", "serviceEndpoint": [["https://a.example"]]}]}`,
+			paths: []string{"$.service[0].serviceEndpoint[0]"}},
+		{name: "service with a relative endpoint", doc: `{"id": "did:dnssec:example.com", "service": [
+			{"id": "#s", "type": 1, "serviceEndpoint": "/a"}]}`,
+			paths: []string{"$.service[0].serviceEndpoint", "$.service[0].type"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(testDocument(t, tt.doc), tt.base)
+			if len(tt.paths) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+			}
+			paths := []string{}
+			for _, e := range errs {
+				paths = append(paths, e.Path)
+			}
+			if strings.Join(paths, " ") != strings.Join(tt.paths, " ") {
+				t.Errorf("Validate() paths = %v, want %v; error = %v", paths, tt.paths, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocumentMetadata is the DID document metadata defined in DID Core.
+// The CanonicalID is the canonical form of the resolved DID (see CanonicalDID), and the Location is
+// the name of the root records when the `_did` name is delegated to another zone (see DelegationRR).
+type DocumentMetadata struct {
+	Updated       *time.Time `json:"updated,omitempty"`
+	Deactivated   bool       `json:"deactivated,omitempty"`
+	VersionID     string     `json:"versionId,omitempty"`
+	NextVersionID string     `json:"nextVersionId,omitempty"`
+	NextUpdate    *time.Time `json:"nextUpdate,omitempty"`
+	CanonicalID   string     `json:"canonicalId,omitempty"`
+	Location      string     `json:"location,omitempty"`
+}
+
+// Version is the version metadata of the document.
+//
+// The version record is published at the root name of the document along with the document records:
+//
+//	`v=did:dnssec; t=v; d=<id>; ts=<time>`
+//	- id: the version number of the document, starting from 1.
+//	- time: the time when the version is published, in RFC 3339.
+//
+// The current version is published under `_did.<base>`, and the previous versions are kept under
+// `v<id>._did.<base>`. The labels starting with "v" never collide with the labels of the keys,
+// because they decode into an invalid UTF-8 sequence.
+type Version struct {
+	ID   int
+	Time time.Time
+}
+
+// RR returns the version record of the document published under the given root name.
+func (v Version) RR(name string) *ResorceRecord {
+	return &ResorceRecord{
+		Name:  name,
+		Class: "IN",
+		Type:  "TXT",
+		TTL:   3600,
+		Data: zoneData(formatRecord(
+			"did:dnssec", "t", "v", "d", strconv.Itoa(v.ID), "ts", v.Time.UTC().Format(time.RFC3339),
+		)),
+	}
+}
+
+// ArchiveRRs moves the records of the current document under `_did.<base>` into the
+// versioned name `v<id>._did.<base>`, so that the version can be kept after it is replaced.
+// The base argument must be ended with a dot(root).
+func ArchiveRRs(rrs []*ResorceRecord, base string, versionID int) []*ResorceRecord {
+	root := fmt.Sprintf("_did.%s", base)
+	archived := []*ResorceRecord{}
+
+	for _, rr := range rrs {
+		copied := *rr
+		if strings.HasSuffix(rr.Name, root) {
+			copied.Name = fmt.Sprintf("%sv%d.%s", strings.TrimSuffix(rr.Name, root), versionID, root)
+		}
+		archived = append(archived, &copied)
+	}
+
+	return archived
+}
+
+// parseVersionRecord finds the version record in the TXT records.
+func parseVersionRecord(txt []string) (*Version, error) {
+	for _, v := range txt {
+		mapping, err := parseTagList(v)
+		if err != nil || mapping["v"] != "did:dnssec" || mapping["t"] != "v" {
+			continue
+		}
+
+		id, err := strconv.Atoi(mapping["d"])
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid version id; got = %s", mapping["d"])
+		}
+
+		ts, err := time.Parse(time.RFC3339, mapping["ts"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version time; got = %s", mapping["ts"])
+		}
+
+		return &Version{ID: id, Time: ts}, nil
+	}
+
+	return nil, nil
+}
+
+// parseDIDURL splits the DID URL into the DID and the query parameters.
+// The path and fragment are not supported for now.
+func parseDIDURL(didURL string) (string, url.Values, error) {
+	did, query, _ := strings.Cut(didURL, "?")
+	if strings.ContainsAny(did, "/#") || strings.Contains(query, "#") {
+		return "", nil, fmt.Errorf("%w: path and fragment are not supported; did = %s", ErrInvalidDIDURL, didURL)
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: invalid query; did = %s", ErrInvalidDIDURL, didURL)
+	}
+
+	return did, params, nil
+}
+
+// selectVersion finds the records of the version requested with the versionId or versionTime parameter.
+// The name and txt arguments are the root name of the current document and its records.
+func selectVersion(ctx context.Context, name string, txt []string, params url.Values) (string, []string, *DocumentMetadata, error) {
+	current, err := parseVersionRecord(txt)
+	if err != nil {
+		return "", nil, nil, recordFormatError(name, txt, err)
+	}
+
+	if !params.Has("versionId") && !params.Has("versionTime") {
+		if current == nil {
+			return name, txt, &DocumentMetadata{}, nil
+		}
+		return name, txt, versionMetadata(current, nil), nil
+	}
+
+	if current == nil {
+		return "", nil, nil, fmt.Errorf("%w: document is not versioned", ErrNotFound)
+	}
+
+	if params.Has("versionId") {
+		id, err := strconv.Atoi(params.Get("versionId"))
+		if err != nil || id < 1 || id > current.ID {
+			return "", nil, nil, fmt.Errorf("%w: version not found; versionId = %s", ErrNotFound, params.Get("versionId"))
+		}
+
+		if id == current.ID {
+			return name, txt, versionMetadata(current, nil), nil
+		}
+
+		return findVersion(ctx, name, id, current, func(v *Version) bool { return true })
+	}
+
+	at, err := time.Parse(time.RFC3339, params.Get("versionTime"))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%w: invalid versionTime; got = %s", ErrInvalidDIDURL, params.Get("versionTime"))
+	}
+
+	if !current.Time.After(at) {
+		return name, txt, versionMetadata(current, nil), nil
+	}
+
+	return findVersion(ctx, name, current.ID-1, current, func(v *Version) bool { return !v.Time.After(at) })
+}
+
+// findVersion walks the previous versions from the given id towards the first one,
+// and returns the records of the version accepted by the match function.
+func findVersion(
+	ctx context.Context, name string, id int, current *Version, match func(v *Version) bool,
+) (string, []string, *DocumentMetadata, error) {
+	next := current
+
+	for ; id >= 1; id-- {
+		txt, vName, err := lookupTXT(ctx, fmt.Sprintf("v%d.%s", id, name))
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		version, err := parseVersionRecord(txt)
+		if err != nil {
+			return "", nil, nil, recordFormatError(vName, txt, err)
+		}
+		if version == nil || version.ID != id {
+			return "", nil, nil, recordFormatError(vName, txt, fmt.Errorf("version record not found"))
+		}
+
+		if match(version) {
+			if next.ID != id+1 {
+				// the next version has not been looked up yet
+				next = &Version{ID: id + 1}
+			}
+			return vName, txt, versionMetadata(version, next), nil
+		}
+
+		next = version
+	}
+
+	return "", nil, nil, fmt.Errorf("%w: version not found", ErrNotFound)
+}
+
+func versionMetadata(version *Version, next *Version) *DocumentMetadata {
+	updated := version.Time
+	meta := &DocumentMetadata{
+		Updated:   &updated,
+		VersionID: strconv.Itoa(version.ID),
+	}
+
+	if next != nil {
+		meta.NextVersionID = strconv.Itoa(next.ID)
+		if !next.Time.IsZero() {
+			nextUpdate := next.Time
+			meta.NextUpdate = &nextUpdate
+		}
+	}
+
+	return meta
+}
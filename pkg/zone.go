@@ -0,0 +1,297 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Zone is the authoritative zone served by Server, built from the records of the documents.
+// It is not modified once built; Server swaps the whole zone to change the records atomically.
+type Zone struct {
+	origin string
+	soa    *dns.SOA
+	// rrsets maps the lowercase names to their RRsets by type.
+	// The empty non-terminals, the names which only have the names below them, have no RRset.
+	rrsets map[string]map[uint16][]dns.RR
+
+	// signed reports whether the zone is signed, and the fields below are set only if it is.
+	signed bool
+	// sigs maps the names to the signatures of their RRsets by the covered type.
+	sigs map[string]map[uint16][]dns.RR
+	// chain is the NSEC3 records sorted by the hashed owner names.
+	chain      []*dns.NSEC3
+	hashes     []string
+	chainSigs  map[string][]dns.RR
+	iterations uint16
+	salt       string
+}
+
+// ZoneOptions is the options to build the zone.
+type ZoneOptions struct {
+	// NS is the names of the nameservers of the zone, synthesized as the NS records at the origin.
+	// It defaults to `ns.<origin>`.
+	NS []string
+	// Key and Signer sign the zone with DNSSEC if set. The key signs all the RRsets, including the
+	// DNSKEY RRset, and the nonexistence of the names and types is proven with NSEC3.
+	Key    *dns.DNSKEY
+	Signer crypto.Signer
+	// NSEC3Iterations and NSEC3Salt (in hex) are the parameters of the hash of NSEC3.
+	// RFC 9276 recommends no additional iteration and no salt, which are the defaults.
+	NSEC3Iterations uint16
+	NSEC3Salt       string
+	// Validity is the validity period of the signatures. It defaults to 7 days; the zone must be
+	// rebuilt before they expire.
+	Validity time.Duration
+}
+
+// NewZone builds the zone of the origin from the records, synthesizing the SOA and NS records.
+// All the records must be in the zone. The origin must be ended with a dot(root).
+func NewZone(origin string, rrs []*ResorceRecord, opts ZoneOptions) (*Zone, error) {
+	origin = strings.ToLower(dns.Fqdn(origin))
+	z := &Zone{
+		origin: origin,
+		rrsets: map[string]map[uint16][]dns.RR{},
+	}
+
+	records, err := toDNSRRs(rrs)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := opts.NS
+	if len(ns) == 0 {
+		ns = []string{"ns." + origin}
+	}
+
+	z.soa = &dns.SOA{
+		Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+		Ns:      dns.Fqdn(ns[0]),
+		Mbox:    "hostmaster." + origin,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  300,
+	}
+	records = append(records, z.soa)
+	for _, n := range ns {
+		records = append(records, &dns.NS{
+			Hdr: dns.RR_Header{Name: origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600},
+			Ns:  dns.Fqdn(n),
+		})
+	}
+
+	if opts.Key != nil {
+		key := *opts.Key
+		key.Hdr = dns.RR_Header{Name: origin, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600}
+		records = append(records, &key, &dns.NSEC3PARAM{
+			Hdr:        dns.RR_Header{Name: origin, Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET},
+			Hash:       dns.SHA1,
+			Iterations: opts.NSEC3Iterations,
+			SaltLength: uint8(len(opts.NSEC3Salt) / 2),
+			Salt:       opts.NSEC3Salt,
+		})
+	}
+
+	for _, rr := range dns.Dedup(records, nil) {
+		name := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(origin, name) {
+			return nil, fmt.Errorf("record out of zone; zone = %s, name = %s", origin, name)
+		}
+		rr.Header().Name = name
+
+		z.addName(name)
+		z.rrsets[name][rr.Header().Rrtype] = append(z.rrsets[name][rr.Header().Rrtype], rr)
+	}
+
+	if opts.Key != nil {
+		if err := z.sign(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return z, nil
+}
+
+// addName adds the name and the empty non-terminals between it and the origin.
+func (z *Zone) addName(name string) {
+	for {
+		if _, ok := z.rrsets[name]; ok {
+			return
+		}
+		z.rrsets[name] = map[uint16][]dns.RR{}
+
+		if name == z.origin {
+			return
+		}
+		parent, _ := dns.NextLabel(name, 0)
+		name = name[parent:]
+	}
+}
+
+// Origin returns the origin of the zone.
+func (z *Zone) Origin() string {
+	return z.origin
+}
+
+// sign signs all the RRsets, and builds the NSEC3 chain of the names.
+func (z *Zone) sign(opts ZoneOptions) error {
+	validity := opts.Validity
+	if validity == 0 {
+		validity = 7 * 24 * time.Hour
+	}
+	// the inception is set back for the clock skew of the validators
+	now := time.Now()
+	sig := func(name string, rrset []dns.RR) (*dns.RRSIG, error) {
+		s := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rrset[0].Header().Ttl},
+			Inception:  uint32(now.Add(-time.Hour).Unix()),
+			Expiration: uint32(now.Add(validity).Unix()),
+			KeyTag:     opts.Key.KeyTag(),
+			SignerName: z.origin,
+			Algorithm:  opts.Key.Algorithm,
+		}
+		if err := s.Sign(opts.Signer, rrset); err != nil {
+			return nil, fmt.Errorf("failed to sign the rrset; name = %s, type = %s: %w",
+				name, dns.TypeToString[rrset[0].Header().Rrtype], err)
+		}
+		return s, nil
+	}
+
+	z.signed = true
+	z.iterations = opts.NSEC3Iterations
+	z.salt = opts.NSEC3Salt
+	z.sigs = map[string]map[uint16][]dns.RR{}
+	z.chainSigs = map[string][]dns.RR{}
+
+	for name, rrsets := range z.rrsets {
+		z.sigs[name] = map[uint16][]dns.RR{}
+		types := []uint16{}
+		for t, rrset := range rrsets {
+			s, err := sig(name, rrset)
+			if err != nil {
+				return err
+			}
+			z.sigs[name][t] = []dns.RR{s}
+			types = append(types, t)
+		}
+		if len(types) > 0 {
+			types = append(types, dns.TypeRRSIG)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+		z.chain = append(z.chain, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: z.soa.Minttl},
+			Hash:       dns.SHA1,
+			Iterations: z.iterations,
+			SaltLength: uint8(len(z.salt) / 2),
+			Salt:       z.salt,
+			HashLength: 20,
+			NextDomain: z.hash(name),
+			TypeBitMap: types,
+		})
+	}
+
+	// the owner of each NSEC3 is its hash, and the next is the one of the following record
+	sort.Slice(z.chain, func(i, j int) bool { return z.chain[i].NextDomain < z.chain[j].NextDomain })
+	z.hashes = make([]string, len(z.chain))
+	for i, n := range z.chain {
+		z.hashes[i] = n.NextDomain
+	}
+	for i, n := range z.chain {
+		n.Hdr.Name = strings.ToLower(z.hashes[i]) + "." + z.origin
+		n.NextDomain = z.hashes[(i+1)%len(z.hashes)]
+
+		s, err := sig(n.Hdr.Name, []dns.RR{n})
+		if err != nil {
+			return err
+		}
+		z.chainSigs[n.Hdr.Name] = []dns.RR{s}
+	}
+
+	return nil
+}
+
+// hash returns the NSEC3 hash of the name in uppercase base32hex.
+func (z *Zone) hash(name string) string {
+	return dns.HashName(name, dns.SHA1, z.iterations, z.salt)
+}
+
+// nsec3 returns the NSEC3 record matching the hash of the existing name, or covering it otherwise,
+// with its signature.
+func (z *Zone) nsec3(name string) []dns.RR {
+	h := z.hash(name)
+	// the record of the hash, or the last one before it, which covers it;
+	// the last record covers the hashes before the first one
+	i := sort.SearchStrings(z.hashes, h)
+	if i == len(z.hashes) || z.hashes[i] != h {
+		i--
+	}
+	if i < 0 {
+		i = len(z.chain) - 1
+	}
+
+	n := z.chain[i]
+	return append([]dns.RR{n}, z.chainSigs[n.Hdr.Name]...)
+}
+
+// GenerateZoneKey generates the ECDSA P-256 key to sign the zone of the origin,
+// with the SEP flag to be used as both the key signing key and the zone signing key.
+func GenerateZoneKey(origin string) (*dns.DNSKEY, crypto.Signer, error) {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(origin), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	priv, err := key.Generate(256)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, priv.(*ecdsa.PrivateKey), nil
+}
+
+// ReadZoneKey reads the key to sign the zone from the key files in the BIND format
+// (K<origin>+<alg>+<tag>.key and .private), given the path without the extension.
+func ReadZoneKey(path string) (*dns.DNSKEY, crypto.Signer, error) {
+	pub, err := os.Open(path + ".key")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer pub.Close()
+
+	rr, err := dns.ReadRR(pub, path+".key")
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, nil, fmt.Errorf("not a dnskey record; path = %s.key", path)
+	}
+
+	priv, err := os.Open(path + ".private")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer priv.Close()
+
+	pk, err := key.ReadPrivateKey(priv, path+".private")
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := pk.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key; path = %s.private", path)
+	}
+
+	return key, signer, nil
+}